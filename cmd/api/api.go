@@ -0,0 +1,287 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the computation of the API of a package.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"io"
+	"sort"
+	"strings"
+
+	"code.google.com/p/go.tools/go/types"
+	"github.com/sourcegraph/go.tools/go/exact"
+)
+
+// An API maps feature keys to feature descriptions. A key identifies an
+// exported feature, e.g. "pkg io, func Copy"; the description describes
+// its type or value, e.g. "func(io.Writer, io.Reader) (int64, error)".
+type API map[string]string
+
+// Descriptions of struct and interface types, recorded for their type
+// features. Whether a struct or interface has unexported members decides
+// if new members are compatible.
+const (
+	structDesc             = "struct"
+	structUnexportedDesc   = "struct (has unexported fields)"
+	ifaceDesc              = "interface"
+	ifaceUnexportedDesc    = "interface (has unexported methods)"
+	featureSep             = ": " // separates key and description in API files
+	structFeature          = " struct, field "
+	structEmbeddedFeature  = " struct, embedded "
+	interfaceMethodFeature = " interface, method "
+)
+
+// packageAPI returns the API of pkg.
+func packageAPI(pkg *types.Package) API {
+	w := apiWriter{pkg: pkg, api: make(API)}
+	for _, obj := range pkg.Scope().Entries {
+		if ast.IsExported(obj.Name()) {
+			w.object(obj)
+		}
+	}
+	return w.api
+}
+
+// apiWriter collects the features of a package.
+type apiWriter struct {
+	pkg *types.Package
+	api API
+}
+
+func (w *apiWriter) add(key, desc string) {
+	w.api["pkg "+w.pkg.Path()+", "+key] = desc
+}
+
+func (w *apiWriter) object(obj types.Object) {
+	switch obj := obj.(type) {
+	case *types.Const:
+		w.add("const "+obj.Name(), w.typeString(obj.Type())+" = "+exact.Literal(obj.Val(), exact.Decimal, -1))
+	case *types.Var:
+		w.add("var "+obj.Name(), w.typeString(obj.Type()))
+	case *types.Func:
+		w.add("func "+obj.Name(), w.typeString(obj.Type()))
+	case *types.TypeName:
+		w.typeName(obj)
+	}
+}
+
+func (w *apiWriter) typeName(obj *types.TypeName) {
+	name := obj.Name()
+	typ := obj.Type()
+
+	switch u := typ.Underlying().(type) {
+	case *types.Struct:
+		desc := structDesc
+		for i := 0; i < u.NumFields(); i++ {
+			f := u.Field(i)
+			if !ast.IsExported(f.Name()) {
+				desc = structUnexportedDesc
+				continue
+			}
+			if f.IsAnonymous {
+				w.add("type "+name+structEmbeddedFeature+f.Name(), w.typeString(f.Type()))
+			} else {
+				w.add("type "+name+structFeature+f.Name(), w.typeString(f.Type()))
+			}
+		}
+		w.add("type "+name, desc)
+
+	case *types.Interface:
+		desc := ifaceDesc
+		u.ForEachMethod(func(m *types.Func) {
+			if !ast.IsExported(m.Name()) {
+				desc = ifaceUnexportedDesc
+				return
+			}
+			w.add("type "+name+interfaceMethodFeature+m.Name(), w.typeString(m.Type()))
+		})
+		w.add("type "+name, desc)
+
+	default:
+		w.add("type "+name, w.typeString(u))
+	}
+
+	// methods
+	if t, ok := typ.(*types.Named); ok {
+		t.ForEachMethod(func(m *types.Func) {
+			if !ast.IsExported(m.Name()) {
+				return
+			}
+			sig := m.Type().(*types.Signature)
+			recv := name
+			if _, isPtr := sig.Recv().Type().(*types.Pointer); isPtr {
+				recv = "*" + name
+			}
+			w.add("method ("+recv+") "+m.Name(), w.typeString(sig))
+		})
+	}
+}
+
+// typeString returns the canonical description of typ. Named types of
+// other packages are qualified with their package path; parameter and
+// result names are omitted.
+func (w *apiWriter) typeString(typ types.Type) string {
+	var buf bytes.Buffer
+	w.writeType(&buf, typ)
+	return buf.String()
+}
+
+func (w *apiWriter) writeType(buf *bytes.Buffer, typ types.Type) {
+	switch t := typ.(type) {
+	case *types.Basic:
+		buf.WriteString(t.Name())
+
+	case *types.Array:
+		fmt.Fprintf(buf, "[%d]", t.Len())
+		w.writeType(buf, t.Elem())
+
+	case *types.Slice:
+		buf.WriteString("[]")
+		w.writeType(buf, t.Elem())
+
+	case *types.Struct:
+		buf.WriteString("struct{")
+		for i := 0; i < t.NumFields(); i++ {
+			if i > 0 {
+				buf.WriteString("; ")
+			}
+			f := t.Field(i)
+			if !f.IsAnonymous {
+				buf.WriteString(f.Name())
+				buf.WriteByte(' ')
+			}
+			w.writeType(buf, f.Type())
+			if tag := t.Tag(i); tag != "" {
+				fmt.Fprintf(buf, " %q", tag)
+			}
+		}
+		buf.WriteByte('}')
+
+	case *types.Pointer:
+		buf.WriteByte('*')
+		w.writeType(buf, t.Elem())
+
+	case *types.Signature:
+		buf.WriteString("func")
+		w.writeSignature(buf, t)
+
+	case *types.Interface:
+		// sort methods for a canonical representation
+		var methods []string
+		t.ForEachMethod(func(m *types.Func) {
+			methods = append(methods, m.Name()+w.signatureString(m.Type().(*types.Signature)))
+		})
+		sort.Strings(methods)
+		buf.WriteString("interface{")
+		buf.WriteString(strings.Join(methods, "; "))
+		buf.WriteByte('}')
+
+	case *types.Map:
+		buf.WriteString("map[")
+		w.writeType(buf, t.Key())
+		buf.WriteByte(']')
+		w.writeType(buf, t.Elem())
+
+	case *types.Chan:
+		switch t.Dir() {
+		case ast.SEND:
+			buf.WriteString("chan<- ")
+		case ast.RECV:
+			buf.WriteString("<-chan ")
+		default:
+			buf.WriteString("chan ")
+		}
+		w.writeType(buf, t.Elem())
+
+	case *types.Named:
+		obj := t.Obj()
+		if pkg := obj.Pkg(); pkg != nil && pkg.Path() != w.pkg.Path() {
+			buf.WriteString(pkg.Path())
+			buf.WriteByte('.')
+		}
+		buf.WriteString(obj.Name())
+
+	default:
+		fmt.Fprintf(buf, "<%T>", t)
+	}
+}
+
+func (w *apiWriter) signatureString(sig *types.Signature) string {
+	var buf bytes.Buffer
+	w.writeSignature(&buf, sig)
+	return buf.String()
+}
+
+func (w *apiWriter) writeSignature(buf *bytes.Buffer, sig *types.Signature) {
+	w.writeTuple(buf, sig.Params(), sig.IsVariadic())
+	res := sig.Results()
+	switch res.Len() {
+	case 0:
+		// no result
+	case 1:
+		buf.WriteByte(' ')
+		w.writeType(buf, res.At(0).Type())
+	default:
+		buf.WriteByte(' ')
+		w.writeTuple(buf, res, false)
+	}
+}
+
+func (w *apiWriter) writeTuple(buf *bytes.Buffer, tup *types.Tuple, isVariadic bool) {
+	buf.WriteByte('(')
+	for i, n := 0, tup.Len(); i < n; i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		typ := tup.At(i).Type()
+		if isVariadic && i == n-1 {
+			buf.WriteString("...")
+			if s, ok := typ.(*types.Slice); ok {
+				typ = s.Elem()
+			}
+		}
+		w.writeType(buf, typ)
+	}
+	buf.WriteByte(')')
+}
+
+// write writes the API in canonical form, one sorted feature per line.
+func (api API) write(out io.Writer) error {
+	keys := make([]string, 0, len(api))
+	for key := range api {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(out, "%s%s%s\n", key, featureSep, api[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAPI reads an API in the form written by API.write.
+// Empty lines and lines starting with '#' are ignored.
+func readAPI(r io.Reader) (API, error) {
+	api := make(API)
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		i := strings.Index(text, featureSep)
+		if i < 0 || !strings.HasPrefix(text, "pkg ") {
+			return nil, fmt.Errorf("line %d: invalid feature: %s", line, text)
+		}
+		api[text[:i]] = text[i+len(featureSep):]
+	}
+	return api, s.Err()
+}
@@ -0,0 +1,147 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+func apiOf(t *testing.T, src string) API {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := types.Check("p", fset, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packageAPI(pkg)
+}
+
+const oldSrc = `package p
+
+const C = 42
+
+const D = 1.5
+
+var V *T
+
+type T struct {
+	X int
+	U
+}
+
+type U int
+
+func (T) M(x, y int) error { return nil }
+func (*T) P() {}
+func (*T) Q() {}
+
+type S struct {
+	A int
+	b int
+}
+
+type I interface {
+	M(int) error
+}
+
+type J interface {
+	M()
+	m()
+}
+
+func F(args ...string) (int, bool) { return 0, false }
+
+func internal() {}
+`
+
+func TestAPI(t *testing.T) {
+	var buf bytes.Buffer
+	if err := apiOf(t, oldSrc).write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	const want = `pkg p, const C: untyped integer = 42
+pkg p, const D: untyped float = 1.5
+pkg p, func F: func(...string) (int, bool)
+pkg p, method (*T) P: func()
+pkg p, method (*T) Q: func()
+pkg p, method (T) M: func(int, int) error
+pkg p, type I: interface
+pkg p, type I interface, method M: func(int) error
+pkg p, type J: interface (has unexported methods)
+pkg p, type J interface, method M: func()
+pkg p, type S: struct (has unexported fields)
+pkg p, type S struct, field A: int
+pkg p, type T: struct
+pkg p, type T struct, embedded U: U
+pkg p, type T struct, field X: int
+pkg p, type U: int
+pkg p, var V: *T
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got API\n%s\nwant\n%s", got, want)
+	}
+
+	// the API can be read back
+	api, err := readAPI(strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := compareAPIs(apiOf(t, oldSrc), api); len(changes) != 0 {
+		t.Errorf("got changes %v for identical APIs", changes)
+	}
+}
+
+func TestCompareAPIs(t *testing.T) {
+	newSrc := strings.NewReplacer(
+		"const C = 42", "const C = 43",
+		"func (T) M(x, y int) error", "func (T) M(a, b int) error", // parameter names don't matter
+		"func (*T) P() {}", "func (T) P() {}",
+		"func (*T) Q() {}", "",
+		"X int\n", "X int\n\tY int\n",
+		"A int\n", "A int\n\tB int\n",
+		"M(int) error\n", "M(int) error\n\tN()\n",
+		"m()\n", "m()\n\tN()\n",
+		"func internal() {}", "func G() {}",
+	).Replace(oldSrc)
+
+	var got []string
+	for _, c := range compareAPIs(apiOf(t, oldSrc), apiOf(t, newSrc)) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"incompatible: pkg p, const C: changed from untyped integer = 42 to untyped integer = 43",
+		"compatible: pkg p, func G: added func()",
+		"compatible: pkg p, method (*T) P: removed func() (receiver changed from pointer to value)",
+		"incompatible: pkg p, method (*T) Q: removed func()",
+		"compatible: pkg p, type J interface, method N: added func()",
+		"compatible: pkg p, type S struct, field B: added int",
+		"incompatible: pkg p, type I interface, method N: added func() (breaks implementations of the interface)",
+		"incompatible: pkg p, type T struct, field Y: added int (breaks unkeyed composite literals)",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d changes:\n%s\nwant %d:\n%s", len(got), strings.Join(got, "\n"), len(want), strings.Join(want, "\n"))
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if g == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing change: %s\ngot:\n%s", w, strings.Join(got, "\n"))
+		}
+	}
+}
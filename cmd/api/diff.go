@@ -0,0 +1,128 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the comparison of APIs.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A change describes a difference between two APIs.
+type change struct {
+	key        string // feature key
+	old, new   string // old and new feature descriptions; "" if the feature doesn't exist
+	compatible bool
+	reason     string // explanation, for some changes
+}
+
+func (c *change) String() string {
+	var what string
+	switch {
+	case c.old == "":
+		what = "added " + c.new
+	case c.new == "":
+		what = "removed " + c.old
+	default:
+		what = fmt.Sprintf("changed from %s to %s", c.old, c.new)
+	}
+	if c.reason != "" {
+		what += " (" + c.reason + ")"
+	}
+	kind := "incompatible"
+	if c.compatible {
+		kind = "compatible"
+	}
+	return fmt.Sprintf("%s: %s%s%s", kind, c.key, featureSep, what)
+}
+
+// compareAPIs returns the changes from the old to the new API,
+// sorted by feature key.
+func compareAPIs(old, new API) []*change {
+	var changes []*change
+	for key, desc := range old {
+		ndesc, found := new[key]
+		switch {
+		case !found:
+			c := &change{key: key, old: desc}
+			if alt, ok := methodValueReceiver(key); ok && new[alt] == desc {
+				// the method set of T grew
+				c.compatible = true
+				c.reason = "receiver changed from pointer to value"
+			}
+			changes = append(changes, c)
+		case desc != ndesc:
+			changes = append(changes, &change{key: key, old: desc, new: ndesc})
+		}
+	}
+
+	for key, desc := range new {
+		if _, found := old[key]; found {
+			continue
+		}
+		c := &change{key: key, new: desc, compatible: true}
+		if alt, ok := methodPointerReceiver(key); ok && old[alt] == desc {
+			continue // reported with the removed method
+		}
+		if typ, kind := enclosingType(key); typ != "" {
+			// A member of an existing type was added. (If the type is new,
+			// it doesn't matter.)
+			switch otyp, found := old[typ]; {
+			case !found:
+				// new type
+			case kind == "interface" && otyp == ifaceDesc:
+				c.compatible = false
+				c.reason = "breaks implementations of the interface"
+			case kind == "struct" && otyp == structDesc:
+				c.compatible = false
+				c.reason = "breaks unkeyed composite literals"
+			}
+		}
+		changes = append(changes, c)
+	}
+
+	sort.Sort(byKey(changes))
+	return changes
+}
+
+type byKey []*change
+
+func (s byKey) Len() int           { return len(s) }
+func (s byKey) Less(i, j int) bool { return s[i].key < s[j].key }
+func (s byKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// enclosingType returns the key of the struct or interface type of the
+// field or interface method feature with the given key, and the kind of
+// the type ("struct" or "interface"). If key is not such a feature, the
+// result is "".
+func enclosingType(key string) (typ, kind string) {
+	for _, sep := range []string{structFeature, structEmbeddedFeature, interfaceMethodFeature} {
+		if i := strings.Index(key, sep); i >= 0 {
+			kind = strings.TrimSpace(sep[:strings.Index(sep, ",")])
+			return key[:i], kind
+		}
+	}
+	return "", ""
+}
+
+// methodValueReceiver returns the key of the method feature with receiver T,
+// if key is the key of a method feature with receiver *T.
+func methodValueReceiver(key string) (string, bool) {
+	if i := strings.Index(key, ", method (*"); i >= 0 {
+		return key[:i] + ", method (" + key[i+len(", method (*"):], true
+	}
+	return "", false
+}
+
+// methodPointerReceiver returns the key of the method feature with receiver *T,
+// if key is the key of a method feature with receiver T.
+func methodPointerReceiver(key string) (string, bool) {
+	if i := strings.Index(key, ", method ("); i >= 0 && !strings.HasPrefix(key[i:], ", method (*") {
+		return key[:i] + ", method (*" + key[i+len(", method ("):], true
+	}
+	return "", false
+}
@@ -0,0 +1,60 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+The api command computes the exported API of Go packages and compares
+API descriptions, classifying the differences as compatible or
+incompatible changes.
+
+Usage:
+	api [flags] [path ...]
+
+Without the -c or -diff flag, api prints the API of the packages with
+the given import paths, in canonical form: one sorted line per feature,
+of the form
+
+	pkg <path>, <feature>: <description>
+
+for instance
+
+	pkg io, func Copy: func(io.Writer, io.Reader) (int64, error)
+	pkg io, type Reader interface, method Read: func([]uint8) (int, error)
+
+Features are package-level constants, variables, functions, and types,
+methods, exported struct fields, and interface methods. Parameter names
+are not part of the API.
+
+The flags are:
+	-c file
+		Compare the API of the packages with the API description in
+		file (as printed by api) and report the differences.
+	-diff
+		Compare two API description files, given as arguments.
+	-src
+		Type-check the packages (and their dependencies) from source
+		rather than reading gc export data. Files importing "C" are
+		processed with cgo.
+
+When comparing, api prints one line per change and exits with status 1
+if any change is incompatible. The following changes are compatible:
+added features (except as noted below), and changing the receiver of
+a method from *T to T, which grows the method set of T. All other
+changes are incompatible, notably removed features, changed types or
+values, new methods in interfaces without unexported methods (which
+breaks implementations of the interface), and new fields in structs
+without unexported fields (which breaks unkeyed composite literals of
+the struct).
+
+Examples
+
+To record the API of package io:
+
+	api io > io.api
+
+To check that the current package io is compatible with the recorded API:
+
+	api -c io.api io
+
+*/
+package main
@@ -0,0 +1,112 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Api computes and compares the exported API of Go packages.
+// See doc.go for more information.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+
+	"code.google.com/p/go.tools/go/loader"
+	"code.google.com/p/go.tools/go/types"
+)
+
+var (
+	compareFile = flag.String("c", "", "compare the API of the packages with the API description in file")
+	diffMode    = flag.Bool("diff", false, "compare two API description files")
+	fromSource  = flag.Bool("src", false, "type-check packages from source instead of reading export data")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: api [flags] [path ...]\n")
+	fmt.Fprintf(os.Stderr, "       api -c file [flags] [path ...]\n")
+	fmt.Fprintf(os.Stderr, "       api -diff old new\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "api: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *diffMode {
+		if flag.NArg() != 2 {
+			usage()
+		}
+		report(compareAPIs(readAPIFile(flag.Arg(0)), readAPIFile(flag.Arg(1))))
+		return
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	api := make(API)
+	imports := make(map[string]*types.Package)
+	for _, path := range flag.Args() {
+		pkg, err := importPackage(imports, path)
+		if err != nil {
+			fatalf("%s", err)
+		}
+		for key, desc := range packageAPI(pkg) {
+			api[key] = desc
+		}
+	}
+
+	if *compareFile != "" {
+		report(compareAPIs(readAPIFile(*compareFile), api))
+		return
+	}
+
+	if err := api.write(os.Stdout); err != nil {
+		fatalf("%s", err)
+	}
+}
+
+// report prints the changes and exits with status 1
+// if any of them is incompatible.
+func report(changes []*change) {
+	exitCode := 0
+	for _, c := range changes {
+		fmt.Println(c)
+		if !c.compatible {
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func readAPIFile(filename string) API {
+	f, err := os.Open(filename)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	defer f.Close()
+	api, err := readAPI(f)
+	if err != nil {
+		fatalf("%s: %s", filename, err)
+	}
+	return api
+}
+
+// importPackage imports the package with the given path,
+// from export data or, if -src is set, from source.
+func importPackage(imports map[string]*types.Package, path string) (*types.Package, error) {
+	if *fromSource {
+		return srcImporter.Import(imports, path)
+	}
+	return types.GcImport(imports, path)
+}
+
+// srcImporter type-checks packages and their dependencies from source.
+var srcImporter = loader.NewSourceImporter(token.NewFileSet(), nil)
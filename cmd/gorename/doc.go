@@ -0,0 +1,45 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+The gorename command renames a Go entity (a constant, type, variable,
+function, method, or struct field) and updates all references to it.
+
+Usage:
+	gorename -to name (-from qualified-name | -offset file:#offset) [-w] path ...
+
+The packages with the given import paths are type-checked from source;
+all references to the entity in these packages are renamed. Other
+dependencies are imported from gc export data.
+
+The entity is specified either by its qualified name, e.g.
+
+	-from example.com/p.Buffer      (package-level object)
+	-from example.com/p.Buffer.Len  (method or field of a named type)
+
+or by the byte offset of an identifier denoting it, e.g.
+
+	-offset src/example.com/p/buffer.go:#1234
+
+which is needed for local objects. Gorename uses type information to
+find the references, so objects that merely have the same name are
+not affected. It refuses to rename if the result would not be valid
+or would change the meaning of the program; for instance, if the new
+name would shadow or be shadowed by another object, if a type would
+no longer implement an interface, or if an exported object used in
+other packages would become unexported.
+
+The flags are:
+	-to name
+		The new name.
+	-from name
+		The qualified name of the entity to rename.
+	-offset file:#offset
+		The position of an identifier denoting the entity.
+	-w
+		Write the changed files. By default, they are printed to
+		standard output.
+
+*/
+package main
@@ -0,0 +1,178 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Gorename renames Go entities in a type-safe way.
+// See doc.go for more information.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/rename"
+)
+
+var (
+	to     = flag.String("to", "", "new name")
+	from   = flag.String("from", "", "qualified name of the entity to rename")
+	offset = flag.String("offset", "", "position (file:#offset) of an identifier denoting the entity")
+	write  = flag.Bool("w", false, "write changed files instead of printing them")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gorename -to name (-from qualified-name | -offset file:#offset) [-w] path ...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "gorename: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *to == "" || (*from == "") == (*offset == "") || flag.NArg() == 0 {
+		usage()
+	}
+
+	fset := token.NewFileSet()
+	prog := rename.NewProgram(fset, nil)
+	if err := load(prog, flag.Args()); err != nil {
+		fatalf("%s", err)
+	}
+
+	var obj types.Object
+	if *from != "" {
+		obj = prog.Lookup(*from)
+		if obj == nil {
+			fatalf("%s not found", *from)
+		}
+	} else {
+		pos, err := parseOffset(prog, *offset)
+		if err != nil {
+			fatalf("%s", err)
+		}
+		obj = prog.ObjectAt(pos)
+		if obj == nil {
+			fatalf("no identifier at %s", *offset)
+		}
+	}
+
+	files, err := prog.Rename(obj, *to)
+	if err != nil {
+		if list, ok := err.(rename.ConflictList); ok {
+			for _, c := range list {
+				fmt.Fprintln(os.Stderr, c)
+			}
+			os.Exit(1)
+		}
+		fatalf("%s", err)
+	}
+
+	for _, file := range files {
+		filename := fset.Position(file.Pos()).Filename
+		var buf bytes.Buffer
+		if err := config.Fprint(&buf, fset, file); err != nil {
+			fatalf("%s: %s", filename, err)
+		}
+		if *write {
+			if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+				fatalf("%s", err)
+			}
+			continue
+		}
+		fmt.Printf("// %s\n", filename)
+		os.Stdout.Write(buf.Bytes())
+	}
+}
+
+// config is the printer configuration used by gofmt.
+var config = printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+
+// load adds the packages with the given import paths to prog.
+// Packages are added after the packages they import.
+func load(prog *rename.Program, paths []string) error {
+	bps := make(map[string]*build.Package)
+	for _, path := range paths {
+		bp, err := build.Import(path, "", 0)
+		if err != nil {
+			return err
+		}
+		bps[path] = bp
+	}
+
+	added := make(map[string]bool)
+	var add func(path string) error
+	add = func(path string) error {
+		bp := bps[path]
+		if bp == nil || added[path] {
+			return nil
+		}
+		added[path] = true
+		for _, imp := range bp.Imports {
+			if err := add(imp); err != nil {
+				return err
+			}
+		}
+		var files []*ast.File
+		for _, filename := range bp.GoFiles {
+			file, err := parser.ParseFile(prog.Fset, filepath.Join(bp.Dir, filename), nil, parser.ParseComments)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+		return prog.Add(path, files...)
+	}
+
+	for _, path := range paths {
+		if err := add(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseOffset returns the position denoted by a file:#offset string.
+func parseOffset(prog *rename.Program, s string) (token.Pos, error) {
+	i := strings.LastIndex(s, ":#")
+	if i < 0 {
+		return token.NoPos, fmt.Errorf("invalid offset %q (want file:#offset)", s)
+	}
+	offs, err := strconv.Atoi(s[i+2:])
+	if err != nil {
+		return token.NoPos, fmt.Errorf("invalid offset %q: %s", s, err)
+	}
+	filename, err := filepath.Abs(s[:i])
+	if err != nil {
+		return token.NoPos, err
+	}
+	for _, p := range prog.Packages {
+		for _, file := range p.Files {
+			f := prog.Fset.File(file.Pos())
+			if name, _ := filepath.Abs(f.Name()); name == filename {
+				if offs < 0 || offs > f.Size() {
+					return token.NoPos, fmt.Errorf("offset %d out of range for %s", offs, f.Name())
+				}
+				return f.Pos(offs), nil
+			}
+		}
+	}
+	return token.NoPos, fmt.Errorf("file %s not in any of the packages", s[:i])
+}
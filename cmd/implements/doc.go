@@ -0,0 +1,52 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+The implements command reports which named types implement which named
+interfaces in a set of Go packages.
+
+Usage:
+	implements [flags] path ...
+
+The packages with the given import paths are type-checked from source.
+Their dependencies are imported from gc export data, or type-checked
+from source as well if the -src flag is set. Files importing "C" are
+processed with cgo before type-checking. Implements then considers
+every named non-interface type T declared at package level in the
+packages, and every named non-empty interface declared in the packages
+or any package they depend on, exported or not. T implements an
+interface if its method set does; if only the method set of *T does,
+*T is reported instead.
+
+The output lists, for each interface, the types implementing it, and,
+for each type, the interfaces it implements:
+
+	interface io.Reader
+		*example.com/p.File
+		example.com/p.Buffer
+	type *example.com/p.File
+		io.Closer
+		io.Reader
+
+Types and interfaces are qualified with their package path.
+
+The flags are:
+	-iface name
+		Only report the types implementing the interface with the
+		given qualified name, e.g. io.Reader.
+	-type name
+		Only report the interfaces implemented by the type with the
+		given qualified name (without *).
+	-src
+		Type-check the dependencies of the packages from source
+		rather than reading gc export data.
+
+Examples
+
+To find the types in package example.com/p implementing io.Reader:
+
+	implements -iface io.Reader example.com/p
+
+*/
+package main
@@ -0,0 +1,109 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the computation of the implements relation.
+
+package main
+
+import (
+	"sort"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+// A relation records that typ (a named type T or a pointer *T)
+// implements the named interface iface.
+type relation struct {
+	typ   types.Type
+	iface *types.Named
+}
+
+// implements returns the implements relation between the named
+// non-interface types declared at package level in pkgs and the
+// non-empty named interfaces declared in pkgs or any package they
+// (transitively) import. For each type T, the relation contains T if
+// T implements an interface, and *T if only *T implements it. The
+// result is sorted by interface and type name.
+//
+func implements(pkgs []*types.Package) []relation {
+	local := make(map[*types.Package]bool)
+	for _, pkg := range pkgs {
+		local[pkg] = true
+	}
+
+	// collect named types
+	var named, ifaces []*types.Named
+	seen := make(map[*types.Package]bool)
+	var collect func(pkg *types.Package)
+	collect = func(pkg *types.Package) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		for _, obj := range pkg.Scope().Entries {
+			obj, _ := obj.(*types.TypeName)
+			if obj == nil {
+				continue
+			}
+			t, _ := obj.Type().(*types.Named)
+			if t == nil {
+				continue
+			}
+			if iface, ok := t.Underlying().(*types.Interface); ok {
+				if !iface.IsEmpty() {
+					ifaces = append(ifaces, t)
+				}
+			} else if local[pkg] {
+				named = append(named, t)
+			}
+		}
+		for _, imp := range pkg.Imports() {
+			collect(imp)
+		}
+	}
+	for _, pkg := range pkgs {
+		collect(pkg)
+	}
+
+	var rels []relation
+	for _, iface := range ifaces {
+		it := iface.Underlying().(*types.Interface)
+		for _, t := range named {
+			switch {
+			case types.Implements(t, it):
+				rels = append(rels, relation{t, iface})
+			case types.Implements(types.NewPointer(t), it):
+				rels = append(rels, relation{types.NewPointer(t), iface})
+			}
+		}
+	}
+	sort.Sort(byName(rels))
+	return rels
+}
+
+type byName []relation
+
+func (s byName) Len() int { return len(s) }
+func (s byName) Less(i, j int) bool {
+	if x, y := qualifiedName(s[i].iface), qualifiedName(s[j].iface); x != y {
+		return x < y
+	}
+	return qualifiedName(s[i].typ) < qualifiedName(s[j].typ)
+}
+func (s byName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// qualifiedName returns the name of the named type or pointer to a named
+// type typ, qualified with its package path, e.g. "*example.com/p.T".
+func qualifiedName(typ types.Type) string {
+	prefix := ""
+	if p, ok := typ.(*types.Pointer); ok {
+		prefix = "*"
+		typ = p.Elem()
+	}
+	obj := typ.(*types.Named).Obj()
+	if pkg := obj.Pkg(); pkg != nil {
+		prefix += pkg.Path() + "."
+	}
+	return prefix + obj.Name()
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+const src = `package p
+
+type Reader interface {
+	Read() int
+}
+
+type closer interface {
+	Close()
+}
+
+type ReadCloser interface {
+	Reader
+	closer
+}
+
+type Empty interface{}
+
+type file struct{}
+
+func (*file) Read() int { return 0 }
+func (*file) Close()    {}
+
+type Buffer []byte
+
+func (Buffer) Read() int { return 0 }
+
+type wrapper struct{ *file }
+
+type value struct{ file }
+
+type Int int
+`
+
+func TestImplements(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := types.Check("p", fset, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels := implements([]*types.Package{pkg})
+
+	for _, test := range []struct {
+		iface, typ string
+		want       string
+	}{
+		{"", "", `interface p.ReadCloser
+	*p.file
+	*p.value
+	p.wrapper
+interface p.Reader
+	*p.file
+	*p.value
+	p.Buffer
+	p.wrapper
+interface p.closer
+	*p.file
+	*p.value
+	p.wrapper
+type *p.file
+	p.ReadCloser
+	p.Reader
+	p.closer
+type *p.value
+	p.ReadCloser
+	p.Reader
+	p.closer
+type p.Buffer
+	p.Reader
+type p.wrapper
+	p.ReadCloser
+	p.Reader
+	p.closer
+`},
+		{"p.Reader", "", `interface p.Reader
+	*p.file
+	*p.value
+	p.Buffer
+	p.wrapper
+`},
+		{"", "p.file", `type *p.file
+	p.ReadCloser
+	p.Reader
+	p.closer
+`},
+		{"", "p.Int", ""},
+	} {
+		var buf bytes.Buffer
+		report(&buf, rels, test.iface, test.typ)
+		if got := buf.String(); got != test.want {
+			t.Errorf("-iface=%q -type=%q: got\n%s\nwant\n%s", test.iface, test.typ, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Implements reports which types implement which interfaces.
+// See doc.go for more information.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+
+	"code.google.com/p/go.tools/go/loader"
+	"code.google.com/p/go.tools/go/types"
+)
+
+var (
+	ifaceName  = flag.String("iface", "", "only report the types implementing the named interface")
+	typeName   = flag.String("type", "", "only report the interfaces implemented by the named type")
+	fromSource = flag.Bool("src", false, "type-check dependencies from source instead of reading export data")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: implements [flags] path ...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "implements: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	// The packages are type-checked from source, and so are their
+	// dependencies if -src is set.
+	imp := loader.NewSourceImporter(token.NewFileSet(), nil)
+	if !*fromSource {
+		imp.Deps = types.GcImport
+	}
+
+	var pkgs []*types.Package
+	imports := make(map[string]*types.Package)
+	for _, path := range flag.Args() {
+		pkg, err := imp.Import(imports, path)
+		if err != nil {
+			fatalf("%s", err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	report(os.Stdout, implements(pkgs), *ifaceName, *typeName)
+}
+
+// report prints the relations rels, grouped by interface and by type.
+// If iface or typ is set, only the types implementing the interface
+// with that name, or the interfaces implemented by the type with that
+// name are printed.
+//
+func report(w io.Writer, rels []relation, iface, typ string) {
+	if typ == "" {
+		cur := ""
+		for _, r := range rels {
+			name := qualifiedName(r.iface)
+			if iface != "" && name != iface {
+				continue
+			}
+			if name != cur {
+				fmt.Fprintf(w, "interface %s\n", name)
+				cur = name
+			}
+			fmt.Fprintf(w, "\t%s\n", qualifiedName(r.typ))
+		}
+	}
+
+	if iface == "" {
+		byType := make(map[string][]string)
+		var names []string
+		for _, r := range rels {
+			name := qualifiedName(r.typ)
+			if typ != "" && name != typ && name != "*"+typ {
+				continue
+			}
+			if byType[name] == nil {
+				names = append(names, name)
+			}
+			byType[name] = append(byType[name], qualifiedName(r.iface))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "type %s\n", name)
+			for _, iface := range byType[name] {
+				fmt.Fprintf(w, "\t%s\n", iface)
+			}
+		}
+	}
+}
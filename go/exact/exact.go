@@ -8,8 +8,10 @@
 package exact
 
 import (
+	"errors"
 	"fmt"
 	"go/token"
+	"math"
 	"math/big"
 	"strconv"
 )
@@ -54,15 +56,32 @@ type Value interface {
 // ----------------------------------------------------------------------------
 // Implementations
 
+// Float values are represented exactly as rationals (ratVal) as long as
+// numerator and denominator remain reasonably small. Values that would
+// require larger rationals (e.g., after repeated division, or for large
+// exponents) are represented as arbitrary-precision floating-point numbers
+// (floatVal) with a fixed mantissa size. This bounds the cost of constant
+// arithmetic for pathological expressions while keeping the results for
+// common constants exact.
+
+// prec is the mantissa precision in bits of floatVal values.
+const prec = 512
+
+// maxExp is the maximum bit length of the numerator or denominator
+// of a ratVal, and the maximum binary exponent of a floatVal value
+// that may be converted back into a ratVal or an Int.
+const maxExp = 4 << 10
+
 type (
 	unknownVal struct{}
 	nilVal     struct{}
 	boolVal    bool
 	stringVal  string
-	int64Val   int64
-	intVal     struct{ val *big.Int }
-	floatVal   struct{ val *big.Rat }
-	complexVal struct{ re, im *big.Rat }
+	int64Val   int64                    // Int values representable as an int64
+	intVal     struct{ val *big.Int }   // Int values not representable as an int64
+	ratVal     struct{ val *big.Rat }   // Float values representable as a (small) fraction
+	floatVal   struct{ val *big.Float } // Float values not representable as a (small) fraction
+	complexVal struct{ re, im Value }   // re and im are Int or Float values
 )
 
 func (unknownVal) Kind() Kind { return Unknown }
@@ -71,6 +90,7 @@ func (boolVal) Kind() Kind    { return Bool }
 func (stringVal) Kind() Kind  { return String }
 func (int64Val) Kind() Kind   { return Int }
 func (intVal) Kind() Kind     { return Int }
+func (ratVal) Kind() Kind     { return Float }
 func (floatVal) Kind() Kind   { return Float }
 func (complexVal) Kind() Kind { return Complex }
 
@@ -80,7 +100,8 @@ func (x boolVal) String() string    { return fmt.Sprintf("%v", bool(x)) }
 func (x stringVal) String() string  { return strconv.Quote(string(x)) }
 func (x int64Val) String() string   { return strconv.FormatInt(int64(x), 10) }
 func (x intVal) String() string     { return x.val.String() }
-func (x floatVal) String() string   { return x.val.String() }
+func (x ratVal) String() string     { return x.val.String() }
+func (x floatVal) String() string   { return x.val.Text('g', 10) }
 func (x complexVal) String() string { return fmt.Sprintf("(%s + %si)", x.re, x.im) }
 
 func (unknownVal) implementsValue() {}
@@ -89,6 +110,7 @@ func (boolVal) implementsValue()    {}
 func (stringVal) implementsValue()  {}
 func (int64Val) implementsValue()   {}
 func (intVal) implementsValue()     {}
+func (ratVal) implementsValue()     {}
 func (floatVal) implementsValue()   {}
 func (complexVal) implementsValue() {}
 
@@ -98,6 +120,8 @@ var (
 	maxInt64 = big.NewInt(1<<63 - 1)
 )
 
+func newFloat() *big.Float { return new(big.Float).SetPrec(prec) }
+
 func normInt(x *big.Int) Value {
 	if minInt64.Cmp(x) <= 0 && x.Cmp(maxInt64) <= 0 {
 		return int64Val(x.Int64())
@@ -105,20 +129,61 @@ func normInt(x *big.Int) Value {
 	return intVal{x}
 }
 
-func normFloat(x *big.Rat) Value {
+// smallRat reports whether x can be kept as a ratVal.
+func smallRat(x *big.Rat) bool {
+	return x.Num().BitLen() < maxExp && x.Denom().BitLen() < maxExp
+}
+
+// smallFloat reports whether x is small enough in magnitude
+// to be converted into a ratVal or an Int without excessive
+// growth.
+func smallFloat(x *big.Float) bool {
+	if x.IsInf() {
+		return false
+	}
+	e := x.MantExp(nil)
+	return -maxExp < e && e < maxExp
+}
+
+func normRat(x *big.Rat) Value {
 	if x.IsInt() {
 		return normInt(x.Num())
 	}
+	if smallRat(x) {
+		return ratVal{x}
+	}
+	return floatVal{newFloat().SetRat(x)}
+}
+
+func normFloat(x *big.Float) Value {
+	if x.IsInf() {
+		return unknownVal{}
+	}
+	if x.IsInt() && smallFloat(x) {
+		i, _ := x.Int(nil)
+		return normInt(i)
+	}
 	return floatVal{x}
 }
 
-func normComplex(re, im *big.Rat) Value {
-	if im.Sign() == 0 {
-		return normFloat(re)
+func normComplex(re, im Value) Value {
+	if Sign(im) == 0 {
+		return re
 	}
 	return complexVal{re, im}
 }
 
+// Conversions between representations. All conversions are exact
+// except for those to floatVal, which may be rounded to prec bits.
+
+func i64toi(x int64Val) intVal   { return intVal{big.NewInt(int64(x))} }
+func i64tor(x int64Val) ratVal   { return ratVal{big.NewRat(int64(x), 1)} }
+func i64tof(x int64Val) floatVal { return floatVal{newFloat().SetInt64(int64(x))} }
+func itor(x intVal) ratVal       { return ratVal{new(big.Rat).SetInt(x.val)} }
+func itof(x intVal) floatVal     { return floatVal{newFloat().SetInt(x.val)} }
+func rtof(x ratVal) floatVal     { return floatVal{newFloat().SetRat(x.val)} }
+func vtoc(x Value) complexVal    { return complexVal{x, int64Val(0)} }
+
 // ----------------------------------------------------------------------------
 // Factories
 
@@ -143,11 +208,10 @@ func MakeUint64(x uint64) Value { return normInt(new(big.Int).SetUint64(x)) }
 // MakeFloat64 returns the numeric value for x.
 // If x is not finite, the result is unknown.
 func MakeFloat64(x float64) Value {
-	f := new(big.Rat).SetFloat64(x)
-	if f != nil {
-		return normFloat(f)
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return unknownVal{}
 	}
-	return unknownVal{}
+	return normRat(new(big.Rat).SetFloat64(x))
 }
 
 // MakeFromLiteral returns the corresponding literal value.
@@ -163,14 +227,14 @@ func MakeFromLiteral(lit string, tok token.Token) Value {
 		}
 
 	case token.FLOAT:
-		if x, ok := new(big.Rat).SetString(lit); ok {
-			return normFloat(x)
+		if x := makeFloatFromLiteral(lit); x != nil {
+			return x
 		}
 
 	case token.IMAG:
 		if n := len(lit); n > 0 && lit[n-1] == 'i' {
-			if im, ok := new(big.Rat).SetString(lit[0 : n-1]); ok {
-				return normComplex(big.NewRat(0, 1), im)
+			if im := makeFloatFromLiteral(lit[0 : n-1]); im != nil {
+				return normComplex(int64Val(0), im)
 			}
 		}
 
@@ -191,6 +255,32 @@ func MakeFromLiteral(lit string, tok token.Token) Value {
 	return nil
 }
 
+// makeFloatFromLiteral returns the numeric value for the floating-point
+// literal lit, or nil if lit has illegal format. Literals with very large
+// or very small exponents are not converted into fractions (whose size
+// would be proportional to the exponent) but rounded to prec bits.
+func makeFloatFromLiteral(lit string) Value {
+	f, ok := newFloat().SetString(lit)
+	if !ok {
+		// big.Rat also accepts fractions of the form "a/b".
+		if r, ok := new(big.Rat).SetString(lit); ok {
+			return normRat(r)
+		}
+		return nil
+	}
+	if smallFloat(f) {
+		// Convert into an exact fraction if possible.
+		if f.Sign() == 0 {
+			// Note: big.Float has a signed zero; use int64Val instead.
+			return int64Val(0)
+		}
+		if r, ok := new(big.Rat).SetString(lit); ok {
+			return normRat(r)
+		}
+	}
+	return normFloat(f)
+}
+
 // ----------------------------------------------------------------------------
 // Accessors
 
@@ -233,8 +323,11 @@ func Float64Val(x Value) (float64, bool) {
 		return f, int64Val(f) == x
 	case intVal:
 		return new(big.Rat).SetFrac(x.val, int1).Float64()
-	case floatVal:
+	case ratVal:
 		return x.val.Float64()
+	case floatVal:
+		f, acc := x.val.Float64()
+		return f, acc == big.Exact
 	}
 	panic(fmt.Sprintf("invalid Float64Val(%v)", x))
 }
@@ -272,10 +365,12 @@ func Sign(x Value) int {
 		return 0
 	case intVal:
 		return x.val.Sign()
+	case ratVal:
+		return x.val.Sign()
 	case floatVal:
 		return x.val.Sign()
 	case complexVal:
-		return x.re.Sign() | x.im.Sign()
+		return Sign(x.re) | Sign(x.im)
 	}
 	panic(fmt.Sprintf("invalid Sign(%v)", x))
 }
@@ -287,27 +382,20 @@ func Sign(x Value) int {
 // x must be numeric but not Complex.
 // If x is unknown, the result is unknown.
 func MakeImag(x Value) Value {
-	var im *big.Rat
-	switch x := x.(type) {
+	switch x.(type) {
 	case unknownVal:
 		return x
-	case int64Val:
-		im = big.NewRat(int64(x), 1)
-	case intVal:
-		im = new(big.Rat).SetFrac(x.val, int1)
-	case floatVal:
-		im = x.val
-	default:
-		panic(fmt.Sprintf("invalid MakeImag(%v)", x))
+	case int64Val, intVal, ratVal, floatVal:
+		return normComplex(int64Val(0), x)
 	}
-	return normComplex(rat0, im)
+	panic(fmt.Sprintf("invalid MakeImag(%v)", x))
 }
 
 // Real returns the real part of x, which must be a numeric value.
 // If x is unknown, the result is unknown.
 func Real(x Value) Value {
 	if z, ok := x.(complexVal); ok {
-		return normFloat(z.re)
+		return z.re
 	}
 	return x
 }
@@ -316,11 +404,158 @@ func Real(x Value) Value {
 // If x is unknown, the result is 0.
 func Imag(x Value) Value {
 	if z, ok := x.(complexVal); ok {
-		return normFloat(z.im)
+		return z.im
 	}
 	return int64Val(0)
 }
 
+// ----------------------------------------------------------------------------
+// Conversions
+
+// Values are always represented in the smallest Kind that represents
+// them exactly. Consequently, the conversion functions below don't
+// change the Kind of a value to a "larger" Kind; instead they report
+// whether the value is representable in the requested Kind at all.
+
+// ToInt returns x as an Int value if x is representable as an Int;
+// that is, if x is numeric with integral value and zero imaginary part.
+// Otherwise it returns Unknown.
+func ToInt(x Value) Value {
+	switch x := x.(type) {
+	case int64Val, intVal:
+		return x
+	case floatVal:
+		// Only integral floatVals too large to be normalized to Int
+		// values exist; convert them explicitly.
+		if x.val.IsInt() {
+			i, _ := x.val.Int(nil)
+			return normInt(i)
+		}
+	case complexVal:
+		if Sign(x.im) == 0 {
+			return ToInt(x.re)
+		}
+	}
+	return unknownVal{}
+}
+
+// ToFloat returns x if x is representable as a Float; that is,
+// if x is an Int or Float value. Otherwise it returns Unknown.
+func ToFloat(x Value) Value {
+	switch x := x.(type) {
+	case int64Val, intVal, ratVal, floatVal:
+		return x
+	case complexVal:
+		if Sign(x.im) == 0 {
+			return ToFloat(x.re)
+		}
+	}
+	return unknownVal{}
+}
+
+// ToComplex returns x if x is representable as a Complex;
+// that is, if x is numeric. Otherwise it returns Unknown.
+func ToComplex(x Value) Value {
+	switch x := x.(type) {
+	case int64Val, intVal, ratVal, floatVal, complexVal:
+		return x
+	}
+	return unknownVal{}
+}
+
+// Float32Val is like Float64Val but for float32 instead of float64.
+func Float32Val(x Value) (float32, bool) {
+	switch x := x.(type) {
+	case int64Val:
+		f := float32(x)
+		return f, int64Val(f) == x
+	case intVal:
+		return new(big.Rat).SetFrac(x.val, int1).Float32()
+	case ratVal:
+		return x.val.Float32()
+	case floatVal:
+		f, acc := x.val.Float32()
+		return f, acc == big.Exact
+	}
+	panic(fmt.Sprintf("invalid Float32Val(%v)", x))
+}
+
+// RoundFloat32 returns x rounded to the nearest float32 value;
+// x must be numeric. The real and imaginary parts of Complex
+// values are rounded separately. If x is unknown, or if the
+// rounded value overflows float32, the result is unknown.
+func RoundFloat32(x Value) Value { return round(x, 32) }
+
+// RoundFloat64 is like RoundFloat32 but for float64 instead of float32.
+func RoundFloat64(x Value) Value { return round(x, 64) }
+
+func round(x Value, bits int) Value {
+	switch x := x.(type) {
+	case unknownVal:
+		return x
+	case int64Val, intVal, ratVal, floatVal:
+		var f float64
+		if bits == 32 {
+			f32, _ := Float32Val(x)
+			f = float64(f32)
+		} else {
+			f, _ = Float64Val(x)
+		}
+		return MakeFloat64(f) // unknown if f is infinite
+	case complexVal:
+		re := round(x.re, bits)
+		im := round(x.im, bits)
+		if re.Kind() == Unknown || im.Kind() == Unknown {
+			return unknownVal{}
+		}
+		return normComplex(re, im)
+	}
+	panic(fmt.Sprintf("invalid round(%v)", x))
+}
+
+// ConvertInt returns the value of x converted to a sized integer of
+// the given size in bytes and signedness, and whether x is representable
+// in that integer type. x must be an Int, and size must be > 0. If x is
+// not representable, the result is x truncated to the size and signedness,
+// as it would be by a non-constant Go conversion. If x is unknown, the
+// result is unknown and representable.
+func ConvertInt(x Value, size int, signed bool) (Value, bool) {
+	var z *big.Int
+	switch x := x.(type) {
+	case unknownVal:
+		return x, true
+	case int64Val:
+		if size >= 8 && (signed || x >= 0) {
+			return x, true // common case
+		}
+		z = big.NewInt(int64(x))
+	case intVal:
+		z = new(big.Int).Set(x.val)
+	default:
+		panic(fmt.Sprintf("invalid ConvertInt(%v)", x))
+	}
+
+	s := uint(size) * 8
+	var ok bool
+	if signed {
+		ok = z.BitLen() < int(s) || z.Sign() < 0 && z.BitLen() == int(s) && z.TrailingZeroBits() == s-1
+	} else {
+		ok = z.Sign() >= 0 && z.BitLen() <= int(s)
+	}
+	if ok {
+		return normInt(z), true
+	}
+
+	// truncate: z &= 1<<s - 1
+	mask := new(big.Int).Lsh(int1, s)
+	z.And(z, mask.Sub(mask, int1))
+	if signed && z.Bit(int(s)-1) != 0 {
+		// sign-extend: z -= 1<<s
+		z.Sub(z, new(big.Int).Lsh(int1, s))
+	}
+	return normInt(z), false
+}
+
 // ----------------------------------------------------------------------------
 // Operations
 
@@ -344,7 +579,7 @@ func UnaryOp(op token.Token, y Value, size int) Value {
 	switch op {
 	case token.ADD:
 		switch y.(type) {
-		case unknownVal, int64Val, intVal, floatVal, complexVal:
+		case unknownVal, int64Val, intVal, ratVal, floatVal, complexVal:
 			return y
 		}
 
@@ -357,10 +592,12 @@ func UnaryOp(op token.Token, y Value, size int) Value {
 			return normInt(new(big.Int).Neg(big.NewInt(int64(y))))
 		case intVal:
 			return normInt(new(big.Int).Neg(y.val))
+		case ratVal:
+			return normRat(new(big.Rat).Neg(y.val))
 		case floatVal:
-			return normFloat(new(big.Rat).Neg(y.val))
+			return normFloat(newFloat().Neg(y.val))
 		case complexVal:
-			return normComplex(new(big.Rat).Neg(y.re), new(big.Rat).Neg(y.im))
+			return normComplex(UnaryOp(token.SUB, y.re, -1), UnaryOp(token.SUB, y.im, -1))
 		}
 
 	case token.XOR:
@@ -390,10 +627,7 @@ Error:
 	panic(fmt.Sprintf("invalid unary operation %s%v", op, y))
 }
 
-var (
-	int1 = big.NewInt(1)
-	rat0 = big.NewRat(0, 1)
-)
+var int1 = big.NewInt(1)
 
 func ord(x Value) int {
 	switch x.(type) {
@@ -403,10 +637,12 @@ func ord(x Value) int {
 		return 1
 	case intVal:
 		return 2
-	case floatVal:
+	case ratVal:
 		return 3
-	case complexVal:
+	case floatVal:
 		return 4
+	case complexVal:
+		return 5
 	}
 }
 
@@ -430,21 +666,35 @@ func match(x, y Value) (_, _ Value) {
 		case int64Val:
 			return x, y
 		case intVal:
-			return intVal{big.NewInt(int64(x))}, y
+			return i64toi(x), y
+		case ratVal:
+			return i64tor(x), y
 		case floatVal:
-			return floatVal{big.NewRat(int64(x), 1)}, y
+			return i64tof(x), y
 		case complexVal:
-			return complexVal{big.NewRat(int64(x), 1), rat0}, y
+			return vtoc(x), y
 		}
 
 	case intVal:
 		switch y := y.(type) {
 		case intVal:
 			return x, y
+		case ratVal:
+			return itor(x), y
+		case floatVal:
+			return itof(x), y
+		case complexVal:
+			return vtoc(x), y
+		}
+
+	case ratVal:
+		switch y := y.(type) {
+		case ratVal:
+			return x, y
 		case floatVal:
-			return floatVal{new(big.Rat).SetFrac(x.val, int1)}, y
+			return rtof(x), y
 		case complexVal:
-			return complexVal{new(big.Rat).SetFrac(x.val, int1), rat0}, y
+			return vtoc(x), y
 		}
 
 	case floatVal:
@@ -452,7 +702,7 @@ func match(x, y Value) (_, _ Value) {
 		case floatVal:
 			return x, y
 		case complexVal:
-			return complexVal{x.val, rat0}, y
+			return vtoc(x), y
 		}
 	}
 
@@ -502,7 +752,7 @@ func BinaryOp(x Value, op token.Token, y Value) Value {
 			}
 			c = a * b
 		case token.QUO:
-			return normFloat(new(big.Rat).SetFrac(big.NewInt(a), big.NewInt(b)))
+			return normRat(new(big.Rat).SetFrac(big.NewInt(a), big.NewInt(b)))
 		case token.QUO_ASSIGN: // force integer division
 			c = a / b
 		case token.REM:
@@ -532,7 +782,7 @@ func BinaryOp(x Value, op token.Token, y Value) Value {
 		case token.MUL:
 			c.Mul(a, b)
 		case token.QUO:
-			return normFloat(new(big.Rat).SetFrac(a, b))
+			return normRat(new(big.Rat).SetFrac(a, b))
 		case token.QUO_ASSIGN: // force integer division
 			c.Quo(a, b)
 		case token.REM:
@@ -550,10 +800,28 @@ func BinaryOp(x Value, op token.Token, y Value) Value {
 		}
 		return normInt(&c)
 
+	case ratVal:
+		a := x.val
+		b := y.(ratVal).val
+		c := new(big.Rat)
+		switch op {
+		case token.ADD:
+			c.Add(a, b)
+		case token.SUB:
+			c.Sub(a, b)
+		case token.MUL:
+			c.Mul(a, b)
+		case token.QUO:
+			c.Quo(a, b)
+		default:
+			goto Error
+		}
+		return normRat(c)
+
 	case floatVal:
 		a := x.val
 		b := y.(floatVal).val
-		var c big.Rat
+		c := newFloat()
 		switch op {
 		case token.ADD:
 			c.Add(a, b)
@@ -562,51 +830,52 @@ func BinaryOp(x Value, op token.Token, y Value) Value {
 		case token.MUL:
 			c.Mul(a, b)
 		case token.QUO:
+			if b.Sign() == 0 {
+				panic("division by zero")
+			}
 			c.Quo(a, b)
 		default:
 			goto Error
 		}
-		return normFloat(&c)
+		return normFloat(c)
 
 	case complexVal:
 		y := y.(complexVal)
 		a, b := x.re, x.im
 		c, d := y.re, y.im
-		var re, im big.Rat
+		var re, im Value
 		switch op {
 		case token.ADD:
 			// (a+c) + i(b+d)
-			re.Add(a, c)
-			im.Add(b, d)
+			re = add(a, c)
+			im = add(b, d)
 		case token.SUB:
 			// (a-c) + i(b-d)
-			re.Sub(a, c)
-			im.Sub(b, d)
+			re = sub(a, c)
+			im = sub(b, d)
 		case token.MUL:
 			// (ac-bd) + i(bc+ad)
-			var ac, bd, bc, ad big.Rat
-			ac.Mul(a, c)
-			bd.Mul(b, d)
-			bc.Mul(b, c)
-			ad.Mul(a, d)
-			re.Sub(&ac, &bd)
-			im.Add(&bc, &ad)
+			ac := mul(a, c)
+			bd := mul(b, d)
+			bc := mul(b, c)
+			ad := mul(a, d)
+			re = sub(ac, bd)
+			im = add(bc, ad)
 		case token.QUO:
 			// (ac+bd)/s + i(bc-ad)/s, with s = cc + dd
-			var ac, bd, bc, ad, s big.Rat
-			ac.Mul(a, c)
-			bd.Mul(b, d)
-			bc.Mul(b, c)
-			ad.Mul(a, d)
-			s.Add(c.Mul(c, c), d.Mul(d, d))
-			re.Add(&ac, &bd)
-			re.Quo(&re, &s)
-			im.Sub(&bc, &ad)
-			im.Quo(&im, &s)
+			ac := mul(a, c)
+			bd := mul(b, d)
+			bc := mul(b, c)
+			ad := mul(a, d)
+			s := add(mul(c, c), mul(d, d))
+			re = add(ac, bd)
+			re = quo(re, s)
+			im = sub(bc, ad)
+			im = quo(im, s)
 		default:
 			goto Error
 		}
-		return normComplex(&re, &im)
+		return normComplex(re, im)
 
 	case stringVal:
 		if op == token.ADD {
@@ -618,6 +887,75 @@ Error:
 	panic(fmt.Sprintf("invalid binary operation %v %s %v", x, op, y))
 }
 
+// MaxIntBits is the implementation limit for the size of Int values
+// resulting from checked operations on Int operands; it matches the
+// limit of the gc compiler.
+const MaxIntBits = 512
+
+// Errors reported by checked operations.
+var (
+	ErrDivByZero = errors.New("division by zero")
+	ErrOverflow  = errors.New("constant overflow")
+)
+
+// CheckedBinaryOp is like BinaryOp but returns ErrDivByZero for
+// divisions by zero, and ErrOverflow if the result exceeds the
+// implementation limits for the given (result) kind: For kind Int,
+// the result must not require more than MaxIntBits bits; for kinds
+// Float and Complex, the binary exponent of the result must not
+// overflow. For all other kinds, no limits apply.
+//
+func CheckedBinaryOp(x Value, op token.Token, y Value, kind Kind) (Value, error) {
+	switch op {
+	case token.QUO, token.QUO_ASSIGN, token.REM:
+		if y.Kind() != Unknown && Sign(y) == 0 {
+			return unknownVal{}, ErrDivByZero
+		}
+	}
+	z := BinaryOp(x, op, y)
+	if err := checkLimit(z, kind); err != nil {
+		return unknownVal{}, err
+	}
+	if z.Kind() == Unknown && x.Kind() != Unknown && y.Kind() != Unknown {
+		return z, ErrOverflow // Inf result
+	}
+	return z, nil
+}
+
+// CheckedShift is like Shift but returns ErrOverflow if the result
+// would require more than MaxIntBits bits. In that case, the result
+// is not computed, so very large shift counts are cheap.
+func CheckedShift(x Value, op token.Token, s uint) (Value, error) {
+	if op == token.SHL && x.Kind() == Int && Sign(x) != 0 && uint(BitLen(x))+s > MaxIntBits {
+		return unknownVal{}, ErrOverflow
+	}
+	z := Shift(x, op, s)
+	return z, checkLimit(z, Int)
+}
+
+// checkLimit returns ErrOverflow if x exceeds the implementation
+// limits for kind; it returns nil otherwise.
+func checkLimit(x Value, kind Kind) error {
+	switch x := x.(type) {
+	case int64Val, intVal:
+		if kind == Int && BitLen(x) > MaxIntBits {
+			return ErrOverflow
+		}
+	case complexVal:
+		// the result of an operation on complex numbers may
+		// have an unknown (overflown) real or imaginary part
+		if x.re.Kind() == Unknown || x.im.Kind() == Unknown {
+			return ErrOverflow
+		}
+	}
+	return nil
+}
+
+func add(x, y Value) Value { return BinaryOp(x, token.ADD, y) }
+func sub(x, y Value) Value { return BinaryOp(x, token.SUB, y) }
+func mul(x, y Value) Value { return BinaryOp(x, token.MUL, y) }
+func quo(x, y Value) Value { return BinaryOp(x, token.QUO, y) }
+
 // Shift returns the result of the shift expression x op s
 // with op == token.SHL or token.SHR (<< or >>). x must be
 // an Int.
@@ -712,18 +1050,21 @@ func Compare(x Value, op token.Token, y Value) bool {
 	case intVal:
 		return cmpZero(x.val.Cmp(y.(intVal).val), op)
 
+	case ratVal:
+		return cmpZero(x.val.Cmp(y.(ratVal).val), op)
+
 	case floatVal:
 		return cmpZero(x.val.Cmp(y.(floatVal).val), op)
 
 	case complexVal:
 		y := y.(complexVal)
-		re := x.re.Cmp(y.re)
-		im := x.im.Cmp(y.im)
+		re := Compare(x.re, token.EQL, y.re)
+		im := Compare(x.im, token.EQL, y.im)
 		switch op {
 		case token.EQL:
-			return re == 0 && im == 0
+			return re && im
 		case token.NEQ:
-			return re != 0 || im != 0
+			return !re || !im
 		}
 
 	case stringVal:
@@ -5,6 +5,7 @@
 package exact
 
 import (
+	"fmt"
 	"go/token"
 	"strings"
 	"testing"
@@ -31,7 +32,9 @@ var tests = []string{
 	`0 + 0.1 = 0.1`,
 	`0 + 0.1i = 0.1i`,
 	`0.1 + 0.9 = 1`,
+	`0.1 + 0.2 = 0.3`,
 	`1e100 + 1e100 = 2e100`,
+	`1e10000 + 1e10000 = 2e10000`,
 
 	`0 - 0 = 0`,
 	`0 - 0.1 = -0.1`,
@@ -46,6 +49,8 @@ var tests = []string{
 	`0 / 0 = "division_by_zero"`,
 	`10 / 2 = 5`,
 	`5 / 3 = 5/3`,
+	`1e1000 / 1e999 = 10`,
+	`1 / 3 * 3 = 1`,
 
 	`0 % 0 = "runtime_error:_integer_divide_by_zero"`, // TODO(gri) should be the same as for /
 	`10 % 3 = 1`,
@@ -81,16 +86,8 @@ var tests = []string{
 
 func TestOps(t *testing.T) {
 	for _, test := range tests {
-		var got, want Value
-
-		switch a := strings.Split(test, " "); len(a) {
-		case 4:
-			got = doOp(nil, op[a[0]], val(a[1]))
-			want = val(a[3])
-		case 5:
-			got = doOp(val(a[0]), op[a[1]], val(a[2]))
-			want = val(a[4])
-		default:
+		got, want, ok := eval(test)
+		if !ok {
 			t.Errorf("invalid test case: %s", test)
 			continue
 		}
@@ -101,6 +98,177 @@ func TestOps(t *testing.T) {
 	}
 }
 
+func TestBoundedFloat(t *testing.T) {
+	// x = x/3 + 1 converges towards 3/2, but the exact
+	// fraction grows by log2(3) bits with each iteration.
+	x := MakeInt64(1)
+	for i := 0; i < 10000; i++ {
+		x = BinaryOp(BinaryOp(x, token.QUO, MakeInt64(3)), token.ADD, MakeInt64(1))
+	}
+	if x.Kind() != Float {
+		t.Fatalf("got kind %d; want Float", x.Kind())
+	}
+	if _, ok := x.(floatVal); !ok {
+		t.Errorf("got %T; want floatVal", x)
+	}
+	if f, _ := Float64Val(x); f != 1.5 {
+		t.Errorf("got %g; want 1.5", f)
+	}
+
+	// Small fractions remain exact.
+	x = BinaryOp(MakeInt64(1), token.QUO, MakeInt64(3))
+	if _, ok := x.(ratVal); !ok {
+		t.Errorf("got %T; want ratVal", x)
+	}
+	if x := BinaryOp(x, token.MUL, MakeInt64(3)); !Compare(x, token.EQL, MakeInt64(1)) {
+		t.Errorf("got %s; want 1", x)
+	}
+}
+
+func TestConvertInt(t *testing.T) {
+	for _, test := range []struct {
+		x      string
+		size   int
+		signed bool
+		want   string
+		ok     bool
+	}{
+		{"0", 1, true, "0", true},
+		{"127", 1, true, "127", true},
+		{"128", 1, true, "-128", false},
+		{"-128", 1, true, "-128", true},
+		{"-129", 1, true, "127", false},
+		{"255", 1, false, "255", true},
+		{"256", 1, false, "0", false},
+		{"-1", 1, false, "255", false},
+		{"-1", 8, false, "18446744073709551615", false},
+		{"18446744073709551615", 8, false, "18446744073709551615", true},
+		{"18446744073709551616", 8, false, "0", false},
+		{"9223372036854775808", 8, true, "-9223372036854775808", false},
+	} {
+		x := MakeFromLiteral(test.x, token.INT)
+		got, ok := ConvertInt(x, test.size, test.signed)
+		if got.String() != test.want || ok != test.ok {
+			t.Errorf("ConvertInt(%s, %d, %v) = %s, %v; want %s, %v",
+				test.x, test.size, test.signed, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	for _, test := range []struct {
+		x   string
+		f32 string // "" means overflow
+		f64 string
+	}{
+		{"0", "0", "0"},
+		{"0.5", "1/2", "1/2"},
+		{"0.1", "13421773/134217728", "3602879701896397/36028797018963968"},
+		{"1e39", "", "999999999999999939709166371603178586112"},
+		{"1e309", "", ""},
+		{"0.5i", "(0 + 1/2i)", "(0 + 1/2i)"},
+	} {
+		tok := token.FLOAT
+		if strings.HasSuffix(test.x, "i") {
+			tok = token.IMAG
+		}
+		x := MakeFromLiteral(test.x, tok)
+		for _, r := range []struct {
+			round func(Value) Value
+			want  string
+		}{{RoundFloat32, test.f32}, {RoundFloat64, test.f64}} {
+			want := r.want
+			if want == "" {
+				want = "unknown"
+			}
+			if got := r.round(x).String(); got != want {
+				t.Errorf("rounding %s: got %s; want %s", test.x, got, want)
+			}
+		}
+	}
+
+	if got := ToInt(MakeFromLiteral("2.5", token.FLOAT)); got.Kind() != Unknown {
+		t.Errorf("ToInt(2.5) = %s; want unknown", got)
+	}
+	if got := ToFloat(MakeFromLiteral("2.5i", token.IMAG)); got.Kind() != Unknown {
+		t.Errorf("ToFloat(2.5i) = %s; want unknown", got)
+	}
+}
+
+func TestCheckedOps(t *testing.T) {
+	big := Shift(MakeInt64(1), token.SHL, MaxIntBits-1)
+	for _, test := range []struct {
+		x, y Value
+		op   token.Token
+		kind Kind
+		err  error
+	}{
+		{big, big, token.ADD, Int, ErrOverflow},
+		{big, big, token.ADD, Float, nil},
+		{big, MakeInt64(1), token.SUB, Int, nil},
+		{MakeInt64(1), MakeInt64(0), token.QUO_ASSIGN, Int, ErrDivByZero},
+		{MakeInt64(1), MakeInt64(0), token.REM, Int, ErrDivByZero},
+		{val("1.5"), MakeInt64(0), token.QUO, Float, ErrDivByZero},
+		{val("1i"), val("0"), token.QUO, Complex, ErrDivByZero},
+		{val("1e400000000"), val("1e400000000"), token.MUL, Float, ErrOverflow},
+		{val("1e400000000i"), val("1e400000000"), token.MUL, Complex, ErrOverflow},
+		{MakeString("a"), MakeString("b"), token.ADD, String, nil},
+	} {
+		if _, err := CheckedBinaryOp(test.x, test.op, test.y, test.kind); err != test.err {
+			t.Errorf("%s %s %s: got error %v; want %v", test.x, test.op, test.y, err, test.err)
+		}
+	}
+
+	if _, err := CheckedShift(MakeInt64(1), token.SHL, MaxIntBits-1); err != nil {
+		t.Errorf("1 << %d: unexpected error %v", MaxIntBits-1, err)
+	}
+	if _, err := CheckedShift(MakeInt64(1), token.SHL, MaxIntBits); err != ErrOverflow {
+		t.Errorf("1 << %d: got error %v; want %v", MaxIntBits, err, ErrOverflow)
+	}
+	// must not attempt to compute a gigantic value
+	if _, err := CheckedShift(MakeInt64(1), token.SHL, 1<<40); err != ErrOverflow {
+		t.Errorf("1 << (1<<40): got error %v; want %v", err, ErrOverflow)
+	}
+	if z, err := CheckedShift(MakeInt64(0), token.SHL, 1<<20); err != nil || Sign(z) != 0 {
+		t.Errorf("0 << (1<<20): got %s, %v; want 0, <nil>", z, err)
+	}
+}
+
+func BenchmarkOps(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, test := range tests {
+			eval(test)
+		}
+	}
+}
+
+// constTable returns n literal/operator pairs resembling a table
+// of mathematical constants, each defined in terms of the previous
+// one: c[i] = c[i-1] op lit[i].
+func constTable(n int) (lits []string, ops []token.Token) {
+	divs := []token.Token{token.QUO, token.MUL, token.QUO, token.ADD}
+	for i := 0; i < n; i++ {
+		lits = append(lits, fmt.Sprintf("%d.%de%d", i%9+1, i*7919%1000, i%40-20))
+		ops = append(ops, divs[i%len(divs)])
+	}
+	return
+}
+
+func BenchmarkConstTable(b *testing.B) {
+	lits, ops := constTable(2000)
+	vals := make([]Value, len(lits))
+	for i, lit := range lits {
+		vals[i] = MakeFromLiteral(lit, token.FLOAT)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x := MakeInt64(1)
+		for j, y := range vals {
+			x = BinaryOp(x, ops[j], y)
+		}
+	}
+}
+
 // ----------------------------------------------------------------------------
 // Support functions
 
@@ -167,6 +335,21 @@ func panicHandler(v *Value) {
 	}
 }
 
+// eval evaluates a test case of the form "op x = z" or "x op y = z"
+// and returns the computed and the expected result.
+func eval(test string) (got, want Value, ok bool) {
+	switch a := strings.Split(test, " "); len(a) {
+	case 4:
+		return doOp(nil, op[a[0]], val(a[1])), val(a[3]), true
+	case 5:
+		return doOp(val(a[0]), op[a[1]], val(a[2])), val(a[4]), true
+	case 7:
+		// x op y op z = w, evaluated left to right
+		return doOp(doOp(val(a[0]), op[a[1]], val(a[2])), op[a[3]], val(a[4])), val(a[6]), true
+	}
+	return
+}
+
 func doOp(x Value, op token.Token, y Value) (z Value) {
 	defer panicHandler(&z)
 
@@ -0,0 +1,238 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements formatting of values as Go source literals.
+
+package exact
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A LiteralForm specifies the preferred form of the Go literal
+// produced by Literal. Forms that don't apply to a value's Kind
+// are ignored in favor of Decimal.
+type LiteralForm int
+
+const (
+	Decimal   LiteralForm = iota // 123, 1.5, 1.5e100 (default form)
+	Hex                          // 0x7b, 0x1.8p+00 (Int, Float, Complex)
+	Exponent                     // 1.23e+02 (Int, Float, Complex)
+	Rune                         // 'a' (Int values that are valid Unicode code points)
+	RawString                    // `abc` (String values that can be backquoted)
+)
+
+// Literal returns a Go source representation of x in the given form.
+// The result is a literal or, for some Float and Complex values, a
+// parenthesized constant expression of literals, that evaluates to x
+// when parsed and type-checked as an untyped constant.
+//
+// For non-integral Float values and the components of Complex values,
+// prec specifies the number of significant decimal digits (Decimal,
+// Exponent), or the number of hexadecimal digits after the point (Hex).
+// If prec < 0, the result is exact: fractions that have no finite
+// representation in the requested base are written as quotients
+// such as (1.0/3). Values that exceed the precision of exact fractions
+// are written with the shortest representation that reproduces their
+// internal value. Int values are always exact.
+//
+// Unknown values have no literal form; for them Literal returns x.String().
+//
+func Literal(x Value, form LiteralForm, prec int) string {
+	switch x := x.(type) {
+	case boolVal, nilVal, unknownVal:
+		return x.String()
+
+	case stringVal:
+		if form == RawString && strconv.CanBackquote(string(x)) {
+			return "`" + string(x) + "`"
+		}
+		return strconv.Quote(string(x))
+
+	case int64Val:
+		if form == Rune && 0 <= x && x <= utf8.MaxRune && utf8.ValidRune(rune(x)) {
+			return strconv.QuoteRune(rune(x))
+		}
+		return intLit(big.NewInt(int64(x)), form)
+
+	case intVal:
+		return intLit(x.val, form)
+
+	case ratVal:
+		return ratLit(x.val, form, prec)
+
+	case floatVal:
+		return floatLit(x.val, form, prec)
+
+	case complexVal:
+		if form == Rune || form == RawString {
+			form = Decimal
+		}
+		im := imagLit(x.im, form, prec)
+		if Sign(x.re) == 0 {
+			return im
+		}
+		re := Literal(x.re, form, prec)
+		if im[0] == '-' {
+			return fmt.Sprintf("(%s - %s)", re, im[1:])
+		}
+		return fmt.Sprintf("(%s + %s)", re, im)
+	}
+	panic(fmt.Sprintf("invalid Literal(%v)", x))
+}
+
+// imagLit returns the imaginary literal for x*i.
+func imagLit(x Value, form LiteralForm, prec int) string {
+	s := Literal(x, form, prec)
+	if strings.HasPrefix(s, "(") || strings.HasPrefix(s, "-(") {
+		// quotient: not a valid imaginary literal prefix
+		return s + "*1i"
+	}
+	return s + "i"
+}
+
+// intLit returns the literal for the integer x.
+func intLit(x *big.Int, form LiteralForm) string {
+	switch form {
+	case Hex:
+		if x.Sign() < 0 {
+			return "-0x" + new(big.Int).Neg(x).Text(16)
+		}
+		return "0x" + x.Text(16)
+	case Exponent:
+		return expLit(x.String(), 0)
+	}
+	return x.String()
+}
+
+// expLit returns the exponent form of the decimal number with the
+// (possibly signed) integral digit string digits, multiplied by 10**exp.
+func expLit(digits string, exp int) string {
+	sign := ""
+	if digits[0] == '-' {
+		sign = "-"
+		digits = digits[1:]
+	}
+	exp += len(digits) - 1
+	mant := digits[:1]
+	if frac := strings.TrimRight(digits[1:], "0"); frac != "" {
+		mant += "." + frac
+	}
+	if digits == "0" {
+		exp = 0
+	}
+	esign := "+"
+	if exp < 0 {
+		esign = "-"
+		exp = -exp
+	}
+	return fmt.Sprintf("%s%se%s%02d", sign, mant, esign, exp)
+}
+
+// ratLit returns the literal for the fraction x.
+func ratLit(x *big.Rat, form LiteralForm, prec int) string {
+	if prec >= 0 {
+		// Round to the requested number of digits. Use enough mantissa
+		// bits so that the conversion doesn't lose any requested digits.
+		bits := uint(prec)*4 + 64
+		return floatLit(new(big.Float).SetPrec(bits).SetRat(x), form, prec)
+	}
+
+	// exact representation
+	num, den := x.Num(), x.Denom()
+	switch form {
+	case Hex:
+		if isPow2(den) {
+			// dyadic fraction: exactly representable in binary
+			f := new(big.Float).SetPrec(uint(num.BitLen()) + 1).SetRat(x)
+			return f.Text('x', -1)
+		}
+		return fmt.Sprintf("(%s/%s)", new(big.Float).SetInt(num).Text('x', -1), intLit(den, Hex))
+	default:
+		if k, ok := decimalScale(den); ok {
+			// x = num * 10**k / 10**k, and num * 10**k / den is integral
+			p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(k)), nil)
+			digits := new(big.Int).Quo(p.Mul(p, num), den).String()
+			if form == Exponent {
+				return expLit(digits, -k)
+			}
+			return decLit(digits, k)
+		}
+		if form == Exponent {
+			return fmt.Sprintf("(%s/%s)", expLit(num.String(), 0), den)
+		}
+		return fmt.Sprintf("(%s.0/%s)", num, den)
+	}
+}
+
+// floatLit returns the literal for the floating-point number x.
+func floatLit(x *big.Float, form LiteralForm, prec int) string {
+	switch form {
+	case Hex:
+		return x.Text('x', prec)
+	case Exponent:
+		if prec > 0 {
+			prec-- // the digit before the point is significant as well
+		}
+		return x.Text('e', prec)
+	}
+	s := x.Text('g', prec)
+	if !strings.ContainsAny(s, ".e") {
+		// integral value: make sure the result is a float literal
+		s += ".0"
+	}
+	return s
+}
+
+// decLit returns the decimal literal with the (possibly signed)
+// integral digit string digits, divided by 10**k; k > 0.
+func decLit(digits string, k int) string {
+	sign := ""
+	if digits[0] == '-' {
+		sign = "-"
+		digits = digits[1:]
+	}
+	if n := k + 1 - len(digits); n > 0 {
+		digits = strings.Repeat("0", n) + digits
+	}
+	i := len(digits) - k
+	return sign + digits[:i] + "." + digits[i:]
+}
+
+// decimalScale returns the smallest k such that 10**k is a multiple
+// of den, if any.
+func decimalScale(den *big.Int) (k int, ok bool) {
+	d := new(big.Int).Set(den)
+	var e2, e5 int
+	for d.Bit(0) == 0 {
+		d.Rsh(d, 1)
+		e2++
+	}
+	five := big.NewInt(5)
+	var q, r big.Int
+	for {
+		q.QuoRem(d, five, &r)
+		if r.Sign() != 0 {
+			break
+		}
+		d.Set(&q)
+		e5++
+	}
+	if d.Cmp(int1) != 0 {
+		return 0, false
+	}
+	if e2 > e5 {
+		return e2, true
+	}
+	return e5, true
+}
+
+// isPow2 reports whether x is a power of 2.
+func isPow2(x *big.Int) bool {
+	return x.Sign() > 0 && x.TrailingZeroBits() == uint(x.BitLen()-1)
+}
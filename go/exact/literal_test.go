@@ -0,0 +1,111 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package exact
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+var literalTests = []struct {
+	x    string // value, as for val
+	form LiteralForm
+	prec int
+	want string
+}{
+	{`true`, Decimal, -1, `true`},
+	{`"foo"`, Decimal, -1, `"foo"`},
+	{`"foo"`, RawString, -1, "`foo`"},
+	{"\"a\x60b\"", RawString, -1, "\"a`b\""},
+
+	{`0`, Decimal, -1, `0`},
+	{`-123`, Decimal, -1, `-123`},
+	{`-123`, Hex, -1, `-0x7b`},
+	{`123`, Exponent, -1, `1.23e+02`},
+	{`97`, Rune, -1, `'a'`},
+	{`-1`, Rune, -1, `-1`},
+	{`100000000000000000000`, Hex, -1, `0x56bc75e2d63100000`},
+
+	{`0.5`, Decimal, -1, `0.5`},
+	{`-0.125`, Decimal, -1, `-0.125`},
+	{`0.001`, Exponent, -1, `1e-03`},
+	{`1.5`, Hex, -1, `0x1.8p+00`},
+	{`1/3`, Decimal, -1, `(1.0/3)`},
+	{`-1/3`, Decimal, -1, `(-1.0/3)`},
+	{`1/3`, Hex, -1, `(0x1p+00/0x3)`},
+	{`1/3`, Decimal, 5, `0.33333`},
+	{`2/3`, Decimal, 3, `0.667`},
+	{`2/3`, Exponent, 3, `6.67e-01`},
+	{`2/3i`, Exponent, 3, `6.67e-01i`},
+	{`1e10000`, Decimal, 3, `1e+10000`},
+
+	{`2i`, Decimal, -1, `2i`},
+	{`0.5i`, Decimal, -1, `0.5i`},
+	{`1/3i`, Decimal, -1, `(1.0/3)*1i`},
+}
+
+func TestLiteral(t *testing.T) {
+	for _, test := range literalTests {
+		x := litVal(test.x)
+		got := Literal(x, test.form, test.prec)
+		if got != test.want {
+			t.Errorf("Literal(%s, %d, %d) = %s; want %s", test.x, test.form, test.prec, got, test.want)
+			continue
+		}
+		if test.prec >= 0 {
+			continue // not exact
+		}
+		// the result must evaluate to x
+		if y := evalLit(t, got); y == nil || !Compare(x, token.EQL, y) {
+			t.Errorf("Literal(%s, %d, %d) = %s evaluates to %v", test.x, test.form, test.prec, got, y)
+		}
+	}
+
+	// complex values
+	z := BinaryOp(MakeInt64(1), token.SUB, val("0.5i"))
+	for _, form := range []LiteralForm{Decimal, Hex, Exponent} {
+		lit := Literal(z, form, -1)
+		if y := evalLit(t, lit); y == nil || !Compare(z, token.EQL, y) {
+			t.Errorf("Literal(%s, %d, -1) = %s evaluates to %v", z, form, lit, y)
+		}
+	}
+}
+
+// litVal is like val but also accepts fractions with imaginary unit, "1/3i".
+func litVal(lit string) Value {
+	if n := len(lit); n > 1 && lit[n-1] == 'i' && lit[0] != '"' {
+		return MakeImag(val(lit[:n-1]))
+	}
+	return val(lit)
+}
+
+// evalLit parses and evaluates the constant expression src.
+func evalLit(t *testing.T, src string) Value {
+	x, err := parser.ParseExpr(src)
+	if err != nil {
+		t.Errorf("%s: %s", src, err)
+		return nil
+	}
+	var eval func(x ast.Expr) Value
+	eval = func(x ast.Expr) Value {
+		switch x := x.(type) {
+		case *ast.BasicLit:
+			return MakeFromLiteral(x.Value, x.Kind)
+		case *ast.Ident:
+			return MakeBool(x.Name == "true")
+		case *ast.ParenExpr:
+			return eval(x.X)
+		case *ast.UnaryExpr:
+			return UnaryOp(x.Op, eval(x.X), -1)
+		case *ast.BinaryExpr:
+			return BinaryOp(eval(x.X), x.Op, eval(x.Y))
+		}
+		t.Errorf("%s: unexpected expression %T", src, x)
+		return MakeUnknown()
+	}
+	return eval(x)
+}
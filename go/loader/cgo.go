@@ -0,0 +1,90 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the preprocessing of cgo files.
+
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ParseCgoFiles runs the cgo tool ("go tool cgo") on the named files of
+// a package, which import the pseudo-package "C", and parses the files
+// generated by cgo into fset using the given parser mode. The tool runs
+// in directory dir (the current directory if dir is empty); relative
+// file names are relative to it.
+// The result consists of the files rewritten by cgo, in which references
+// to C entities have been replaced by references to Go declarations, and
+// of the file _cgo_gotypes.go providing these declarations. Together with
+// the package's other files, they can be type-checked like any package.
+//
+// The rewritten files contain line directives referring to the original
+// files, so that positions reported for them (e.g., in type-checking
+// errors) are positions in the original files. The flags cflags (e.g.,
+// the flags specified by #cgo directives, see CgoFlags) are passed to
+// the C compiler. The files generated by cgo import the packages
+// "syscall" and "runtime/cgo".
+//
+func ParseCgoFiles(fset *token.FileSet, dir string, filenames []string, cflags []string, mode parser.Mode) ([]*ast.File, error) {
+	tmpdir, err := ioutil.TempDir("", "cgo")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	args := []string{"tool", "cgo", "-objdir", tmpdir, "--"}
+	args = append(args, cflags...)
+	args = append(args, filenames...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("cgo failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	generated := []string{"_cgo_gotypes.go"}
+	for _, filename := range filenames {
+		generated = append(generated, strings.TrimSuffix(filepath.Base(filename), ".go")+".cgo1.go")
+	}
+
+	var files []*ast.File
+	for _, filename := range generated {
+		file, err := parser.ParseFile(fset, filepath.Join(tmpdir, filename), nil, mode)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// CgoFlags returns the flags to be passed to the C compiler for the
+// package bp, as specified by its #cgo CPPFLAGS and CFLAGS directives.
+func CgoFlags(bp *build.Package) []string {
+	var flags []string
+	flags = append(flags, bp.CgoCPPFLAGS...)
+	return append(flags, bp.CgoCFLAGS...)
+}
+
+// ImportsC reports whether file imports the pseudo-package "C".
+func ImportsC(file *ast.File) bool {
+	for _, spec := range file.Imports {
+		if spec.Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
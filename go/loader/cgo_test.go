@@ -0,0 +1,123 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loader
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+const cgoSrc = `package a
+
+// #include <stdlib.h>
+// static int twice(int x) { return 2*x; }
+import "C"
+
+import "unsafe"
+
+func Twice(x int) int { return int(C.twice(C.int(x))) }
+
+func Free(p *C.char) { C.free(unsafe.Pointer(p)) }
+
+var s string = C.twice(1)
+`
+
+// cgoStubs are the sources of stand-ins for the packages imported by
+// the files generated by cgo.
+var cgoStubs = map[string]string{
+	"syscall":     "package syscall; type Errno uintptr",
+	"runtime/cgo": "package cgo; type Incomplete struct{}",
+}
+
+func TestParseCgoFiles(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir, err := ioutil.TempDir("", "cgotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(filename, []byte(cgoSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ImportsC(file) {
+		t.Fatal("ImportsC = false for cgo file")
+	}
+
+	files, err := ParseCgoFiles(fset, dir, []string{"a.go"}, nil, 0)
+	if err != nil {
+		t.Skipf("cgo not available: %s", err)
+	}
+
+	ctxt := types.Context{
+		Import: func(imports map[string]*types.Package, path string) (*types.Package, error) {
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			if pkg := imports[path]; pkg != nil {
+				return pkg, nil
+			}
+			f, err := parser.ParseFile(fset, path+".go", cgoStubs[path], 0)
+			if err != nil {
+				return nil, err
+			}
+			pkg, err := types.Check(path, fset, f)
+			if err != nil {
+				return nil, err
+			}
+			imports[path] = pkg
+			return pkg, nil
+		},
+	}
+
+	var errors []error
+	ctxt.Error = func(err error) { errors = append(errors, err) }
+	pkg, _ := ctxt.Check("a", fset, files...)
+
+	// the only error is the one in the original file
+	if len(errors) != 1 {
+		t.Fatalf("got errors %v; want 1 error", errors)
+	}
+	pos := fset.Position(errors[0].(types.Error).Pos)
+	if pos.Filename != filename || pos.Line != 13 {
+		t.Errorf("got error at %s; want %s:13", pos, filename)
+	}
+
+	if obj := pkg.Scope().Lookup("Twice"); obj == nil || obj.Type().String() != "func(x int) int" {
+		t.Errorf("got Twice = %v", obj)
+	}
+
+	// references to C entities denote the generated declarations
+	found := false
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && strings.HasPrefix(id.Name, "_Cfunc_twice") {
+				found = true
+			}
+			return true
+		})
+	}
+	if !found {
+		t.Errorf("no declaration of _Cfunc_twice")
+	}
+}
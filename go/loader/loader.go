@@ -0,0 +1,114 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package loader locates, parses and type-checks the sources of Go
+// packages for tools based on go/types, preprocessing files that
+// import "C" with the cgo tool.
+//
+// It is kept apart from go/types, which does not access the file
+// system or run external commands on its own.
+package loader
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+// A SourceImporter type-checks packages from source. The files of a
+// package are located with go/build and parsed into Fset; each package
+// is type-checked at most once.
+type SourceImporter struct {
+	Fset *token.FileSet // file set of the parsed files
+	Ctxt *build.Context // context for locating packages; if nil, build.Default is used
+	Deps types.Importer // if non-nil, used to import the dependencies of packages instead of type-checking them from source
+
+	pkgs map[string]*types.Package // packages type-checked from source, by path
+}
+
+// NewSourceImporter returns a SourceImporter that parses files into fset
+// and imports dependencies with deps, or from source if deps is nil.
+func NewSourceImporter(fset *token.FileSet, deps types.Importer) *SourceImporter {
+	return &SourceImporter{Fset: fset, Deps: deps}
+}
+
+// Import type-checks the package with the given path from source and
+// adds it to imports. It satisfies the types.Importer signature.
+// Type errors are returned but the package may be non-nil nevertheless.
+func (imp *SourceImporter) Import(imports map[string]*types.Package, path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if pkg := imp.pkgs[path]; pkg != nil {
+		imports[path] = pkg
+		return pkg, nil
+	}
+
+	ctxt := imp.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	bp, err := ctxt.Import(path, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ParsePackageFiles(imp.Fset, bp, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := types.Context{
+		Import: imp.importDependency,
+		Error:  func(error) {}, // report all errors; the first one is returned by Check
+	}
+	pkg, err := tc.Check(path, imp.Fset, files...)
+	if pkg != nil {
+		if imp.pkgs == nil {
+			imp.pkgs = make(map[string]*types.Package)
+		}
+		imp.pkgs[path] = pkg
+		imports[path] = pkg
+	}
+	return pkg, err
+}
+
+// importDependency is the Importer used by Import. Type errors in
+// dependencies type-checked from source are ignored as long as a
+// package is produced; they only matter for the imported package.
+func (imp *SourceImporter) importDependency(imports map[string]*types.Package, path string) (*types.Package, error) {
+	if imp.Deps != nil {
+		return imp.Deps(imports, path)
+	}
+	pkg, err := imp.Import(imports, path)
+	if pkg == nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// ParsePackageFiles parses the Go files of package bp into fset using
+// the given parser mode. Files importing "C" are replaced by the files
+// generated by cgo, as by ParseCgoFiles.
+func ParsePackageFiles(fset *token.FileSet, bp *build.Package, mode parser.Mode) ([]*ast.File, error) {
+	var files []*ast.File
+	for _, filename := range bp.GoFiles {
+		file, err := parser.ParseFile(fset, filepath.Join(bp.Dir, filename), nil, mode)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	if len(bp.CgoFiles) > 0 {
+		cgoFiles, err := ParseCgoFiles(fset, bp.Dir, bp.CgoFiles, CgoFlags(bp), mode)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, cgoFiles...)
+	}
+	return files, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loader
+
+import (
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+// loaderSrcs are the sources of the packages in the test GOPATH.
+var loaderSrcs = map[string]string{
+	"a": `package a; import "b"; var V b.T = b.F()`,
+	"b": `package b; type T int; func F() T { return 1 }; var E undefined`,
+}
+
+func TestSourceImporter(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "loadertest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	for path, src := range loaderSrcs {
+		dir := filepath.Join(gopath, "src", path)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, path+".go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctxt := build.Default
+	ctxt.GOPATH = gopath
+	ctxt.CgoEnabled = false
+	imp := NewSourceImporter(token.NewFileSet(), nil)
+	imp.Ctxt = &ctxt
+
+	// type errors in dependencies are ignored
+	imports := make(map[string]*types.Package)
+	a, err := imp.Import(imports, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj := a.Scope().Lookup("V"); obj == nil || obj.Type().String() != "b.T" {
+		t.Errorf("got V = %v", obj)
+	}
+
+	// packages are type-checked once
+	if b, _ := imp.Import(make(map[string]*types.Package), "b"); b == nil || b != a.Imports()["b"] {
+		t.Errorf("b type-checked again or not imported: %v", b)
+	}
+}
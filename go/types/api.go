@@ -16,7 +16,6 @@ package types
 //
 // BUG(gri): Method sets are computed loosely and don't distinguish between ptr vs non-pointer receivers.
 // BUG(gri): Method expressions and method values work accidentally and may not be fully checked.
-// BUG(gri): Conversions of constants only change the type, not the value (e.g., int(1.1) is wrong).
 // BUG(gri): Some built-ins don't check parameters fully, yet (e.g. append).
 // BUG(gri): Use of labels is not checked.
 // BUG(gri): Unused variables and imports are not reported.
@@ -33,6 +32,7 @@ package types
 // API(gri): The GcImporter should probably be in its own package - it is only one of possible importers.
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
 
@@ -46,6 +46,7 @@ type Context struct {
 	// during type checking. The error strings of errors with
 	// detailed position information are formatted as follows:
 	// filename:line:column: message
+	// The errors are of type Error.
 	Error func(err error)
 
 	// If Ident != nil, it is called for each identifier id
@@ -72,6 +73,15 @@ type Context struct {
 	// Otherwise, GcImporter is called.
 	Import Importer
 
+	// If ImportPositions is set, the positions of the package-level
+	// objects and methods of imported packages are determined from
+	// the packages' source files, as for ImportSourcePositions, and
+	// reported by the objects' Pos methods. Missing or unparsable
+	// sources are not an error; the positions remain unknown. It is
+	// an error if the positions of an imported package were recorded
+	// for a file set other than the one passed to Check.
+	ImportPositions bool
+
 	// If Alignof != nil, it is called to determine the alignment
 	// of the given type. Otherwise DefaultAlignmentof is called.
 	// Alignof must implement the alignment guarantees required by
@@ -90,6 +100,23 @@ type Context struct {
 	Sizeof func(Type) int64
 }
 
+// An Error describes a type-checking error; it implements the error interface.
+// If known, Start and End delimit the source range of the offending construct,
+// e.g., an entire binary expression whose constant value overflows; otherwise
+// they are token.NoPos.
+type Error struct {
+	Fset       *token.FileSet // file set for interpretation of Pos, Start, and End
+	Pos        token.Pos      // error position
+	Msg        string         // error message
+	Start, End token.Pos      // source range, or token.NoPos
+}
+
+// Error returns an error string formatted as follows:
+// filename:line:column: message
+func (err Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Fset.Position(err.Pos), err.Msg)
+}
+
 // An Importer resolves import paths to Package objects.
 // The imports map records the packages already imported,
 // indexed by package id (canonical import path).
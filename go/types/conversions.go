@@ -52,8 +52,11 @@ func (check *checker) conversion(x *operand, conv *ast.CallExpr, typ Type, iota
 			default:
 				goto ErrorMsg
 			}
+		} else if !isRepresentableConst(x.val, check.ctxt, typ.kind, &x.val) {
+			// T(x) requires that the constant x be representable by T,
+			// e.g., int(1.1) and uint8(256) are invalid.
+			goto ErrorMsg
 		}
-		// TODO(gri) verify the remaining conversions.
 	} else {
 		// non-constant conversion
 		if !x.isConvertible(check.ctxt, typ) {
@@ -85,7 +85,13 @@ func (check *checker) err(err error) {
 }
 
 func (check *checker) errorf(pos token.Pos, format string, args ...interface{}) {
-	check.err(fmt.Errorf("%s: %s", check.fset.Position(pos), check.formatMsg(format, args)))
+	check.err(Error{Fset: check.fset, Pos: pos, Msg: check.formatMsg(format, args)})
+}
+
+// rangeErrorf is like errorf but also records the source range [start, end)
+// of the offending construct.
+func (check *checker) rangeErrorf(pos, start, end token.Pos, format string, args ...interface{}) {
+	check.err(Error{Fset: check.fset, Pos: pos, Msg: check.formatMsg(format, args), Start: start, End: end})
 }
 
 func (check *checker) invalidAST(pos token.Pos, format string, args ...interface{}) {
@@ -7,7 +7,6 @@
 package types
 
 import (
-	"fmt"
 	"go/ast"
 	"go/token"
 	"strconv"
@@ -308,7 +307,10 @@ func isComparison(op token.Token) bool {
 	return false
 }
 
-func isRepresentableConst(x exact.Value, ctxt *Context, as BasicKind) bool {
+// isRepresentableConst reports whether x can be represented as a value
+// of the given basic type kind. If rounded != nil, *rounded is set to the
+// rounded value of x for float and complex types, and to x otherwise.
+func isRepresentableConst(x exact.Value, ctxt *Context, as BasicKind, rounded *exact.Value) bool {
 	switch x.Kind() {
 	case exact.Unknown:
 		return true
@@ -316,91 +318,53 @@ func isRepresentableConst(x exact.Value, ctxt *Context, as BasicKind) bool {
 	case exact.Bool:
 		return as == Bool || as == UntypedBool
 
-	case exact.Int:
-		if x, ok := exact.Int64Val(x); ok {
-			switch as {
-			case Int:
-				var s = uint(ctxt.sizeof(Typ[as])) * 8
-				return int64(-1)<<(s-1) <= x && x <= int64(1)<<(s-1)-1
-			case Int8:
-				const s = 8
-				return -1<<(s-1) <= x && x <= 1<<(s-1)-1
-			case Int16:
-				const s = 16
-				return -1<<(s-1) <= x && x <= 1<<(s-1)-1
-			case Int32:
-				const s = 32
-				return -1<<(s-1) <= x && x <= 1<<(s-1)-1
-			case Int64:
-				return true
-			case Uint, Uintptr:
-				if s := uint(ctxt.sizeof(Typ[as])) * 8; s < 64 {
-					return 0 <= x && x <= int64(1)<<s-1
-				}
-				return 0 <= x
-			case Uint8:
-				const s = 8
-				return 0 <= x && x <= 1<<s-1
-			case Uint16:
-				const s = 16
-				return 0 <= x && x <= 1<<s-1
-			case Uint32:
-				const s = 32
-				return 0 <= x && x <= 1<<s-1
-			case Uint64:
-				return 0 <= x
-			case Float32:
-				return true // TODO(gri) fix this
-			case Float64:
-				return true // TODO(gri) fix this
-			case Complex64:
-				return true // TODO(gri) fix this
-			case Complex128:
-				return true // TODO(gri) fix this
-			case UntypedInt, UntypedFloat, UntypedComplex:
-				return true
-			}
-		}
-
-		n := exact.BitLen(x)
+	case exact.Int, exact.Float, exact.Complex:
 		switch as {
-		case Uint, Uintptr:
-			var s = uint(ctxt.sizeof(Typ[as])) * 8
-			return exact.Sign(x) >= 0 && n <= int(s)
-		case Uint64:
-			return exact.Sign(x) >= 0 && n <= 64
-		case Float32:
-			return true // TODO(gri) fix this
-		case Float64:
-			return true // TODO(gri) fix this
-		case Complex64:
-			return true // TODO(gri) fix this
-		case Complex128:
-			return true // TODO(gri) fix this
-		case UntypedInt, UntypedFloat, UntypedComplex:
+		case Int, Int8, Int16, Int32, Int64:
+			x := exact.ToInt(x)
+			if x.Kind() != exact.Int {
+				return false
+			}
+			_, ok := exact.ConvertInt(x, intSize(ctxt, as), true)
+			return ok
+		case Uint, Uint8, Uint16, Uint32, Uint64, Uintptr:
+			x := exact.ToInt(x)
+			if x.Kind() != exact.Int {
+				return false
+			}
+			_, ok := exact.ConvertInt(x, intSize(ctxt, as), false)
+			return ok
+		case Float32, Float64:
+			if exact.ToFloat(x).Kind() == exact.Unknown {
+				return false
+			}
+			r := exact.RoundFloat64(x)
+			if as == Float32 {
+				r = exact.RoundFloat32(x)
+			}
+			if r.Kind() == exact.Unknown {
+				return false // overflow
+			}
+			if rounded != nil {
+				*rounded = r
+			}
 			return true
-		}
-
-	case exact.Float:
-		switch as {
-		case Float32:
-			return true // TODO(gri) fix this
-		case Float64:
-			return true // TODO(gri) fix this
-		case Complex64:
-			return true // TODO(gri) fix this
-		case Complex128:
-			return true // TODO(gri) fix this
-		case UntypedFloat, UntypedComplex:
+		case Complex64, Complex128:
+			r := exact.RoundFloat64(x)
+			if as == Complex64 {
+				r = exact.RoundFloat32(x)
+			}
+			if r.Kind() == exact.Unknown {
+				return false // overflow
+			}
+			if rounded != nil {
+				*rounded = r
+			}
 			return true
-		}
-
-	case exact.Complex:
-		switch as {
-		case Complex64:
-			return true // TODO(gri) fix this
-		case Complex128:
-			return true // TODO(gri) fix this
+		case UntypedInt:
+			return exact.ToInt(x).Kind() == exact.Int
+		case UntypedFloat:
+			return exact.ToFloat(x).Kind() != exact.Unknown
 		case UntypedComplex:
 			return true
 		}
@@ -418,13 +382,24 @@ func isRepresentableConst(x exact.Value, ctxt *Context, as BasicKind) bool {
 	return false
 }
 
+// intSize returns the size in bytes of the integer type kind as.
+// ctxt is only consulted for the platform-dependent kinds.
+func intSize(ctxt *Context, as BasicKind) int {
+	switch as {
+	case Int, Uint, Uintptr:
+		return int(ctxt.sizeof(Typ[as]))
+	}
+	return int(DefaultSizeof(Typ[as]))
+}
+
 // isRepresentable checks that a constant operand is representable in the given type.
+// Float and complex constant values are rounded to the precision of typ.
 func (check *checker) isRepresentable(x *operand, typ *Basic) {
 	if x.mode != constant || isUntyped(typ) {
 		return
 	}
 
-	if !isRepresentableConst(x.val, check.ctxt, typ.kind) {
+	if !isRepresentableConst(x.val, check.ctxt, typ.kind, &x.val) {
 		var msg string
 		if isNumeric(x.typ) && isNumeric(typ) {
 			msg = "%s overflows (or cannot be accurately represented as) %s"
@@ -671,7 +646,7 @@ func (check *checker) shift(x, y *operand, op token.Token) {
 
 	// The lhs must be of integer type or be representable
 	// as an integer; otherwise the shift has no chance.
-	if !isInteger(x.typ) && (!untypedx || !isRepresentableConst(x.val, nil, UntypedInt)) {
+	if !isInteger(x.typ) && (!untypedx || !isRepresentableConst(x.val, nil, UntypedInt, nil)) {
 		check.invalidOp(x.pos(), "shifted operand %s must be integer", x)
 		x.mode = invalid
 		return
@@ -709,7 +684,12 @@ func (check *checker) shift(x, y *operand, op token.Token) {
 				return
 			}
 			// everything's ok
-			x.val = exact.Shift(x.val, op, uint(s))
+			var err error
+			x.val, err = exact.CheckedShift(x.val, op, uint(s))
+			if err != nil {
+				check.opError(x, y, err)
+				x.mode = invalid
+			}
 			return
 		}
 
@@ -748,6 +728,35 @@ func (check *checker) shift(x, y *operand, op token.Token) {
 	x.mode = value
 }
 
+// constKind returns the kind of constant values of type typ.
+func constKind(typ *Basic) exact.Kind {
+	switch {
+	case isInteger(typ):
+		return exact.Int
+	case isFloat(typ):
+		return exact.Float
+	case isComplex(typ):
+		return exact.Complex
+	}
+	return exact.Unknown
+}
+
+// opError reports the error err resulting from the binary or shift
+// operation x op y, with the entire operation as source range. Division
+// by zero is reported at y, all other errors are reported at x.
+func (check *checker) opError(x, y *operand, err error) {
+	start := x.pos()
+	end := y.expr.End()
+	if e := x.expr.End(); e > end {
+		end = e // y may be synthesized, as for x++
+	}
+	if err == exact.ErrDivByZero {
+		check.rangeErrorf(y.pos(), start, end, "invalid operation: %s", err)
+		return
+	}
+	check.rangeErrorf(start, start, end, "%s", err)
+}
+
 var binaryOpPredicates = opPredicates{
 	token.ADD: func(typ Type) bool { return isNumeric(typ) || isString(typ) },
 	token.SUB: isNumeric,
@@ -815,7 +824,7 @@ func (check *checker) binary(x *operand, lhs, rhs ast.Expr, op token.Token, iota
 	}
 
 	if (op == token.QUO || op == token.REM) && y.mode == constant && exact.Sign(y.val) == 0 {
-		check.invalidOp(y.pos(), "division by zero")
+		check.opError(x, &y, exact.ErrDivByZero)
 		x.mode = invalid
 		return
 	}
@@ -826,7 +835,13 @@ func (check *checker) binary(x *operand, lhs, rhs ast.Expr, op token.Token, iota
 		if op == token.QUO && isInteger(typ) {
 			op = token.QUO_ASSIGN
 		}
-		x.val = exact.BinaryOp(x.val, op, y.val)
+		var err error
+		x.val, err = exact.CheckedBinaryOp(x.val, op, y.val, constKind(typ))
+		if err != nil {
+			check.opError(x, &y, err)
+			x.mode = invalid
+			return
+		}
 		// Typed constants must be representable in
 		// their type after each constant operation.
 		check.isRepresentable(x, typ)
@@ -1272,6 +1287,11 @@ func (check *checker) rawExpr(x *operand, e ast.Expr, hint Type, iota int, cycle
 		if ident, ok := e.X.(*ast.Ident); ok {
 			if pkg, ok := check.lookup(ident).(*Package); ok {
 				exp := pkg.scope.Lookup(sel)
+				if err := pkg.ImportError(); err != nil {
+					// exp may be missing or incomplete
+					check.errorf(e.Pos(), "could not import %s (%s)", pkg.path, err)
+					goto Error
+				}
 				if exp == nil {
 					check.errorf(e.Pos(), "%s not declared by package %s", sel, ident)
 					goto Error
@@ -1314,7 +1334,6 @@ func (check *checker) rawExpr(x *operand, e ast.Expr, hint Type, iota int, cycle
 			goto Error
 		}
 		res := lookupField(x.typ, check.pkg, sel)
-		utyp := x.typ.Underlying()
 		if res.mode == invalid {
 			check.invalidOp(e.Pos(), "%s has no single field or method %s", x, sel)
 			goto Error
@@ -1335,8 +1354,7 @@ func (check *checker) rawExpr(x *operand, e ast.Expr, hint Type, iota int, cycle
 				params = sig.params.vars
 			}
 			x.mode = value
-			m := x.typ.Deref().(*Named).methods.Lookup(check.pkg, sel)
-			check.register(e.Sel, m)
+			check.register(e.Sel, res.obj)
 			x.typ = &Signature{
 				params:     NewTuple(append([]*Var{{typ: x.typ}}, params...)...),
 				results:    sig.results,
@@ -1345,29 +1363,8 @@ func (check *checker) rawExpr(x *operand, e ast.Expr, hint Type, iota int, cycle
 		} else {
 			// regular selector
 			x.mode = res.mode
-			if res.index == nil {
-				// method
-				var method Object
-				if t, ok := x.typ.Deref().(*Named); ok {
-					method = t.methods.Lookup(check.pkg, sel)
-				}
-				if method == nil {
-					if t, ok := utyp.Deref().(*Interface); ok {
-						method = t.methods.Lookup(check.pkg, sel)
-					}
-				}
-				if method != nil {
-					check.register(e.Sel, method)
-				} else if false {
-					// TODO(sqs): fix this - it occurs when we aren't propagating the methods of an embedded interface.
-					fmt.Printf("nil method: %s", e.Sel)
-				}
-			} else {
-				// field
-				if s, ok := utyp.Deref().(*Struct); ok {
-					check.register(e.Sel, s.Field(res.index[0]))
-				}
-			}
+			// res.obj is the (possibly promoted) field or method
+			check.register(e.Sel, res.obj)
 			x.typ = res.typ
 		}
 
@@ -1537,7 +1534,7 @@ func (check *checker) rawExpr(x *operand, e ast.Expr, hint Type, iota int, cycle
 		if typ == Typ[Invalid] {
 			goto Error
 		}
-		if method, wrongType := missingMethod(typ, T); method != nil {
+		if method, wrongType := missingMethod(typ, T, false); method != nil {
 			var msg string
 			if wrongType {
 				msg = "%s cannot have dynamic type %s (wrong type for method %s)"
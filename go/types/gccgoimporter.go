@@ -0,0 +1,1152 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements an Importer for gccgo-generated export data.
+
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/sourcegraph/go.tools/go/exact"
+)
+
+// A GccgoInstallation describes a gccgo installation; it is
+// used to determine where the export data of installed packages
+// may be found.
+type GccgoInstallation struct {
+	// Version of gcc (e.g. 4.8.0).
+	GccVersion string
+
+	// Target triple (e.g. x86_64-unknown-linux-gnu).
+	TargetTriple string
+
+	// Built-in library paths used by this installation.
+	LibPaths []string
+}
+
+// InitFromDriver queries the gccgo driver at gccgoPath for the
+// version, target triple and library paths of the installation.
+func (inst *GccgoInstallation) InitFromDriver(gccgoPath string) (err error) {
+	cmd := exec.Command(gccgoPath, "-###", "-S", "-x", "go", "-")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "Target: "):
+			inst.TargetTriple = line[8:]
+
+		case len(line) > 0 && line[0] == ' ':
+			args := strings.Fields(line)
+			for _, arg := range args[1:] {
+				if strings.HasPrefix(arg, "-L") {
+					inst.LibPaths = append(inst.LibPaths, arg[2:])
+				}
+			}
+		}
+	}
+	if err = cmd.Wait(); err != nil {
+		return
+	}
+
+	stdout, err := exec.Command(gccgoPath, "-dumpversion").Output()
+	if err != nil {
+		return
+	}
+	inst.GccVersion = strings.TrimSpace(string(stdout))
+
+	return
+}
+
+// SearchPaths returns the list of directories searched for the
+// export data of packages installed with inst.
+func (inst *GccgoInstallation) SearchPaths() (paths []string) {
+	for _, lpath := range inst.LibPaths {
+		spath := filepath.Join(lpath, "go", inst.GccVersion)
+		fi, err := os.Stat(spath)
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+		paths = append(paths, spath)
+
+		spath = filepath.Join(spath, inst.TargetTriple)
+		fi, err = os.Stat(spath)
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+		paths = append(paths, spath)
+	}
+
+	paths = append(paths, inst.LibPaths...)
+
+	return
+}
+
+// Importer returns an Importer that searches the directories
+// incpaths (typically specified with -I), followed by the search
+// paths of inst.
+func (inst *GccgoInstallation) Importer(incpaths []string) Importer {
+	return GccgoImporter(append(incpaths, inst.SearchPaths()...))
+}
+
+// GccgoImporter returns an Importer for gccgo-generated export data.
+// For an import path p, it looks for the files p, p.gox, libp.so,
+// libp.a and p.o, in that order, in each of the directories of the
+// searchpaths list. Export data may be stored in the .go_export
+// section of ELF object files and archives of object files, or in
+// raw form (as in .gox files).
+//
+func GccgoImporter(searchpaths []string) Importer {
+	return func(imports map[string]*Package, path string) (pkg *Package, err error) {
+		if path == "unsafe" {
+			return Unsafe, nil
+		}
+
+		// no need to re-import if the package was imported completely before
+		if pkg = imports[path]; pkg != nil && pkg.complete {
+			return
+		}
+
+		filename, err := findGccgoExportFile(searchpaths, path)
+		if err != nil {
+			return
+		}
+
+		r, err := openGccgoExportFile(filename)
+		if err != nil {
+			return
+		}
+
+		pkg, err = GccgoImportData(imports, filename, path, r)
+		if err != nil {
+			err = fmt.Errorf("reading export data: %s: %v", filename, err)
+		}
+
+		return
+	}
+}
+
+// GccgoImportData imports a package by reading the gccgo-generated export
+// data, adds the corresponding package object to the imports map indexed
+// by id, and returns the object. The data reader must provide the raw
+// export data (which starts with "v1;"); the filename is only used in
+// error messages.
+//
+func GccgoImportData(imports map[string]*Package, filename, id string, data io.Reader) (pkg *Package, err error) {
+	// support for gccgoParser error handling
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(importError) // will re-panic if r is not an importError
+		}
+	}()
+
+	var p gccgoParser
+	p.init(filename, id, data, imports)
+	pkg = p.parsePackage()
+
+	return
+}
+
+// findGccgoExportFile returns the name of the file containing the
+// export data for pkgpath.
+func findGccgoExportFile(searchpaths []string, pkgpath string) (string, error) {
+	for _, spath := range searchpaths {
+		pkgfullpath := filepath.Join(spath, pkgpath)
+		pkgdir, name := filepath.Split(pkgfullpath)
+
+		for _, filename := range [...]string{
+			pkgfullpath,
+			pkgfullpath + ".gox",
+			pkgdir + "lib" + name + ".so",
+			pkgdir + "lib" + name + ".a",
+			pkgfullpath + ".o",
+		} {
+			if fi, err := os.Stat(filename); err == nil && !fi.IsDir() {
+				return filename, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("can't find import: %s (tried %s)", pkgpath, strings.Join(searchpaths, ":"))
+}
+
+const (
+	gccgoV1Magic = "v1;\n"
+	archiveMagic = "!<arch>\n"
+	elfMagic     = "\x7fELF"
+)
+
+// openGccgoExportFile returns a reader for the raw export data
+// contained in the file filename.
+func openGccgoExportFile(filename string) (io.Reader, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, []byte(archiveMagic)) {
+		// use the first archive member with export data
+		err = errors.New("no export data found in archive")
+		for _, m := range archiveMembers(data[len(archiveMagic):]) {
+			var r io.Reader
+			if r, err = gccgoExportData(m); err == nil {
+				return r, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	r, err := gccgoExportData(data)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", filename, err)
+	}
+	return r, err
+}
+
+// gccgoExportData returns a reader for the raw export data contained
+// in the raw export data or ELF object file data.
+func gccgoExportData(data []byte) (io.Reader, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte(gccgoV1Magic)):
+		return bytes.NewReader(data), nil
+
+	case bytes.HasPrefix(data, []byte(elfMagic)):
+		f, err := elf.NewFile(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		sec := f.Section(".go_export")
+		if sec == nil {
+			return nil, errors.New(".go_export section not found")
+		}
+		return sec.Open(), nil
+	}
+
+	return nil, errors.New("unrecognized export data format")
+}
+
+// archiveMembers returns the contents of the members of the Unix archive
+// data (without the leading archive magic). Malformed trailing data is
+// ignored.
+func archiveMembers(data []byte) (members [][]byte) {
+	const hdrSize = 60 // name(16) date(12) uid(6) gid(6) mode(8) size(10) magic(2)
+	for len(data) >= hdrSize {
+		hdr := data[:hdrSize]
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || size < 0 || int64(len(data)-hdrSize) < size {
+			break
+		}
+		data = data[hdrSize:]
+		members = append(members, data[:size])
+		// members are aligned to even offsets
+		if size&1 != 0 {
+			size++
+		}
+		if int64(len(data)) < size {
+			break
+		}
+		data = data[size:]
+	}
+	return
+}
+
+// ----------------------------------------------------------------------------
+// gccgoParser
+
+// gccgoParser parses the export data produced by gccgo
+// and populates the package scope with the results.
+type gccgoParser struct {
+	scanner scanner.Scanner
+	tok     rune   // current token
+	lit     string // literal string; only valid for Ident, Int, Float, String tokens
+
+	id      string              // package id of imported package
+	pkgname string              // package name of imported package
+	pkgpath string              // package path of imported package, as used in the export data
+	pkg     *Package            // imported package
+	imports map[string]*Package // package id -> package object
+	pkgs    map[string]*Package // package path (as used in the export data) -> package object
+
+	typeMap    map[int]Type          // type number -> type
+	embeddeds  map[*Interface][]Type // interface -> embedded interfaces
+	underlying map[*Named]*Named     // named type -> named type whose underlying type it shares
+}
+
+func (p *gccgoParser) init(filename, id string, src io.Reader, imports map[string]*Package) {
+	p.scanner.Init(src)
+	p.scanner.Error = func(_ *scanner.Scanner, msg string) { p.error(msg) }
+	p.scanner.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings | scanner.ScanComments | scanner.SkipComments
+	p.scanner.Whitespace = 1<<'\t' | 1<<'\n' | 1<<' '
+	p.scanner.Filename = filename // for good error messages
+	p.next()
+	p.id = id
+	p.imports = imports
+	p.pkgs = make(map[string]*Package)
+	p.typeMap = make(map[int]Type)
+	p.embeddeds = make(map[*Interface][]Type)
+	p.underlying = make(map[*Named]*Named)
+}
+
+func (p *gccgoParser) next() {
+	p.tok = p.scanner.Scan()
+	switch p.tok {
+	case scanner.Ident, scanner.Int, scanner.Float, scanner.String, '·':
+		p.lit = p.scanner.TokenText()
+	default:
+		p.lit = ""
+	}
+}
+
+func (p *gccgoParser) error(err interface{}) {
+	if s, ok := err.(string); ok {
+		err = errors.New(s)
+	}
+	// panic with a runtime.Error if err is not an error
+	panic(importError{p.scanner.Pos(), err.(error)})
+}
+
+func (p *gccgoParser) errorf(format string, args ...interface{}) {
+	p.error(fmt.Sprintf(format, args...))
+}
+
+func (p *gccgoParser) expect(tok rune) string {
+	lit := p.lit
+	if p.tok != tok {
+		p.errorf("expected %s, got %s (%s)", scanner.TokenString(tok), scanner.TokenString(p.tok), lit)
+	}
+	p.next()
+	return lit
+}
+
+func (p *gccgoParser) expectKeyword(keyword string) {
+	lit := p.expect(scanner.Ident)
+	if lit != keyword {
+		p.errorf("expected keyword %s, got %q", keyword, lit)
+	}
+}
+
+// parseString parses a quoted string.
+func (p *gccgoParser) parseString() string {
+	str, err := strconv.Unquote(p.expect(scanner.String))
+	if err != nil {
+		p.error(err)
+	}
+	return str
+}
+
+// unquotedString     = { unquotedStringChar } .
+// unquotedStringChar = <neither a whitespace nor a ';' char> .
+//
+func (p *gccgoParser) parseUnquotedString() string {
+	if p.tok == scanner.EOF {
+		p.error("unexpected EOF")
+	}
+	var buf bytes.Buffer
+	buf.WriteString(p.scanner.TokenText())
+	// This loop needs to examine each character before deciding whether to consume it.
+	// If we see a semicolon, we need to let it be consumed by p.next().
+	for ch := p.scanner.Peek(); ch != ';' && ch != scanner.EOF && p.scanner.Whitespace&(1<<uint(ch)) == 0; ch = p.scanner.Peek() {
+		buf.WriteRune(ch)
+		p.scanner.Next()
+	}
+	p.next()
+	return buf.String()
+}
+
+// parseInt parses a (possibly negative) decimal integer.
+func (p *gccgoParser) parseInt() int64 {
+	sign := ""
+	if p.tok == '-' {
+		p.next()
+		sign = "-"
+	}
+	n, err := strconv.ParseInt(sign+p.expect(scanner.Int), 10, 64)
+	if err != nil {
+		p.error(err)
+	}
+	return n
+}
+
+// getPkg returns the package for a given package path as used in the
+// export data. If the package is not found, it is created and added to
+// the p.imports map. If name is empty, the package name is assumed to
+// be the last element of the path.
+//
+func (p *gccgoParser) getPkg(path, name string) *Package {
+	if path == "unsafe" {
+		return Unsafe
+	}
+	if pkg := p.pkgs[path]; pkg != nil {
+		return pkg
+	}
+	pkg := p.imports[path]
+	if pkg == nil {
+		if name == "" {
+			name = path[strings.LastIndex(path, "/")+1:]
+		}
+		pkg = &Package{name: name, path: path, scope: new(Scope)}
+		p.imports[path] = pkg
+	}
+	p.pkgs[path] = pkg
+	return pkg
+}
+
+// splitQualifiedName splits a name of the form "pkgpath.name" or
+// ".pkgpath.name" into its package path and name components. The
+// package path may itself contain dots.
+func splitQualifiedName(s string) (path, name string) {
+	s = strings.TrimPrefix(s, ".")
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return "", s
+}
+
+// Name = QualifiedName | "?" .
+// QualifiedName = "." PackagePath "." identifier | identifier .
+//
+// Unqualified names are exported names and are returned with a nil package,
+// like exported names in the gc export data. For unexported names, the
+// corresponding package is returned.
+//
+func (p *gccgoParser) parseName() (pkg *Package, name string) {
+	if p.tok == '?' {
+		// anonymous
+		p.next()
+		return
+	}
+	path, name := splitQualifiedName(p.parseUnquotedString())
+	switch {
+	case path != "":
+		pkg = p.getPkg(path, "")
+	case !ast.IsExported(name):
+		pkg = p.pkg
+	}
+	return
+}
+
+// ExportedName = string_lit .
+//
+func (p *gccgoParser) parseExportedName() (pkg *Package, name string) {
+	path, name := splitQualifiedName(p.parseString())
+	if path == "" {
+		return p.pkg, name
+	}
+	return p.getPkg(path, ""), name
+}
+
+// ----------------------------------------------------------------------------
+// Types
+
+// gccgo encodes predeclared types as negative type numbers;
+// the codes below are the negated values.
+const (
+	gccgoBuiltinINT8       = 1
+	gccgoBuiltinINT16      = 2
+	gccgoBuiltinINT32      = 3
+	gccgoBuiltinINT64      = 4
+	gccgoBuiltinUINT8      = 5
+	gccgoBuiltinUINT16     = 6
+	gccgoBuiltinUINT32     = 7
+	gccgoBuiltinUINT64     = 8
+	gccgoBuiltinFLOAT32    = 9
+	gccgoBuiltinFLOAT64    = 10
+	gccgoBuiltinINT        = 11
+	gccgoBuiltinUINT       = 12
+	gccgoBuiltinUINTPTR    = 13
+	gccgoBuiltinBOOL       = 15
+	gccgoBuiltinSTRING     = 16
+	gccgoBuiltinCOMPLEX64  = 17
+	gccgoBuiltinCOMPLEX128 = 18
+	gccgoBuiltinERROR      = 19
+	gccgoBuiltinBYTE       = 20
+	gccgoBuiltinRUNE       = 21
+)
+
+var gccgoBuiltinNames = map[int64]string{
+	gccgoBuiltinINT8:       "int8",
+	gccgoBuiltinINT16:      "int16",
+	gccgoBuiltinINT32:      "int32",
+	gccgoBuiltinINT64:      "int64",
+	gccgoBuiltinUINT8:      "uint8",
+	gccgoBuiltinUINT16:     "uint16",
+	gccgoBuiltinUINT32:     "uint32",
+	gccgoBuiltinUINT64:     "uint64",
+	gccgoBuiltinFLOAT32:    "float32",
+	gccgoBuiltinFLOAT64:    "float64",
+	gccgoBuiltinINT:        "int",
+	gccgoBuiltinUINT:       "uint",
+	gccgoBuiltinUINTPTR:    "uintptr",
+	gccgoBuiltinBOOL:       "bool",
+	gccgoBuiltinSTRING:     "string",
+	gccgoBuiltinCOMPLEX64:  "complex64",
+	gccgoBuiltinCOMPLEX128: "complex128",
+	gccgoBuiltinERROR:      "error",
+	gccgoBuiltinBYTE:       "byte",
+	gccgoBuiltinRUNE:       "rune",
+}
+
+func (p *gccgoParser) lookupBuiltinType(n int64) Type {
+	if name, ok := gccgoBuiltinNames[n]; ok {
+		if obj, ok := Universe.Lookup(name).(*TypeName); ok {
+			return obj.typ
+		}
+	}
+	p.errorf("unknown builtin type -%d", n)
+	return nil
+}
+
+// Type = "<" "type" ( "-" int | int [ TypeDefinition ] ) ">" .
+//
+func (p *gccgoParser) parseType() (t Type) {
+	p.expect('<')
+	p.expectKeyword("type")
+
+	switch p.tok {
+	case scanner.Int:
+		n := p.parseInt()
+		if p.tok == '>' {
+			t = p.typeMap[int(n)]
+			if t == nil {
+				p.errorf("undefined type %d", n)
+			}
+		} else {
+			t = p.parseTypeDefinition(int(n))
+		}
+
+	case '-':
+		p.next()
+		t = p.lookupBuiltinType(p.parseInt())
+
+	default:
+		p.errorf("expected type number, got %s (%q)", scanner.TokenString(p.tok), p.lit)
+	}
+
+	p.expect('>')
+	return
+}
+
+// TypeDefinition = NamedType | MapType | ChanType | StructType | InterfaceType | PointerType | ArrayOrSliceType | FunctionType .
+//
+func (p *gccgoParser) parseTypeDefinition(n int) (t Type) {
+	switch p.tok {
+	case scanner.String:
+		t = p.parseNamedType(n)
+	case scanner.Ident:
+		switch p.lit {
+		case "map":
+			t = p.parseMapType()
+		case "chan":
+			t = p.parseChanType()
+		case "struct":
+			t = p.parseStructType()
+		case "interface":
+			t = p.parseInterfaceType()
+		}
+	case '*':
+		t = p.parsePointerType()
+	case '[':
+		t = p.parseArrayOrSliceType()
+	case '(':
+		t = p.parseFunctionType()
+	}
+	if t == nil {
+		p.errorf("expected type definition, got %s (%q)", scanner.TokenString(p.tok), p.lit)
+	}
+	p.typeMap[n] = t
+	return
+}
+
+// NamedType = TypeName Type { Method } .
+// TypeName  = ExportedName .
+// Method    = "func" "(" Param ")" Name ParamList ResultList ";" .
+//
+func (p *gccgoParser) parseNamedType(n int) Type {
+	pkg, name := p.parseExportedName()
+	obj := declTypeName(pkg, name)
+	typ := obj.typ
+	p.typeMap[n] = typ
+
+	nt, ok := typ.(*Named)
+	if !ok {
+		// This can happen for unsafe.Pointer, which is
+		// a TypeName holding a Basic type.
+		p.parseType()
+		return typ
+	}
+
+	// The type object may have been imported before and thus already
+	// have a type associated with it. We still need to parse the type
+	// structure, but throw it away if the object already has a type.
+	underlying := p.parseType()
+	if nt.underlying == nil {
+		switch u := underlying.(type) {
+		case *Named:
+			if u.underlying == nil {
+				// defined later; fixed up at the end
+				p.underlying[nt] = u
+			} else {
+				nt.underlying = u.underlying
+			}
+		default:
+			nt.underlying = u
+		}
+	}
+
+	for p.tok == scanner.Ident {
+		// associated methods
+		p.expectKeyword("func")
+		p.expect('(')
+		recv, _ := p.parseParam()
+		p.expect(')')
+		pkg, name := p.parseName()
+		sig := p.parseFunctionType()
+		sig.recv = recv
+		p.expect(';')
+		// add method to type unless type was imported before
+		// and method exists already
+		nt.methods.Insert(&Func{pkg, name, sig, nil})
+	}
+
+	return nt
+}
+
+// MapType = "map" "[" Type "]" Type .
+//
+func (p *gccgoParser) parseMapType() Type {
+	p.expectKeyword("map")
+	p.expect('[')
+	key := p.parseType()
+	p.expect(']')
+	elt := p.parseType()
+	return &Map{key: key, elt: elt}
+}
+
+// ChanType = "chan" [ "<-" | "-<" ] Type .
+//
+func (p *gccgoParser) parseChanType() Type {
+	p.expectKeyword("chan")
+	dir := ast.SEND | ast.RECV
+	switch p.tok {
+	case '-':
+		p.next()
+		p.expect('<')
+		dir = ast.SEND
+	case '<':
+		// don't consume '<' if it belongs to Type
+		if p.scanner.Peek() == '-' {
+			p.next()
+			p.expect('-')
+			dir = ast.RECV
+		}
+	}
+	elt := p.parseType()
+	return &Chan{dir: dir, elt: elt}
+}
+
+// Field = Name Type [ string_lit ] .
+//
+func (p *gccgoParser) parseField() (*Field, string) {
+	var f Field
+	f.pkg, f.name = p.parseName()
+	f.typ = p.parseType()
+	tag := ""
+	if p.tok == scanner.String {
+		tag = p.parseString()
+	}
+	if f.name == "" {
+		// anonymous field - typ must be T or *T and T must be a type name
+		switch typ := f.typ.Deref().(type) {
+		case *Basic: // basic types are named types
+			f.name = typ.name
+		case *Named:
+			f.name = typ.obj.name
+		default:
+			p.errorf("anonymous field expected")
+		}
+		f.IsAnonymous = true
+	}
+	return &f, tag
+}
+
+// StructType = "struct" "{" { Field ";" } "}" .
+//
+func (p *gccgoParser) parseStructType() Type {
+	var fields []*Field
+	var tags []string
+
+	p.expectKeyword("struct")
+	p.expect('{')
+	for p.tok != '}' && p.tok != scanner.EOF {
+		fld, tag := p.parseField()
+		p.expect(';')
+		if tag != "" && tags == nil {
+			tags = make([]string, len(fields))
+		}
+		if tags != nil {
+			tags = append(tags, tag)
+		}
+		fields = append(fields, fld)
+	}
+	p.expect('}')
+
+	return &Struct{fields: fields, tags: tags}
+}
+
+// InterfaceType = "interface" "{" { ( "?" Type | Name FunctionType ) ";" } "}" .
+//
+// Embedded interfaces may not be fully defined at this point; their methods
+// are added to the interface when the entire package has been parsed.
+//
+func (p *gccgoParser) parseInterfaceType() Type {
+	t := new(Interface)
+
+	p.expectKeyword("interface")
+	p.expect('{')
+	for p.tok != '}' && p.tok != scanner.EOF {
+		if p.tok == '?' {
+			p.next()
+			p.embeddeds[t] = append(p.embeddeds[t], p.parseType())
+		} else {
+			pkg, name := p.parseName()
+			sig := p.parseFunctionType()
+			if alt := t.methods.Insert(&Func{pkg, name, sig, nil}); alt != nil {
+				p.errorf("multiple methods named %s", name)
+			}
+		}
+		p.expect(';')
+	}
+	p.expect('}')
+
+	return t
+}
+
+// PointerType = "*" ( "any" | Type ) .
+//
+func (p *gccgoParser) parsePointerType() Type {
+	p.expect('*')
+	if p.tok == scanner.Ident {
+		p.expectKeyword("any")
+		return Typ[UnsafePointer]
+	}
+	return &Pointer{base: p.parseType()}
+}
+
+// ArrayOrSliceType = "[" [ int ] "]" Type .
+//
+func (p *gccgoParser) parseArrayOrSliceType() Type {
+	p.expect('[')
+	if p.tok == ']' {
+		p.next()
+		return &Slice{elt: p.parseType()}
+	}
+
+	n := p.parseInt()
+	p.expect(']')
+	return &Array{len: n, elt: p.parseType()}
+}
+
+// Param = Name [ "..." ] Type .
+//
+func (p *gccgoParser) parseParam() (par *Var, isVariadic bool) {
+	_, name := p.parseName()
+	if name == "" {
+		name = "_" // cannot access unnamed identifiers
+	}
+	if p.tok == '.' {
+		p.next()
+		p.expect('.')
+		p.expect('.')
+		isVariadic = true
+	}
+	typ := p.parseType()
+	par = &Var{name: name, typ: typ} // Pkg == nil
+	return
+}
+
+// ParamList = "(" [ { Param "," } Param ] ")" .
+//
+func (p *gccgoParser) parseParamList() (list []*Var, isVariadic bool) {
+	p.expect('(')
+	for p.tok != ')' && p.tok != scanner.EOF {
+		if len(list) > 0 {
+			p.expect(',')
+		}
+		par, variadic := p.parseParam()
+		list = append(list, par)
+		if variadic {
+			if isVariadic {
+				p.error("... not on final argument")
+			}
+			isVariadic = true
+		}
+	}
+	p.expect(')')
+
+	return
+}
+
+// ResultList = Type | ParamList .
+//
+func (p *gccgoParser) parseResultList() []*Var {
+	switch p.tok {
+	case '<':
+		return []*Var{{name: "_", typ: p.parseType()}}
+	case '(':
+		list, _ := p.parseParamList()
+		return list
+	}
+	return nil
+}
+
+// FunctionType = ParamList ResultList .
+//
+func (p *gccgoParser) parseFunctionType() *Signature {
+	params, isVariadic := p.parseParamList()
+	results := p.parseResultList()
+	return &Signature{params: NewTuple(params...), results: NewTuple(results...), isVariadic: isVariadic}
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+// ConstValue     = string | "false" | "true" | [ "-" ] ( int [ "'" ] | FloatOrComplex ) .
+// FloatOrComplex = float [ "i" | ( "+" | "-" ) float "i" ] .
+//
+func (p *gccgoParser) parseConstValue() (val exact.Value, typ Type) {
+	switch p.tok {
+	case scanner.String:
+		val = exact.MakeString(p.parseString())
+		typ = Typ[UntypedString]
+		return
+
+	case scanner.Ident:
+		b := false
+		switch p.lit {
+		case "false":
+		case "true":
+			b = true
+		default:
+			p.errorf("expected const value, got %s (%q)", scanner.TokenString(p.tok), p.lit)
+		}
+		p.next()
+		val = exact.MakeBool(b)
+		typ = Typ[UntypedBool]
+		return
+	}
+
+	sign := ""
+	if p.tok == '-' {
+		p.next()
+		sign = "-"
+	}
+
+	switch p.tok {
+	case scanner.Int:
+		val = exact.MakeFromLiteral(sign+p.lit, token.INT)
+		if val == nil {
+			p.error("could not parse integer literal")
+		}
+		p.next()
+		if p.tok == '\'' {
+			p.next()
+			typ = Typ[UntypedRune]
+		} else {
+			typ = Typ[UntypedInt]
+		}
+
+	case scanner.Float:
+		re := sign + p.lit
+		p.next()
+
+		var im string
+		switch p.tok {
+		case '+':
+			p.next()
+			im = p.expect(scanner.Float)
+		case '-':
+			p.next()
+			im = "-" + p.expect(scanner.Float)
+		case scanner.Ident:
+			// re is in fact the imaginary component; expect "i" below
+			im = re
+			re = "0"
+		default:
+			val = exact.MakeFromLiteral(re, token.FLOAT)
+			if val == nil {
+				p.error("could not parse float literal")
+			}
+			typ = Typ[UntypedFloat]
+			return
+		}
+
+		p.expectKeyword("i")
+		reval := exact.MakeFromLiteral(re, token.FLOAT)
+		imval := exact.MakeFromLiteral(im+"i", token.IMAG)
+		if reval == nil || imval == nil {
+			p.error("could not parse complex literal")
+		}
+		val = exact.BinaryOp(reval, token.ADD, imval)
+		typ = Typ[UntypedComplex]
+
+	default:
+		p.errorf("expected const value, got %s (%q)", scanner.TokenString(p.tok), p.lit)
+	}
+
+	return
+}
+
+// Const = Name [ Type ] "=" ConstValue .
+//
+func (p *gccgoParser) parseConst() {
+	_, name := p.parseName()
+	obj := declConst(p.pkg, name)
+	var typ Type
+	if p.tok == '<' {
+		typ = p.parseType()
+	}
+	p.expect('=')
+	val, vtyp := p.parseConstValue()
+	if typ == nil {
+		typ = vtyp
+	}
+	obj.typ = typ
+	obj.val = val
+}
+
+// Var = Name Type .
+//
+func (p *gccgoParser) parseVar() {
+	_, name := p.parseName()
+	declVar(p.pkg, name).typ = p.parseType()
+}
+
+// Func = Name FunctionType .
+//
+func (p *gccgoParser) parseFunc() {
+	_, name := p.parseName()
+	typ := p.parseFunctionType()
+	declFunc(p.pkg, name).typ = typ
+}
+
+// InitDataDirective = "v1" ";" |
+//                     "priority" int ";" |
+//                     "init" { PackageInit } ";" |
+//                     "checksum" unquotedString ";" .
+// PackageInit       = unquotedString unquotedString int .
+//
+func (p *gccgoParser) parseInitDataDirective() {
+	if p.tok != scanner.Ident {
+		// unexpected token kind; panic
+		p.expect(scanner.Ident)
+	}
+
+	switch p.lit {
+	case "v1":
+		p.next()
+		p.expect(';')
+
+	case "priority":
+		p.next()
+		p.parseInt()
+		p.expect(';')
+
+	case "init":
+		p.next()
+		for p.tok != ';' && p.tok != scanner.EOF {
+			p.parseUnquotedString()
+			p.parseUnquotedString()
+			p.parseInt()
+		}
+		p.expect(';')
+
+	case "checksum":
+		// Don't let the scanner try to parse the checksum as a number.
+		defer func(mode uint) {
+			p.scanner.Mode = mode
+		}(p.scanner.Mode)
+		p.scanner.Mode &^= scanner.ScanInts | scanner.ScanFloats
+		p.next()
+		p.parseUnquotedString()
+		p.expect(';')
+
+	default:
+		p.errorf("unexpected identifier: %q", p.lit)
+	}
+}
+
+// maybeCreatePackage creates the imported package once
+// both its name and path are known.
+func (p *gccgoParser) maybeCreatePackage() {
+	if p.pkgname != "" && p.pkgpath != "" && p.pkg == nil {
+		p.pkg = p.getPkg(p.id, p.pkgname)
+		p.pkgs[p.pkgpath] = p.pkg
+	}
+}
+
+// Directive = InitDataDirective |
+//             "package" unquotedString ";" |
+//             "pkgpath" unquotedString ";" |
+//             "prefix" unquotedString ";" |
+//             "import" unquotedString unquotedString string ";" |
+//             "func" Func ";" |
+//             "type" Type ";" |
+//             "var" Var ";" |
+//             "const" Const ";" .
+//
+func (p *gccgoParser) parseDirective() {
+	if p.tok != scanner.Ident {
+		// unexpected token kind; panic
+		p.expect(scanner.Ident)
+	}
+
+	switch p.lit {
+	case "v1", "priority", "init", "checksum":
+		p.parseInitDataDirective()
+
+	case "package":
+		p.next()
+		p.pkgname = p.parseUnquotedString()
+		if p.pkgpath == "" {
+			// older export data has no pkgpath directive
+			p.pkgpath = p.id
+		}
+		p.maybeCreatePackage()
+		p.expect(';')
+
+	case "pkgpath":
+		p.next()
+		p.pkgpath = p.parseUnquotedString()
+		p.maybeCreatePackage()
+		if p.pkg != nil {
+			p.pkgs[p.pkgpath] = p.pkg
+		}
+		p.expect(';')
+
+	case "prefix":
+		p.next()
+		p.parseUnquotedString()
+		p.expect(';')
+
+	case "import":
+		p.next()
+		pkgname := p.parseUnquotedString()
+		pkgpath := p.parseUnquotedString()
+		p.getPkg(pkgpath, pkgname)
+		p.parseString()
+		p.expect(';')
+
+	default:
+		if p.pkg == nil {
+			p.errorf("declaration before package clause: %q", p.lit)
+		}
+		switch p.lit {
+		case "func":
+			p.next()
+			p.parseFunc()
+		case "type":
+			p.next()
+			p.parseType()
+		case "var":
+			p.next()
+			p.parseVar()
+		case "const":
+			p.next()
+			p.parseConst()
+		default:
+			p.errorf("unexpected identifier: %q", p.lit)
+		}
+		p.expect(';')
+	}
+}
+
+// completeTypes sets the underlying types of named types whose
+// underlying types were named types defined later in the export
+// data, and adds the methods of embedded interfaces.
+func (p *gccgoParser) completeTypes() {
+	for nt, u := range p.underlying {
+		seen := map[*Named]bool{nt: true}
+		for u.underlying == nil {
+			if seen[u] {
+				p.errorf("invalid recursive type %s", nt.obj.name)
+			}
+			seen[u] = true
+			v := p.underlying[u]
+			if v == nil {
+				p.errorf("underlying type of %s not defined", nt.obj.name)
+			}
+			u = v
+		}
+		nt.underlying = u.underlying
+	}
+
+	done := make(map[*Interface]bool)
+	var complete func(t *Interface)
+	complete = func(t *Interface) {
+		if done[t] {
+			return
+		}
+		done[t] = true
+		for _, e := range p.embeddeds[t] {
+			et, ok := e.Underlying().(*Interface)
+			if !ok {
+				p.errorf("embedded type %s is not an interface", e)
+			}
+			complete(et)
+			for _, m := range et.methods.entries {
+				t.methods.Insert(m)
+			}
+		}
+	}
+	for t := range p.embeddeds {
+		complete(t)
+	}
+}
+
+// Package = { Directive } .
+//
+func (p *gccgoParser) parsePackage() *Package {
+	for p.tok != scanner.EOF {
+		p.parseDirective()
+	}
+
+	if p.pkg == nil {
+		p.error("missing package clause")
+	}
+
+	if n := p.scanner.ErrorCount; n != 0 {
+		p.errorf("expected no scanner errors, got %d", n)
+	}
+
+	p.completeTypes()
+
+	// package was imported completely and without errors
+	p.pkg.complete = true
+
+	return p.pkg
+}
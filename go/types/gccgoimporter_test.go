@@ -0,0 +1,187 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gccgoExport is export data in the format written by gccgo for the package
+//
+//	package p
+//
+//	import "io"
+//
+//	const C = 42
+//	const R = 'x'
+//	const S = "hello"
+//	const Z = 1.5+2i
+//	const N int64 = -7
+//	var V []*T
+//	type T struct { x int; io.Reader "tag"; U }
+//	type U int
+//	func (T) M(x int, y ...string) (bool, error)
+//	func (*T) m()
+//	type I interface { io.Reader; N() chan<- int }
+//	func F(m map[string]<-chan T) U
+//
+var gccgoExport = `v1;
+package p;
+pkgpath p;
+priority 2;
+import io io "io";
+init p p..import 2 io io..import 1;
+checksum 5F7E0B6C4A1C29F5AF5A0B4D8B5A67AD3EA4D5BF;
+const C = 42;
+const N <type -4> = -7;
+const R = 120';
+const S = "hello";
+const Z = 1.5E0+2E0i;
+func F (m <type 1 map [<type -16>] <type 2 chan <- <type 3 "p.T" <type 4 struct { .p.x <type -11>; ? <type 5 "io.Reader" <type 6 interface { Read (p <type 7 [] <type -20>>) (n <type -11>, err <type -19>); }>> "tag"; ? <type 8 "p.U" <type -11>>; }>
+ func (? <type 3>) M (x <type -11>, y ... <type -16>) (? <type -15>, ? <type -19>);
+ func (? <type 9 *<type 3>>) .p.m ();
+>>>) <type 8>;
+type <type 10 "p.I" <type 11 interface { ? <type 5>; N () <type 12 chan -< <type -11>>; }>>;
+var V <type 13 [] <type 9>>;
+`
+
+func TestGccgoImportData(t *testing.T) {
+	imports := make(map[string]*Package)
+	pkg, err := GccgoImportData(imports, "p.gox", "p", strings.NewReader(gccgoExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name() != "p" || pkg.Path() != "p" || !pkg.complete {
+		t.Fatalf("got package %s (path %s, complete = %v); want p", pkg.Name(), pkg.Path(), pkg.complete)
+	}
+	if imports["io"] == nil {
+		t.Errorf("package io not recorded in imports map")
+	}
+
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"C", "untyped integer 42"},
+		{"N", "int64 -7"},
+		{"R", "untyped rune 120"},
+		{"S", `untyped string "hello"`},
+		{"Z", "untyped complex (3/2 + 2i)"},
+		{"V", "[]*p.T"},
+		{"T", "struct{x int; io.Reader \"tag\"; p.U}"},
+		{"U", "int"},
+		{"I", "interface{N() (_ chan<- int); Read(p []byte) (n int, err error)}"},
+		{"F", "func(m map[string]<-chan p.T) (_ p.U)"},
+	} {
+		obj := pkg.scope.Lookup(test.name)
+		if obj == nil {
+			t.Errorf("%s not found", test.name)
+			continue
+		}
+		var got string
+		switch obj := obj.(type) {
+		case *Const:
+			got = fmt.Sprintf("%s %s", obj.typ, obj.val)
+		case *TypeName:
+			got = obj.typ.Underlying().String()
+		default:
+			got = obj.Type().String()
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s; want %s", test.name, got, test.want)
+		}
+	}
+
+	// methods
+	T := pkg.scope.Lookup("T").Type().(*Named)
+	if n := len(T.methods.entries); n != 2 {
+		t.Fatalf("T has %d methods; want 2", n)
+	}
+	if m, ok := T.methods.Lookup(nil, "M").(*Func); !ok {
+		t.Errorf("method T.M not found")
+	} else if got, want := m.typ.String(), "func(x int, y ...string) (_ bool, _ error)"; got != want {
+		t.Errorf("T.M: got %s; want %s", got, want)
+	}
+	if T.methods.Lookup(pkg, "m") == nil {
+		t.Errorf("method (*T).m not found")
+	}
+}
+
+func TestGccgoImportErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"v1;\nconst C = 1;\n",
+		"v1;\npackage p;\nfunc F () <type 1>;\n",
+		"v1;\npackage p;\ntype <type 1 \"p.T\" <type -99>>;\n",
+		"v1;\npackage p;\ntype <type 1 \"p.A\" <type 2 \"p.B\" <type 1>>>;\n", // invalid recursive type
+	} {
+		if _, err := GccgoImportData(make(map[string]*Package), "p.gox", "p", strings.NewReader(src)); err == nil {
+			t.Errorf("%q: expected error", src)
+		}
+	}
+}
+
+// archive returns a Unix archive containing the given members.
+func archive(members map[string]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(archiveMagic)
+	for name, data := range members {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", name+"/", 0, 0, 0, 0644, len(data))
+		buf.WriteString(data)
+		if len(data)&1 != 0 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestGccgoImporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gccgoimporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{
+		"p.gox":   []byte(gccgoExport),
+		"libq.a":  archive(map[string]string{"q.o": strings.NewReplacer("pkgpath p;", "pkgpath q;", `"p.`, `"q.`, ".p.", ".q.").Replace(gccgoExport)}),
+		"libr.a":  archive(map[string]string{"r.o": "not export data"}),
+		"bad.gox": []byte("garbage"),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imp := GccgoImporter([]string{dir})
+	imports := make(map[string]*Package)
+	for _, path := range []string{"p", "q"} {
+		pkg, err := imp(imports, path)
+		if err != nil {
+			t.Errorf("import %s: %s", path, err)
+			continue
+		}
+		if pkg.scope.Lookup("T") == nil {
+			t.Errorf("import %s: T not found", path)
+		}
+		if imports[path] != pkg {
+			t.Errorf("import %s: package not recorded in imports map", path)
+		}
+	}
+	for _, path := range []string{"r", "bad", "missing"} {
+		if _, err := imp(imports, path); err == nil {
+			t.Errorf("import %s: expected error", path)
+		}
+	}
+	if pkg, err := imp(imports, "unsafe"); pkg != Unsafe || err != nil {
+		t.Errorf("import unsafe: got %v, %v", pkg, err)
+	}
+}
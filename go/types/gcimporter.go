@@ -8,6 +8,7 @@ package types
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"go/ast"
@@ -83,7 +84,19 @@ func FindPkg(path, srcDir string) (filename, id string) {
 // can be used directly, and there is no need to call this function (but
 // there is also no harm but for extra time used).
 //
+// The export data is read completely, but declarations are only indexed:
+// an object is created when it is looked up for the first time (or when
+// all objects are requested via Package.Scope). Errors in the declaration
+// of an object are therefore only detected when the object is looked up;
+// they are reported by Package.ImportError.
+//
 func GcImportData(imports map[string]*Package, filename, id string, data *bufio.Reader) (pkg *Package, err error) {
+	return gcImportData(imports, filename, id, data, true)
+}
+
+// gcImportData is like GcImportData; if lazy is not set, all
+// declarations are parsed and materialized immediately.
+func gcImportData(imports map[string]*Package, filename, id string, data *bufio.Reader, lazy bool) (pkg *Package, err error) {
 	// support for gcParser error handling
 	defer func() {
 		if r := recover(); r != nil {
@@ -91,6 +104,15 @@ func GcImportData(imports map[string]*Package, filename, id string, data *bufio.
 		}
 	}()
 
+	// importing may declare objects in the scopes of imported packages
+	lazyMu.Lock()
+	defer lazyMu.Unlock()
+
+	if lazy {
+		pkg = indexExport(imports, filename, id, data)
+		return
+	}
+
 	var p gcParser
 	p.init(filename, id, data, imports)
 	pkg = p.parseExport()
@@ -202,45 +224,45 @@ func declConst(pkg *Package, name string) *Const {
 	// the constant may have been imported before - if it exists
 	// already in the respective scope, return that constant
 	scope := pkg.scope
-	if obj := scope.Lookup(name); obj != nil {
+	if obj := scope.lookupLazy(name); obj != nil {
 		return obj.(*Const)
 	}
 	// otherwise create a new constant and insert it into the scope
 	obj := &Const{pkg: pkg, name: name}
-	scope.Insert(obj)
+	scope.insert(obj)
 	return obj
 }
 
 func declTypeName(pkg *Package, name string) *TypeName {
 	scope := pkg.scope
-	if obj := scope.Lookup(name); obj != nil {
+	if obj := scope.lookupLazy(name); obj != nil {
 		return obj.(*TypeName)
 	}
 	obj := &TypeName{pkg: pkg, name: name}
 	// a named type may be referred to before the underlying type
 	// is known - set it up
 	obj.typ = &Named{obj: obj}
-	scope.Insert(obj)
+	scope.insert(obj)
 	return obj
 }
 
 func declVar(pkg *Package, name string) *Var {
 	scope := pkg.scope
-	if obj := scope.Lookup(name); obj != nil {
+	if obj := scope.lookupLazy(name); obj != nil {
 		return obj.(*Var)
 	}
 	obj := &Var{pkg: pkg, name: name}
-	scope.Insert(obj)
+	scope.insert(obj)
 	return obj
 }
 
 func declFunc(pkg *Package, name string) *Func {
 	scope := pkg.scope
-	if obj := scope.Lookup(name); obj != nil {
+	if obj := scope.lookupLazy(name); obj != nil {
 		return obj.(*Func)
 	}
 	obj := &Func{pkg: pkg, name: name}
-	scope.Insert(obj)
+	scope.insert(obj)
 	return obj
 }
 
@@ -917,10 +939,9 @@ func (p *gcParser) parseDecl() {
 // ----------------------------------------------------------------------------
 // Export
 
-// Export        = "PackageClause { Decl } "$$" .
 // PackageClause = "package" PackageName [ "safe" ] "\n" .
 //
-func (p *gcParser) parseExport() *Package {
+func (p *gcParser) parsePackageClause() *Package {
 	p.expectKeyword("package")
 	name := p.parsePackageName()
 	if p.tok != '\n' {
@@ -931,7 +952,13 @@ func (p *gcParser) parseExport() *Package {
 	}
 	p.expect('\n')
 
-	pkg := p.getPkg(p.id, name)
+	return p.getPkg(p.id, name)
+}
+
+// Export = PackageClause { Decl } "$$" .
+//
+func (p *gcParser) parseExport() *Package {
+	pkg := p.parsePackageClause()
 
 	for p.tok != '$' && p.tok != scanner.EOF {
 		p.parseDecl()
@@ -952,3 +979,143 @@ func (p *gcParser) parseExport() *Package {
 
 	return pkg
 }
+
+// ----------------------------------------------------------------------------
+// Lazy importing
+
+// A gcDecl is a single declaration (one line) of gc-generated export data.
+type gcDecl struct {
+	filename string              // for error messages
+	id       string              // package id of imported package
+	imports  map[string]*Package // package id -> package object
+	line     int                 // line number of declaration, for error messages
+	offset   int                 // byte offset of declaration, for error messages
+	src      []byte              // declaration source, including the terminating newline
+}
+
+// parser returns a gcParser for the declaration d.
+func (d *gcDecl) parser() *gcParser {
+	p := new(gcParser)
+	p.init(d.filename, d.id, bytes.NewReader(d.src), d.imports)
+	return p
+}
+
+// fixPos adjusts the position of an importError raised while
+// parsing d such that it is relative to the export data.
+func (d *gcDecl) fixPos() {
+	if r := recover(); r != nil {
+		if err, ok := r.(importError); ok {
+			err.pos.Line += d.line - 1
+			err.pos.Offset += d.offset
+			r = err
+		}
+		panic(r)
+	}
+}
+
+// error reports err at the beginning of the declaration d.
+func (d *gcDecl) error(err interface{}) {
+	defer d.fixPos()
+	d.parser().error(err)
+}
+
+// materialize parses the declaration d and creates the corresponding object.
+func (d *gcDecl) materialize() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(importError) // will re-panic if r is not an importError
+		}
+	}()
+	defer d.fixPos()
+	d.parser().parseDecl()
+	return nil
+}
+
+// index determines the scope and name of the object declared by d,
+// and records d as a pending declaration of that object. Methods are
+// recorded as declarations of their receiver base type name. Import
+// declarations are parsed immediately since they don't declare objects.
+//
+// Decl = [ ImportDecl | ConstDecl | TypeDecl | VarDecl | FuncDecl | MethodDecl ] "\n" .
+//
+func (d *gcDecl) index() {
+	defer d.fixPos()
+	p := d.parser()
+
+	switch p.tok {
+	case '\n':
+		return // empty declaration
+	case scanner.Ident:
+		// ok
+	default:
+		p.errorf("expected declaration, got %s", scanner.TokenString(p.tok))
+	}
+
+	switch p.lit {
+	case "import":
+		p.parseDecl()
+		return
+	case "const", "type", "var":
+		p.next()
+	case "func":
+		p.next()
+		if p.tok == '(' {
+			// MethodDecl: skip receiver name and find receiver base type name
+			p.next()
+			p.parseName(false)
+			if p.tok == '*' {
+				p.next()
+			}
+		}
+	default:
+		p.errorf("unexpected declaration: %s", p.lit)
+	}
+
+	pkg, name := p.parseExportedName()
+	pkg.scope.declareLazy(name, d.materialize)
+}
+
+// indexExport reads gc-generated export data and records its declarations
+// with the scopes of the packages declaring the respective objects. The
+// declarations are materialized on demand (see Scope.Lookup).
+//
+// Export = PackageClause { Decl } "$$" .
+//
+func indexExport(imports map[string]*Package, filename, id string, data *bufio.Reader) *Package {
+	var pkg *Package
+	offset := 0
+	for line := 1; ; line++ {
+		src, err := data.ReadBytes('\n')
+		d := &gcDecl{filename, id, imports, line, offset, src}
+		offset += len(src)
+
+		if bytes.HasPrefix(src, []byte("$$")) {
+			if pkg == nil {
+				d.error("missing package clause")
+			}
+			break
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("expected '$$', got EOF")
+			}
+			d.error(err)
+		}
+
+		if pkg == nil {
+			func() {
+				defer d.fixPos()
+				pkg = d.parser().parsePackageClause()
+			}()
+			continue
+		}
+
+		d.index()
+	}
+
+	// package was indexed completely and without errors
+	pkg.complete = true
+
+	return pkg
+}
@@ -5,14 +5,20 @@
 package types
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
 	"go/ast"
 	"go/build"
+	"go/parser"
+	"go/token"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -178,3 +184,308 @@ func TestGcImportedTypes(t *testing.T) {
 		}
 	}
 }
+
+// gcExport is export data in the format written by gc for the package
+//
+//	package p
+//
+//	import "io"
+//
+//	type T struct { x int; U }
+//	func (t *T) M(r io.Reader) U
+//	type U int
+//	const C = 42
+//	var V *T
+//	func F() error
+//	func G(x io.ReadWriter)
+//
+const gcExport = `package p
+	import io "io"
+	type @"".T struct { @"".x int; ? @"".U }
+	func (@"".t *@"".T) M(@"".r @"io".Reader) (? @"".U)
+	type @"".U int
+	const @"".C = 42
+	var @"".V *@"".T
+	func @"".F() (? error)
+	func @"".G(@"".x @"io".ReadWriter)
+	type @"io".Reader interface { Read(@"io".p []byte) (@"io".n int, @"io".err error) }
+	type @"io".Writer interface { Write(@"io".p []byte) (@"io".n int, @"io".err error) }
+	type @"io".ReadWriter interface { Read(@"io".p []byte) (@"io".n int, @"io".err error); Write(@"io".p []byte) (@"io".n int, @"io".err error) }
+
+$$
+`
+
+func importGcExport(t *testing.T, src string, lazy bool) (*Package, map[string]*Package) {
+	imports := make(map[string]*Package)
+	pkg, err := gcImportData(imports, "p.a", "p", bufio.NewReader(strings.NewReader(src)), lazy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg, imports
+}
+
+func TestGcImportLazy(t *testing.T) {
+	eager, eagerImports := importGcExport(t, gcExport, false)
+	lazy, lazyImports := importGcExport(t, gcExport, true)
+
+	if !lazy.complete {
+		t.Errorf("lazily imported package is not complete")
+	}
+
+	// nothing is materialized before it is looked up
+	if n := len(lazy.scope.Entries); n != 0 {
+		t.Errorf("got %d materialized objects; want 0", n)
+	}
+	if n := len(lazyImports["io"].scope.Entries); n != 0 {
+		t.Errorf("got %d materialized objects in io; want 0", n)
+	}
+
+	// looking up V materializes V and T (and the types T depends on)
+	if obj := lazy.scope.Lookup("V"); obj == nil {
+		t.Fatalf("V not found")
+	}
+	if lazy.scope.lookup("T") == nil || lazy.scope.lookup("F") != nil {
+		t.Errorf("got materialized objects %s; want V, T, U", lazy.scope)
+	}
+	if T, _ := lazy.scope.lookup("T").(*TypeName); T == nil || T.typ.(*Named).methods.Lookup(nil, "M") == nil {
+		t.Errorf("method T.M not materialized")
+	}
+	if lazyImports["io"].scope.lookup("ReadWriter") != nil {
+		t.Errorf("io.ReadWriter materialized but not used")
+	}
+
+	// both packages and their imports have the same objects
+	for path, epkg := range eagerImports {
+		lpkg := lazyImports[path]
+		if lpkg == nil {
+			t.Errorf("package %s not imported lazily", path)
+			continue
+		}
+		if len(lpkg.Scope().Entries) != len(epkg.Scope().Entries) {
+			t.Errorf("package %s: got %s; want %s", path, lpkg.scope, epkg.scope)
+		}
+		for _, eobj := range epkg.scope.Entries {
+			lobj := lpkg.scope.Lookup(eobj.Name())
+			if lobj == nil {
+				t.Errorf("%s.%s not found", path, eobj.Name())
+				continue
+			}
+			got, want := typeString(lobj.Type().Underlying()), typeString(eobj.Type().Underlying())
+			if got != want {
+				t.Errorf("%s.%s: got type %s; want %s", path, eobj.Name(), got, want)
+			}
+			if named, ok := eobj.Type().(*Named); ok {
+				if got, want := methodsString(lobj.Type().(*Named)), methodsString(named); got != want {
+					t.Errorf("%s.%s: got methods %s; want %s", path, eobj.Name(), got, want)
+				}
+			}
+		}
+	}
+	if eager != eagerImports["p"] || lazy != lazyImports["p"] {
+		t.Errorf("imported package not recorded in imports map")
+	}
+}
+
+// methodsString returns a description of the methods of t.
+func methodsString(t *Named) string {
+	var buf bytes.Buffer
+	for _, m := range t.methods.entries {
+		fmt.Fprintf(&buf, "%s %s; ", m.Name(), typeString(m.Type()))
+	}
+	return buf.String()
+}
+
+func TestGcImportLazyErrors(t *testing.T) {
+	// errors in the overall structure are reported immediately
+	for _, src := range []string{
+		"",
+		"package p\n",
+		"$$\n",
+		"package p\nbogus @\"\".x\n$$\n",
+		"package p\nconst @\"q\".C = 1\n$$\n", // q not imported
+	} {
+		imports := make(map[string]*Package)
+		if _, err := GcImportData(imports, "p.a", "p", bufio.NewReader(strings.NewReader(src))); err == nil {
+			t.Errorf("%q: expected error", src)
+		}
+	}
+
+	// errors in declarations are reported when the object is looked up
+	pkg, _ := importGcExport(t, "package p\nconst @\"\".C = 1\nvar @\"\".V [x]int\n$$\n", true)
+	if pkg.scope.Lookup("C") == nil {
+		t.Errorf("C not found")
+	}
+	if pkg.ImportError() != nil {
+		t.Errorf("unexpected import error: %s", pkg.ImportError())
+	}
+	pkg.scope.Lookup("V") // must not panic
+	err, ok := pkg.ImportError().(importError)
+	if !ok {
+		t.Fatalf("expected import error, got %v", pkg.ImportError())
+	}
+	if err.pos.Line != 3 {
+		t.Errorf("got error at line %d; want line 3", err.pos.Line)
+	}
+}
+
+func TestGcImportLazyConcurrent(t *testing.T) {
+	pkg, _ := importGcExport(t, gcExport, true)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if pkg.scope.Lookup("V") == nil {
+				t.Errorf("V not found")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			pkg.Scope()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if alt := pkg.scope.Insert(&Const{pkg: pkg, name: "C"}); alt == nil {
+			t.Errorf("C inserted twice")
+		}
+	}()
+	wg.Wait()
+	if err := pkg.ImportError(); err != nil {
+		t.Errorf("unexpected import error: %s", err)
+	}
+	// Once all objects are materialized, lookups don't acquire lazyMu.
+	if pkg.scope.hasPending() {
+		t.Errorf("scope has pending declarations after materialization")
+	}
+}
+
+// gcExportSrc is the source corresponding to gcExport (for package p).
+const gcExportSrc = `package p
+
+import "io"
+
+type T struct {
+	x int
+	U
+}
+
+func (t *T) M(r io.Reader) U { return 0 }
+
+type U int
+
+const C = 42
+
+var V *T
+
+func F() error { return nil }
+
+func G(x io.ReadWriter) {}
+
+func init() {}
+`
+
+func TestImportSourcePositions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srcpos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(gcExportSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// import p from dir, as a local import would
+	imports := make(map[string]*Package)
+	pkg, err := gcImportData(imports, "p.a", dir, bufio.NewReader(strings.NewReader(gcExport)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no positions without source
+	if pos := pkg.scope.Lookup("T").Pos(); pos.IsValid() {
+		t.Errorf("T has position %d before sources were read", pos)
+	}
+
+	fset := token.NewFileSet()
+	if err := ImportSourcePositions(fset, pkg); err != nil {
+		t.Fatal(err)
+	}
+
+	// positions may be requested while other objects are materialized
+	done := make(chan bool)
+	go func() {
+		pkg.Scope()
+		done <- true
+	}()
+	pkg.scope.Lookup("T").Pos()
+	<-done
+
+	T := pkg.scope.Lookup("T").Type().(*Named)
+	M := T.methods.Lookup(nil, "M")
+	x := T.underlying.(*Struct).fields[0]
+	for _, test := range []struct {
+		obj  Object
+		line int // 0 means no position
+	}{
+		{pkg.scope.Lookup("T"), 5},
+		{M, 10},
+		{pkg.scope.Lookup("U"), 12},
+		{pkg.scope.Lookup("C"), 14},
+		{pkg.scope.Lookup("V"), 16},
+		{pkg.scope.Lookup("F"), 18},
+		{pkg.scope.Lookup("G"), 20},
+		{x, 0}, // fields are not recorded
+	} {
+		var line int
+		if pos := test.obj.Pos(); pos.IsValid() {
+			line = fset.Position(pos).Line
+		}
+		if line != test.line {
+			t.Errorf("%s: got line %d; want %d", test.obj.Name(), line, test.line)
+		}
+	}
+
+	// positions are not re-recorded for a different file set
+	if err := ImportSourcePositions(fset, pkg); err != nil {
+		t.Errorf("recording positions again failed: %s", err)
+	}
+	if err := ImportSourcePositions(token.NewFileSet(), pkg); err == nil {
+		t.Errorf("recording positions for a different file set succeeded")
+	}
+	if pkg.srcfset != fset {
+		t.Errorf("positions were re-bound to a different file set")
+	}
+
+	// the type checker records positions for imported packages if requested
+	check := func(pkg *Package) (*token.FileSet, error) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "q.go", `package q; import "p"; var _ p.T`, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctxt := Context{
+			Import: func(map[string]*Package, string) (*Package, error) {
+				return pkg, nil
+			},
+			ImportPositions: true,
+		}
+		_, err = ctxt.Check("q", fset, file)
+		return fset, err
+	}
+	if _, err := check(pkg); err == nil {
+		t.Errorf("checking with positions recorded for a different file set succeeded")
+	}
+	pkg, err = gcImportData(make(map[string]*Package), "p.a", dir, bufio.NewReader(strings.NewReader(gcExport)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset, err = check(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := pkg.scope.Lookup("T").Pos(); pkg.srcfset != fset || fset.Position(pos).Line != 5 {
+		t.Errorf("T: got position %s; want %s:5", fset.Position(pos), filepath.Join(dir, "p.go"))
+	}
+}
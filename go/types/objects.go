@@ -33,6 +33,10 @@ type Package struct {
 	complete bool                // if set, this package was imported completely
 
 	spec *ast.ImportSpec
+
+	// source positions of imported objects; see ImportSourcePositions
+	srcfset *token.FileSet       // file set for interpretation of srcpos
+	srcpos  map[string]token.Pos // object key -> position
 }
 
 func NewPackage(path, name string) *Package {
@@ -40,7 +44,6 @@ func NewPackage(path, name string) *Package {
 }
 
 func (obj *Package) Pkg() *Package { return obj }
-func (obj *Package) Scope() *Scope { return obj.scope }
 func (obj *Package) Name() string  { return obj.name }
 func (obj *Package) Type() Type    { return Typ[Invalid] }
 func (obj *Package) Pos() token.Pos {
@@ -53,6 +56,31 @@ func (obj *Package) Path() string                 { return obj.path }
 func (obj *Package) Imports() map[string]*Package { return obj.imports }
 func (obj *Package) Complete() bool               { return obj.complete }
 
+// Scope returns the package-level scope of obj. The objects of
+// imported packages may be materialized on demand when they are
+// looked up; Scope materializes all of them, so that the scope
+// Entries are complete. Scope and Scope.Lookup may be called
+// concurrently once the package has been imported.
+func (obj *Package) Scope() *Scope {
+	if s := obj.scope; s != nil && s.hasPending() {
+		lazyMu.Lock()
+		s.materializeAll()
+		lazyMu.Unlock()
+	}
+	return obj.scope
+}
+
+// ImportError returns the first error that occurred when materializing
+// the lazily imported objects of obj (see GcImportData), or nil.
+// Objects with erroneous declarations may be missing from obj's scope
+// or incomplete.
+func (obj *Package) ImportError() error {
+	if s := obj.scope; s != nil {
+		return s.importError()
+	}
+	return nil
+}
+
 // A Const represents a declared constant.
 type Const struct {
 	pkg  *Package
@@ -70,7 +98,7 @@ func (obj *Const) Name() string  { return obj.name }
 func (obj *Const) Type() Type    { return obj.typ }
 func (obj *Const) Pos() token.Pos {
 	if obj.spec == nil {
-		return token.NoPos
+		return obj.pkg.sourcePos(obj, obj.name)
 	}
 	for _, n := range obj.spec.Names {
 		if n.Name == obj.name {
@@ -100,7 +128,7 @@ func (obj *TypeName) Name() string  { return obj.name }
 func (obj *TypeName) Type() Type    { return obj.typ }
 func (obj *TypeName) Pos() token.Pos {
 	if obj.spec == nil {
-		return token.NoPos
+		return obj.pkg.sourcePos(obj, obj.name)
 	}
 	return obj.spec.Pos()
 }
@@ -145,6 +173,8 @@ func (obj *Var) Pos() token.Pos {
 				return ident.Pos()
 			}
 		}
+	case nil:
+		return obj.pkg.sourcePos(obj, obj.name)
 	}
 	return token.NoPos
 }
@@ -158,6 +188,10 @@ type Func struct {
 	decl *ast.FuncDecl
 }
 
+func NewFunc(pkg *Package, name string, sig *Signature) *Func {
+	return &Func{pkg, name, sig, nil}
+}
+
 func (obj *Func) Pkg() *Package  { return obj.pkg }
 func (obj *Func) Scope() *Scope  { panic("unimplemented") }
 func (obj *Func) Name() string   { return obj.name }
@@ -167,6 +201,16 @@ func (obj *Func) Pos() token.Pos {
 	if obj.decl != nil && obj.decl.Name != nil {
 		return obj.decl.Name.Pos()
 	}
+	if obj.decl == nil {
+		if sig, _ := obj.typ.(*Signature); sig != nil && sig.recv != nil {
+			// method
+			if base, _ := sig.recv.typ.Deref().(*Named); base != nil {
+				return base.obj.pkg.sourcePos(nil, base.obj.name+"."+obj.name)
+			}
+			return token.NoPos
+		}
+		return obj.pkg.sourcePos(obj, obj.name)
+	}
 	return token.NoPos
 }
 
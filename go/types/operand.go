@@ -147,7 +147,7 @@ func (x *operand) isAssignable(ctxt *Context, T Type) bool {
 
 	// T is an interface type and x implements T
 	if Ti, ok := Tu.(*Interface); ok {
-		if m, _ := missingMethod(x.typ, Ti); m == nil {
+		if m, _ := missingMethod(x.typ, Ti, false); m == nil {
 			return true
 		}
 	}
@@ -182,7 +182,7 @@ func (x *operand) isAssignable(ctxt *Context, T Type) bool {
 		switch t := Tu.(type) {
 		case *Basic:
 			if x.mode == constant {
-				return isRepresentableConst(x.val, ctxt, t.kind)
+				return isRepresentableConst(x.val, ctxt, t.kind, nil)
 			}
 			// The result of a comparison is an untyped boolean,
 			// but may not be a constant.
@@ -203,20 +203,23 @@ func (x *operand) isAssignable(ctxt *Context, T Type) bool {
 func (x *operand) isInteger() bool {
 	return x.mode == invalid ||
 		isInteger(x.typ) ||
-		x.mode == constant && isRepresentableConst(x.val, nil, UntypedInt) // no context required for UntypedInt
+		x.mode == constant && isRepresentableConst(x.val, nil, UntypedInt, nil) // no context required for UntypedInt
 }
 
 // lookupResult represents the result of a struct field/method lookup.
 type lookupResult struct {
 	mode  operandMode
 	typ   Type
-	index []int // field index sequence; nil for methods
+	index    []int  // field index sequence; nil for methods
+	obj      Object // the field (*Field) or method (*Func) found
+	indirect bool   // if set, the path to obj contains an embedded pointer
 }
 
 type embeddedType struct {
 	typ       *Named
 	index     []int // field index sequence
 	multiples bool  // if set, typ is embedded multiple times at the same level
+	indirect  bool  // if set, the path to typ contains an embedded pointer
 }
 
 // lookupFieldBreadthFirst searches all types in list for a single entry (field
@@ -232,16 +235,18 @@ func lookupFieldBreadthFirst(list []embeddedType, pkg *Package, name string) (re
 	var next []embeddedType
 
 	// potentialMatch is invoked every time a match is found.
-	potentialMatch := func(multiples bool, mode operandMode, typ Type) bool {
-		if multiples || res.mode != invalid {
+	potentialMatch := func(e *embeddedType, mode operandMode, obj Object) bool {
+		if e.multiples || res.mode != invalid {
 			// name appeared already at this level - annihilate
 			res.mode = invalid
 			return false
 		}
 		// first appearance of name
 		res.mode = mode
-		res.typ = typ
+		res.typ = obj.Type()
 		res.index = nil
+		res.obj = obj
+		res.indirect = e.indirect
 		return true
 	}
 
@@ -267,7 +272,7 @@ func lookupFieldBreadthFirst(list []embeddedType, pkg *Package, name string) (re
 			if obj := typ.methods.Lookup(pkg, name); obj != nil {
 				m := obj.(*Func)
 				assert(m.typ != nil)
-				if !potentialMatch(e.multiples, value, m.typ) {
+				if !potentialMatch(&e, value, m) {
 					return // name collision
 				}
 			}
@@ -278,7 +283,7 @@ func lookupFieldBreadthFirst(list []embeddedType, pkg *Package, name string) (re
 				for i, f := range t.fields {
 					if f.isMatch(pkg, name) {
 						assert(f.typ != nil)
-						if !potentialMatch(e.multiples, variable, f.typ) {
+						if !potentialMatch(&e, variable, f) {
 							return // name collision
 						}
 						var index []int
@@ -303,7 +308,7 @@ func lookupFieldBreadthFirst(list []embeddedType, pkg *Package, name string) (re
 							var index []int
 							index = append(index, e.index...) // copy e.index
 							index = append(index, i)
-							next = append(next, embeddedType{t, index, e.multiples})
+							next = append(next, embeddedType{t, index, e.multiples, e.indirect || isPointer(f.typ)})
 						}
 					}
 				}
@@ -313,7 +318,7 @@ func lookupFieldBreadthFirst(list []embeddedType, pkg *Package, name string) (re
 				if obj := t.methods.Lookup(pkg, name); obj != nil {
 					m := obj.(*Func)
 					assert(m.typ != nil)
-					if !potentialMatch(e.multiples, value, m.typ) {
+					if !potentialMatch(&e, value, m) {
 						return // name collision
 					}
 				}
@@ -356,6 +361,21 @@ func findType(list []embeddedType, typ *Named) *embeddedType {
 	return nil
 }
 
+// LookupFieldOrMethod looks up the field or method with the given name
+// of typ, which may be a pointer. Unexported names are looked up in
+// package pkg. The result obj is the field (*Var) or method (*Func)
+// found, or nil if there is none or the name is ambiguous; for fields,
+// index is the sequence of field indices leading to obj. indirect is set
+// if the path to obj contains an embedded pointer.
+//
+func LookupFieldOrMethod(typ Type, pkg *Package, name string) (obj Object, index []int, indirect bool) {
+	res := lookupField(typ, pkg, name)
+	if res.mode == invalid {
+		return nil, nil, false
+	}
+	return res.obj, res.index, res.indirect
+}
+
 func lookupField(typ Type, pkg *Package, name string) lookupResult {
 	typ = typ.Deref()
 
@@ -363,7 +383,7 @@ func lookupField(typ Type, pkg *Package, name string) lookupResult {
 		if obj := t.methods.Lookup(pkg, name); obj != nil {
 			m := obj.(*Func)
 			assert(m.typ != nil)
-			return lookupResult{value, m.typ, nil}
+			return lookupResult{value, m.typ, nil, m, false}
 		}
 		typ = t.underlying
 	}
@@ -373,7 +393,7 @@ func lookupField(typ Type, pkg *Package, name string) lookupResult {
 		var next []embeddedType
 		for i, f := range t.fields {
 			if f.isMatch(pkg, name) {
-				return lookupResult{variable, f.typ, []int{i}}
+				return lookupResult{variable, f.typ, []int{i}, f, false}
 			}
 			if f.IsAnonymous {
 				// Possible optimization: If the embedded type
@@ -382,7 +402,7 @@ func lookupField(typ Type, pkg *Package, name string) lookupResult {
 				// Ignore embedded basic types - only user-defined
 				// named types can have methods or have struct fields.
 				if t, _ := f.typ.Deref().(*Named); t != nil {
-					next = append(next, embeddedType{t, []int{i}, false})
+					next = append(next, embeddedType{t, []int{i}, false, isPointer(f.typ)})
 				}
 			}
 		}
@@ -394,7 +414,7 @@ func lookupField(typ Type, pkg *Package, name string) lookupResult {
 		if obj := t.methods.Lookup(pkg, name); obj != nil {
 			m := obj.(*Func)
 			assert(m.typ != nil)
-			return lookupResult{value, m.typ, nil}
+			return lookupResult{value, m.typ, nil, m, false}
 		}
 	}
 
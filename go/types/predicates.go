@@ -259,12 +259,32 @@ func defaultType(typ Type) Type {
 	return typ
 }
 
+// Implements reports whether typ implements the interface iface.
+// Unlike the type checker's assignability test, Implements respects
+// method sets: methods with a pointer receiver are in the method set
+// of a non-pointer type only if they are promoted through an embedded
+// pointer.
+func Implements(typ Type, iface *Interface) bool {
+	m, _ := missingMethod(typ, iface, true)
+	return m == nil
+}
+
+// hasPtrRecv reports whether m is a method with a pointer receiver.
+func hasPtrRecv(m *Func) bool {
+	if sig, _ := m.typ.(*Signature); sig != nil && sig.recv != nil {
+		return isPointer(sig.recv.typ)
+	}
+	return false
+}
+
 // missingMethod returns (nil, false) if typ implements T, otherwise
 // it returns the first missing method required by T and whether it
-// is missing or simply has the wrong type.
+// is missing or simply has the wrong type. If strict is set, the
+// method set of typ is computed exactly (see Implements); otherwise
+// pointer and non-pointer receivers are not distinguished.
 // TODO(gri) make method of Type and/or stand-alone predicate.
 //
-func missingMethod(typ Type, T *Interface) (method *Func, wrongType bool) {
+func missingMethod(typ Type, T *Interface, strict bool) (method *Func, wrongType bool) {
 	// TODO(gri): this needs to correctly compare method names (taking package into account)
 	// TODO(gri): distinguish pointer and non-pointer receivers
 	// an interface type implements T if it has no methods with conflicting signatures
@@ -287,6 +307,12 @@ func missingMethod(typ Type, T *Interface) (method *Func, wrongType bool) {
 		if res.mode == invalid {
 			return m, false
 		}
+		if strict {
+			f, _ := res.obj.(*Func)
+			if f == nil || !isPointer(typ) && !res.indirect && hasPtrRecv(f) {
+				return m, false // not in the method set of typ
+			}
+		}
 		if !IsIdentical(res.typ, m.typ) {
 			return m, true
 		}
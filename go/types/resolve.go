@@ -137,6 +137,14 @@ func (check *checker) resolve(importer Importer) (methods []*ast.FuncDecl) {
 				importErrors = true
 				continue
 			}
+			if check.ctxt.ImportPositions && imp.srcfset != check.fset {
+				if imp.srcfset != nil {
+					check.errorf(spec.Path.Pos(), "could not import positions of %s: recorded for a different file set", path)
+				} else {
+					ImportSourcePositions(check.fset, imp) // missing sources are not an error
+				}
+			}
+
 			// TODO(gri) If a local package name != "." is provided,
 			// global identifier resolution could proceed even if the
 			// import failed. Consider adjusting the logic here a bit.
@@ -150,7 +158,7 @@ func (check *checker) resolve(importer Importer) (methods []*ast.FuncDecl) {
 			// add import to file scope
 			if name == "." {
 				// merge imported scope with file scope
-				for _, obj := range imp.scope.Entries {
+				for _, obj := range imp.Scope().Entries {
 					// gcimported package scopes contain non-exported
 					// objects such as types used in partially exported
 					// objects - do not accept them
@@ -167,7 +175,7 @@ func (check *checker) resolve(importer Importer) (methods []*ast.FuncDecl) {
 				// (do not re-use imp in the file scope but create
 				// a new object instead; the Decl field is different
 				// for different files)
-				obj := &Package{name: name, scope: imp.scope, spec: spec}
+				obj := &Package{name: name, path: imp.path, scope: imp.scope, spec: spec}
 				check.declareObj(fileScope, pkg.scope, obj, token.NoPos)
 			}
 		}
@@ -7,6 +7,8 @@ package types
 import (
 	"bytes"
 	"fmt"
+	"sync"
+	"sync/atomic"
 )
 
 // A Scope maintains the set of named language entities declared
@@ -17,13 +19,133 @@ type Scope struct {
 	Outer   *Scope
 	Entries []Object          // scope entries in insertion order
 	large   map[string]Object // for fast lookup - only used for larger scopes
+	lazy    *lazyObjects      // declarations not yet materialized, or nil
+	pending uint32            // 1 if lazy has pending declarations; accessed atomically
+	failed  uint32            // 1 if lazy.err is set; accessed atomically
+}
+
+// lazyObjects records the declarations of objects that are added to a
+// scope the first time they are looked up. It is used by importers to
+// avoid creating objects that are never used.
+type lazyObjects struct {
+	names []string                  // object names, in the order of their first declaration
+	decls map[string][]func() error // object name -> pending declarations
+	err   error                     // first error reported by a declaration, or nil
+}
+
+// lazyMu serializes the declaration and materialization of lazily
+// declared objects. A single lock is used for all scopes since
+// materializing an object may declare objects in the scopes of other
+// (imported) packages. Once a scope has no pending declarations, it
+// is looked up without acquiring lazyMu.
+var lazyMu sync.Mutex
+
+// declareLazy records decl as a declaration of the object with the given
+// name. When the object is looked up and not found in s, the pending
+// declarations are run in order; they are expected to insert the object
+// into s. Declaration errors are recorded and reported by Package.ImportError.
+// lazyMu must be held.
+//
+func (s *Scope) declareLazy(name string, decl func() error) {
+	if s.lookup(name) != nil {
+		return // already materialized; ignore subsequent declarations
+	}
+	l := s.lazy
+	if l == nil {
+		l = &lazyObjects{decls: make(map[string][]func() error)}
+		s.lazy = l
+	}
+	if _, found := l.decls[name]; !found {
+		l.names = append(l.names, name)
+	}
+	l.decls[name] = append(l.decls[name], decl)
+	atomic.StoreUint32(&s.pending, 1)
+}
+
+// hasPending reports whether s has pending lazy declarations.
+func (s *Scope) hasPending() bool {
+	return atomic.LoadUint32(&s.pending) != 0
+}
+
+// importError returns the first error reported by a lazy
+// declaration of s, or nil.
+func (s *Scope) importError() error {
+	if atomic.LoadUint32(&s.failed) == 0 {
+		return nil
+	}
+	lazyMu.Lock()
+	defer lazyMu.Unlock()
+	return s.lazy.err
+}
+
+// materialize runs the pending declarations for name, if any,
+// and reports whether there were any. lazyMu must be held.
+func (s *Scope) materialize(name string) bool {
+	if s.lazy == nil {
+		return false
+	}
+	decls, found := s.lazy.decls[name]
+	if !found {
+		return false
+	}
+	// delete the entry first: the declarations look up the name themselves
+	delete(s.lazy.decls, name)
+	for _, decl := range decls {
+		if err := decl(); err != nil && s.lazy.err == nil {
+			s.lazy.err = err
+			atomic.StoreUint32(&s.failed, 1)
+		}
+	}
+	// The object is only published to readers that don't acquire
+	// lazyMu once its declarations have run.
+	if len(s.lazy.decls) == 0 {
+		atomic.StoreUint32(&s.pending, 0)
+	}
+	return true
+}
+
+// materializeAll runs all pending declarations of s. lazyMu must be held.
+func (s *Scope) materializeAll() {
+	if s.lazy == nil {
+		return
+	}
+	// Materializing an object may declare more objects lazily.
+	// s.lazy is never reset since s may be read concurrently.
+	for l := s.lazy; len(l.decls) > 0; {
+		names := l.names
+		l.names = nil
+		for _, name := range names {
+			s.materialize(name)
+		}
+	}
 }
 
 // Lookup returns the object with the given name if it is
 // found in scope s, otherwise it returns nil. Outer scopes
 // are ignored.
 //
+// If the object was imported lazily and its declaration is
+// erroneous, the result may be nil or incomplete; the error is
+// reported by Package.ImportError.
+//
 func (s *Scope) Lookup(name string) Object {
+	if !s.hasPending() {
+		return s.lookup(name)
+	}
+	lazyMu.Lock()
+	defer lazyMu.Unlock()
+	return s.lookupLazy(name)
+}
+
+// lookupLazy is like Lookup but lazyMu must be held.
+func (s *Scope) lookupLazy(name string) Object {
+	if obj := s.lookup(name); obj != nil || !s.materialize(name) {
+		return obj
+	}
+	return s.lookup(name)
+}
+
+func (s *Scope) lookup(name string) Object {
 	if s.large != nil {
 		return s.large[name]
 	}
@@ -41,8 +163,18 @@ func (s *Scope) Lookup(name string) Object {
 // Otherwise it inserts obj and returns nil.
 //
 func (s *Scope) Insert(obj Object) Object {
+	if s.hasPending() {
+		lazyMu.Lock()
+		defer lazyMu.Unlock()
+	}
+	return s.insert(obj)
+}
+
+// insert is like Insert but lazyMu must be held if s has
+// pending declarations.
+func (s *Scope) insert(obj Object) Object {
 	name := obj.Name()
-	if alt := s.Lookup(name); alt != nil {
+	if alt := s.lookupLazy(name); alt != nil {
 		return alt
 	}
 	s.Entries = append(s.Entries, obj)
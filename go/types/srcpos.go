@@ -0,0 +1,130 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements source positions for imported objects.
+
+package types
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+)
+
+// ImportSourcePositions determines the source positions of the package-level
+// objects and methods of the imported package pkg. Export data doesn't record
+// positions; instead, the package source files are located with go/build
+// (using the build.Default build.Context) and parsed into fset. After a
+// successful call, the Pos methods of the package's objects report positions
+// in fset.
+//
+// Positions are recorded in a side table of the package; objects that are
+// imported later (e.g., materialized on demand) have positions as well.
+// Struct fields, parameters, and results of imported types have no positions.
+//
+// The positions of a package can only be recorded for a single file set:
+// if they were recorded for a different fset before, ImportSourcePositions
+// leaves pkg unchanged and returns an error.
+//
+func ImportSourcePositions(fset *token.FileSet, pkg *Package) error {
+	if pkg == Unsafe {
+		return nil // no source
+	}
+
+	if pkg.srcfset != nil {
+		if pkg.srcfset != fset {
+			return fmt.Errorf("source positions of package %s were recorded for a different file set", pkg.path)
+		}
+		return nil // already recorded
+	}
+
+	var bp *build.Package
+	var err error
+	if filepath.IsAbs(pkg.path) {
+		// local imports are identified by their absolute directory
+		bp, err = build.ImportDir(pkg.path, 0)
+	} else {
+		bp, err = build.Import(pkg.path, "", 0)
+	}
+	if err != nil {
+		return err
+	}
+
+	srcpos := make(map[string]token.Pos)
+	for _, list := range [][]string{bp.GoFiles, bp.CgoFiles} {
+		for _, filename := range list {
+			file, err := parser.ParseFile(fset, filepath.Join(bp.Dir, filename), nil, 0)
+			if err != nil {
+				return err
+			}
+			recordSourcePositions(srcpos, file)
+		}
+	}
+
+	pkg.srcfset = fset
+	pkg.srcpos = srcpos
+
+	return nil
+}
+
+// recordSourcePositions records the positions of the package-level
+// declarations of file in srcpos. Objects are identified by their name,
+// methods by their receiver base type name and method name ("T.m").
+//
+func recordSourcePositions(srcpos map[string]token.Pos, file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						srcpos[name.Name] = name.Pos()
+					}
+				case *ast.TypeSpec:
+					srcpos[s.Name.Name] = s.Name.Pos()
+				}
+			}
+		case *ast.FuncDecl:
+			key := d.Name.Name
+			if d.Recv != nil {
+				if len(d.Recv.List) == 0 {
+					continue // invalid receiver
+				}
+				typ := d.Recv.List[0].Type
+				if ptr, ok := typ.(*ast.StarExpr); ok {
+					typ = ptr.X
+				}
+				base, ok := typ.(*ast.Ident)
+				if !ok {
+					continue // invalid receiver
+				}
+				key = base.Name + "." + key
+			} else if key == "init" {
+				continue // init functions are not declared
+			}
+			srcpos[key] = d.Name.Pos()
+		}
+	}
+}
+
+// sourcePos returns the recorded source position of the object with the
+// given key in package pkg, or token.NoPos. If obj != nil, it must be a
+// package-level object of pkg; otherwise the key identifies a method.
+//
+func (pkg *Package) sourcePos(obj Object, key string) token.Pos {
+	if pkg == nil || pkg.srcpos == nil {
+		return token.NoPos
+	}
+	// struct fields and package-level objects may have the same name;
+	// only the latter are recorded (Lookup is safe for concurrent use
+	// with lazy imports)
+	if obj != nil && pkg.scope.Lookup(key) != obj {
+		return token.NoPos
+	}
+	return pkg.srcpos[key]
+}
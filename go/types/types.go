@@ -304,6 +304,17 @@ type Interface struct {
 	methods ObjSet
 }
 
+// NewInterface returns a new interface type with the given methods,
+// in that order. A method with the same package and name as an
+// earlier one is ignored.
+func NewInterface(methods []*Func) *Interface {
+	t := new(Interface)
+	for _, m := range methods {
+		t.methods.Insert(m)
+	}
+	return t
+}
+
 // NumMethods returns the number of methods of interface t.
 func (t *Interface) NumMethods() int { return len(t.methods.entries) }
 
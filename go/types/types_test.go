@@ -8,8 +8,10 @@
 package types
 
 import (
+	"fmt"
 	"go/ast"
 	"go/parser"
+	"reflect"
 	"testing"
 )
 
@@ -169,3 +171,102 @@ func TestExprs(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorRange(t *testing.T) {
+	const src = "package p; const big = 1 << 511; const _ = big * 2 + 1"
+	_, err := makePkg(t, src)
+	e, ok := err.(Error)
+	if !ok {
+		t.Fatalf("got error %v (%T); want Error", err, err)
+	}
+	if got, want := src[fset.Position(e.Start).Offset:fset.Position(e.End).Offset], "big * 2"; got != want {
+		t.Errorf("got error range %q; want %q", got, want)
+	}
+	if e.Pos != e.Start {
+		t.Errorf("got error position %s; want %s", fset.Position(e.Pos), fset.Position(e.Start))
+	}
+}
+
+func TestImplements(t *testing.T) {
+	const src = `package p
+type I interface{ M() }
+type T struct{}
+func (*T) M() {}
+type V struct{}
+func (V) M() {}
+type E1 struct{ T }
+type E2 struct{ *T }
+type E3 struct{ V }
+type F struct{ M func() }
+`
+	pkg, err := makePkg(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iface := pkg.Scope().Lookup("I").Type().Underlying().(*Interface)
+	for _, test := range []struct {
+		name     string
+		val, ptr bool // whether T and *T implement I
+	}{
+		{"T", false, true},
+		{"V", true, true},
+		{"E1", false, true},
+		{"E2", true, true},
+		{"E3", true, true},
+		{"F", false, false},
+	} {
+		typ := pkg.Scope().Lookup(test.name).Type()
+		if got := Implements(typ, iface); got != test.val {
+			t.Errorf("Implements(%s, I) = %v; want %v", test.name, got, test.val)
+		}
+		if got := Implements(NewPointer(typ), iface); got != test.ptr {
+			t.Errorf("Implements(*%s, I) = %v; want %v", test.name, got, test.ptr)
+		}
+	}
+}
+
+func TestLookupFieldOrMethod(t *testing.T) {
+	const src = `package p
+type I interface{ M() }
+type T struct{ x int }
+func (*T) N() {}
+type S struct {
+	I
+	*T
+}
+type J interface{ M() }
+type A struct {
+	I
+	J
+}
+`
+	pkg, err := makePkg(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	S := pkg.Scope().Lookup("S").Type()
+	for _, test := range []struct {
+		typ      Type
+		name     string
+		want     string // kind and type of the object found, or ""
+		index    []int
+		indirect bool
+	}{
+		{S, "M", "*types.Func func()", nil, false}, // promoted from the embedded interface
+		{S, "N", "*types.Func func()", nil, true},
+		{S, "x", "*types.Field int", []int{1, 0}, true},
+		{NewPointer(S), "x", "*types.Field int", []int{1, 0}, true},
+		{S, "y", "", nil, false},
+		{pkg.Scope().Lookup("A").Type(), "M", "", nil, false}, // ambiguous
+	} {
+		obj, index, indirect := LookupFieldOrMethod(test.typ, pkg, test.name)
+		var got string
+		if obj != nil {
+			got = fmt.Sprintf("%T %s", obj, obj.Type())
+		}
+		if got != test.want || !reflect.DeepEqual(index, test.index) || indirect != test.indirect {
+			t.Errorf("LookupFieldOrMethod(%s, %s) = %s, %v, %v; want %s, %v, %v",
+				test.typ, test.name, got, index, indirect, test.want, test.index, test.indirect)
+		}
+	}
+}
@@ -18,21 +18,55 @@ the same package unless the package name is explicitly specified with the
 Given a directory name, gotype collects all .go files in the directory
 and processes them as if they were provided as an explicit list of file
 names. Each directory is processed independently. Files starting with .
-or not ending in .go are ignored.
+or not ending in .go are ignored; test files (ending in _test.go) are
+ignored if -t=false is set (unless -x is set).
+
+Like go test, gotype distinguishes the files of a package (including its
+in-package test files) from the test files of an external test package,
+whose package name ends in _test. The external test package is only
+checked if -x is set; it is checked after the package, and its imports
+of the package (identified by its directory) denote the package as
+checked from source, including in-package test declarations.
+
+Files importing the pseudo-package "C" are processed with cgo (which
+requires the go command and a C compiler); the files generated by cgo
+are checked in their place. Errors in them are reported at positions
+of the original files.
 
 Usage:
 	gotype [flags] [path ...]
 
 The flags are:
+	-cgo
+		Process files importing "C" with cgo (default true). If not
+		set, references to C entities are reported as errors.
+	-compiler name
+		Import packages using the export data of the named compiler
+		(gc or gccgo; default gc). For gccgo, the installation is
+		determined by querying the gccgo driver in $PATH.
 	-e
 		Print all (including spurious) errors.
 	-p pkgName
 		Process only those files in package pkgName.
 	-r
 		Recursively process subdirectories.
+	-t
+		Include in-package test files in directories (default true).
+	-x
+		Also check external test packages (implies -t).
 	-v
 		Verbose mode.
 
+Output flags:
+	-json
+		Print errors as JSON objects, one per line, to standard output.
+	-types
+		Print the type (and constant value, if any) of each identifier
+		and expression, and the kind and position of the object denoted
+		by each identifier, as JSON objects, one per line, to standard
+		output. The objects are sorted by position, enclosing
+		expressions first.
+
 Debugging flags:
 	-comments
 		Parse comments (ignored if -ast not set).
@@ -53,6 +87,11 @@ and recursively in all subdirectories:
 
 	gotype -p main -r .
 
+To check the package in the current directory together with its
+in-package and external tests:
+
+	gotype -x .
+
 To verify the output of a pipe:
 
 	echo "package foo" | gotype
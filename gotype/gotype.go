@@ -8,6 +8,7 @@ import (
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/scanner"
 	"go/token"
@@ -16,6 +17,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"code.google.com/p/go.tools/go/loader"
 	"code.google.com/p/go.tools/go/types"
 )
 
@@ -25,6 +27,14 @@ var (
 	recursive = flag.Bool("r", false, "recursively process subdirectories")
 	verbose   = flag.Bool("v", false, "verbose mode")
 	allErrors = flag.Bool("e", false, "report all errors (not just the first 10 on different lines)")
+	compiler  = flag.String("compiler", "gc", "compiler whose export data is used for imports (gc or gccgo)")
+	useCgo    = flag.Bool("cgo", true, "process files importing \"C\" with cgo")
+	testFiles = flag.Bool("t", true, "include in-package test files in directories")
+	xtest     = flag.Bool("x", false, "also check external test packages (implies -t)")
+
+	// output modes
+	jsonErrors = flag.Bool("json", false, "print errors as JSON objects, one per line, to standard output")
+	printTypes = flag.Bool("types", false, "print type information for identifiers and expressions as JSON objects, one per line")
 
 	// debugging support
 	parseComments = flag.Bool("comments", false, "parse comments (ignored if -ast not set)")
@@ -41,7 +51,11 @@ func usage() {
 }
 
 func report(err error) {
-	scanner.PrintError(os.Stderr, err)
+	if *jsonErrors {
+		reportJSON(err)
+	} else {
+		scanner.PrintError(os.Stderr, err)
+	}
 	if list, ok := err.(scanner.ErrorList); ok {
 		errorCount += len(list)
 		return
@@ -49,6 +63,21 @@ func report(err error) {
 	errorCount++
 }
 
+// parserMode returns the parser mode selected by the flags.
+func parserMode() parser.Mode {
+	mode := parser.DeclarationErrors
+	if *allErrors {
+		mode |= parser.AllErrors
+	}
+	if *parseComments && *printAST {
+		mode |= parser.ParseComments
+	}
+	if *printTrace {
+		mode |= parser.Trace
+	}
+	return mode
+}
+
 // parse returns the AST for the Go source src.
 // The filename is for error reporting only.
 // The result is nil if there were errors or if
@@ -74,17 +103,7 @@ func parse(fset *token.FileSet, filename string, src []byte) *ast.File {
 	}
 
 	// parse entire file
-	mode := parser.DeclarationErrors
-	if *allErrors {
-		mode |= parser.AllErrors
-	}
-	if *parseComments && *printAST {
-		mode |= parser.ParseComments
-	}
-	if *printTrace {
-		mode |= parser.Trace
-	}
-	file, err := parser.ParseFile(fset, filename, src, mode)
+	file, err := parser.ParseFile(fset, filename, src, parserMode())
 	if err != nil {
 		report(err)
 		return nil
@@ -128,6 +147,10 @@ func isGoFilename(filename string) bool {
 	return !strings.HasPrefix(filename, ".") && strings.HasSuffix(filename, ".go")
 }
 
+func isTestFilename(filename string) bool {
+	return strings.HasSuffix(filename, "_test.go")
+}
+
 func processDirectory(dirname string) {
 	f, err := os.Open(dirname)
 	if err != nil {
@@ -157,17 +180,119 @@ func processFiles(path string, filenames []string, allFiles bool) {
 				processDirectory(filename)
 			}
 		default:
-			if allFiles || isGoFilename(info.Name()) {
+			// in directories, test files are excluded with -t=false (unless -x is set)
+			if allFiles || isGoFilename(info.Name()) && (!isTestFilename(info.Name()) || *testFiles || *xtest) {
 				filenames[i] = filename
 				i++
 			}
 		}
 	}
 	fset := token.NewFileSet()
-	processPackage(path, fset, parseFiles(fset, filenames[0:i]))
+	files, xfiles := splitExternalTests(fset, parseFiles(fset, filenames[0:i]))
+	if *useCgo {
+		files = processCgoFiles(fset, files)
+	}
+	pkg := processPackage(path, fset, files, importer)
+
+	if len(xfiles) > 0 {
+		if !*xtest {
+			if *verbose {
+				fmt.Printf("%s: external test files ignored (use -x)\n", path)
+			}
+			return
+		}
+		// Like go test, check the external test package against the
+		// package augmented by its in-package test files.
+		if *useCgo {
+			xfiles = processCgoFiles(fset, xfiles)
+		}
+		dir := filepath.Dir(fset.Position(xfiles[0].Pos()).Filename)
+		processPackage(path+"_test", fset, xfiles, testImporter(dir, pkg))
+	}
+}
+
+// splitExternalTests separates the files of an external test package
+// (test files with a package name ending in _test) from the other files.
+// If there are no other files, the external test files are not separated.
+func splitExternalTests(fset *token.FileSet, files []*ast.File) (pkgFiles, xtestFiles []*ast.File) {
+	for _, file := range files {
+		if isTestFilename(fset.Position(file.Pos()).Filename) && strings.HasSuffix(file.Name.Name, "_test") {
+			xtestFiles = append(xtestFiles, file)
+		} else {
+			pkgFiles = append(pkgFiles, file)
+		}
+	}
+	if len(pkgFiles) == 0 {
+		return xtestFiles, nil
+	}
+	return
+}
+
+// testImporter returns the Importer for an external test package: imports
+// of the package in directory dir return pkg, the package under test; all
+// other packages are imported with the importer selected by -compiler.
+// If pkg is nil (checking it was aborted), importing it is an error.
+func testImporter(dir string, pkg *types.Package) types.Importer {
+	imp := importer
+	if imp == nil {
+		imp = types.GcImport
+	}
+	absDir, _ := filepath.Abs(dir)
+	return func(imports map[string]*types.Package, path string) (*types.Package, error) {
+		if bp, err := build.Import(path, dir, build.FindOnly); err == nil {
+			if pkgDir, _ := filepath.Abs(bp.Dir); pkgDir == absDir {
+				if pkg == nil {
+					return nil, fmt.Errorf("package under test was not checked completely")
+				}
+				imports[path] = pkg
+				return pkg, nil
+			}
+		}
+		return imp(imports, path)
+	}
+}
+
+// processCgoFiles replaces the files importing "C" by
+// the corresponding files generated by cgo.
+func processCgoFiles(fset *token.FileSet, files []*ast.File) []*ast.File {
+	var cgoFiles []string
+	i := 0
+	for _, file := range files {
+		if loader.ImportsC(file) {
+			cgoFiles = append(cgoFiles, fset.Position(file.Pos()).Filename)
+			continue
+		}
+		files[i] = file
+		i++
+	}
+	if cgoFiles == nil {
+		return files
+	}
+	generated, err := loader.ParseCgoFiles(fset, "", cgoFiles, cgoFlags(filepath.Dir(cgoFiles[0])), parserMode())
+	if err != nil {
+		report(err)
+		return files[0:i]
+	}
+	return append(files[0:i], generated...)
 }
 
-func processPackage(path string, fset *token.FileSet, files []*ast.File) {
+// cgoFlags returns the C compiler flags specified by the #cgo directives
+// of the package in directory dir, as collected by go/build. Flags that
+// cannot be determined are omitted; cgo reports any resulting errors.
+func cgoFlags(dir string) []string {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		if *verbose {
+			fmt.Printf("%s: cgo flags ignored (%s)\n", dir, err)
+		}
+		return nil
+	}
+	return loader.CgoFlags(bp)
+}
+
+// processPackage type-checks the package with the given files using the
+// importer imp. The result is the package, or nil if checking was aborted.
+func processPackage(path string, fset *token.FileSet, files []*ast.File, imp types.Importer) *types.Package {
 	type bailout struct{}
 	ctxt := types.Context{
 		Error: func(err error) {
@@ -176,26 +301,63 @@ func processPackage(path string, fset *token.FileSet, files []*ast.File) {
 			}
 			report(err)
 		},
+		Import: imp,
 	}
 
-	defer func() {
-		switch err := recover().(type) {
-		case nil, bailout:
-		default:
-			panic(err)
-		}
+	var rec *typeRecorder
+	if *printTypes {
+		rec = newTypeRecorder(fset)
+		ctxt.Ident = rec.ident
+		ctxt.Expr = rec.expr
+	}
+
+	pkg := func() *types.Package {
+		defer func() {
+			switch err := recover().(type) {
+			case nil, bailout:
+			default:
+				panic(err)
+			}
+		}()
+		pkg, _ := ctxt.Check(path, fset, files...)
+		return pkg
 	}()
 
-	ctxt.Check(path, fset, files...)
+	if rec != nil {
+		rec.print()
+	}
+	return pkg
+}
+
+// importer is the Importer used for type-checking;
+// nil means the default (gc) importer.
+var importer types.Importer
+
+func initImporter() {
+	switch *compiler {
+	case "gc":
+		// use default
+	case "gccgo":
+		var inst types.GccgoInstallation
+		if err := inst.InitFromDriver("gccgo"); err != nil {
+			fmt.Fprintf(os.Stderr, "gotype: %s\n", err)
+			os.Exit(2)
+		}
+		importer = inst.Importer(nil)
+	default:
+		fmt.Fprintf(os.Stderr, "gotype: unknown compiler %q\n", *compiler)
+		os.Exit(2)
+	}
 }
 
 func main() {
 	flag.Usage = usage
 	flag.Parse()
+	initImporter()
 
 	if flag.NArg() == 0 {
 		fset := token.NewFileSet()
-		processPackage("<stdin>", fset, parseStdin(fset))
+		processPackage("<stdin>", fset, parseStdin(fset), importer)
 	} else {
 		processFiles("<files>", flag.Args(), true)
 	}
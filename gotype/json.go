@@ -0,0 +1,192 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the machine-readable output modes -json and -types.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"os"
+	"sort"
+
+	"code.google.com/p/go.tools/go/types"
+	"github.com/sourcegraph/go.tools/go/exact"
+)
+
+// A position is the JSON form of a token.Position.
+type position struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Offset   int    `json:"offset"`
+}
+
+func newPosition(p token.Position) *position {
+	if !p.IsValid() {
+		return nil
+	}
+	return &position{p.Filename, p.Line, p.Column, p.Offset}
+}
+
+// A diagnostic is the JSON form of an error (-json).
+type diagnostic struct {
+	Kind  string    `json:"kind"` // "syntax", "type", or "other"
+	Pos   *position `json:"pos,omitempty"`
+	Start *position `json:"start,omitempty"` // start of source range, if known
+	End   *position `json:"end,omitempty"`   // end of source range, if known
+	Msg   string    `json:"msg"`
+}
+
+var stdout = json.NewEncoder(os.Stdout)
+
+// reportJSON prints err as a sequence of diagnostics, one per line.
+func reportJSON(err error) {
+	switch err := err.(type) {
+	case scanner.ErrorList:
+		for _, e := range err {
+			reportJSON(e)
+		}
+	case *scanner.Error:
+		stdout.Encode(&diagnostic{Kind: "syntax", Pos: newPosition(err.Pos), Msg: err.Msg})
+	case types.Error:
+		stdout.Encode(&diagnostic{
+			Kind:  "type",
+			Pos:   newPosition(err.Fset.Position(err.Pos)),
+			Start: newPosition(err.Fset.Position(err.Start)),
+			End:   newPosition(err.Fset.Position(err.End)),
+			Msg:   err.Msg,
+		})
+	default:
+		stdout.Encode(&diagnostic{Kind: "other", Msg: err.Error()})
+	}
+}
+
+// A typeInfo is the JSON form of the type information recorded
+// for an identifier or expression (-types).
+type typeInfo struct {
+	Pos   *position `json:"pos"`
+	End   *position `json:"end"`
+	Expr  string    `json:"expr"`            // source form of the expression
+	Kind  string    `json:"kind,omitempty"`  // object kind, for identifiers denoting objects
+	Obj   *position `json:"obj,omitempty"`   // declaration of the object, if known
+	Type  string    `json:"type,omitempty"`  // type of the expression or object
+	Value string    `json:"value,omitempty"` // constant value, if any
+
+	pos, end token.Pos
+	obj      types.Object // object denoted by an identifier, or nil
+}
+
+// typeRecorder collects the type information reported
+// by the type checker for the -types mode.
+type typeRecorder struct {
+	fset  *token.FileSet
+	infos map[ast.Node]*typeInfo
+}
+
+func newTypeRecorder(fset *token.FileSet) *typeRecorder {
+	return &typeRecorder{fset, make(map[ast.Node]*typeInfo)}
+}
+
+func (r *typeRecorder) info(x ast.Expr) *typeInfo {
+	info := r.infos[x]
+	if info == nil {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, r.fset, x)
+		info = &typeInfo{
+			Pos:  newPosition(r.fset.Position(x.Pos())),
+			End:  newPosition(r.fset.Position(x.End())),
+			Expr: buf.String(),
+			pos:  x.Pos(),
+			end:  x.End(),
+		}
+		r.infos[x] = info
+	}
+	return info
+}
+
+// ident is a types.Context.Ident callback.
+func (r *typeRecorder) ident(id *ast.Ident, obj types.Object) {
+	if id.Name == "" {
+		return // not from source
+	}
+	r.info(id).obj = obj
+}
+
+// expr is a types.Context.Expr callback.
+func (r *typeRecorder) expr(x ast.Expr, typ types.Type, val exact.Value) {
+	info := r.info(x)
+	if typ != nil {
+		info.Type = typ.String()
+	}
+	if val != nil {
+		info.Value = val.String()
+	}
+}
+
+// print prints the collected information, one JSON object per line,
+// sorted by position (enclosing expressions first).
+func (r *typeRecorder) print() {
+	list := make([]*typeInfo, 0, len(r.infos))
+	for _, info := range r.infos {
+		list = append(list, info)
+	}
+	sort.Sort(byPos(list))
+	for _, info := range list {
+		if obj := info.obj; obj != nil {
+			// objects are complete only after type-checking
+			info.Kind = objKind(obj)
+			info.Obj = newPosition(r.fset.Position(obj.Pos()))
+			if _, isPkg := obj.(*types.Package); !isPkg && info.Type == "" && obj.Type() != nil {
+				info.Type = obj.Type().String()
+			}
+			if obj, ok := obj.(*types.Const); ok && info.Value == "" && obj.Val() != nil {
+				info.Value = obj.Val().String()
+			}
+		}
+		stdout.Encode(info)
+	}
+}
+
+type byPos []*typeInfo
+
+func (s byPos) Len() int { return len(s) }
+func (s byPos) Less(i, j int) bool {
+	if s[i].pos != s[j].pos {
+		return s[i].pos < s[j].pos
+	}
+	return s[i].end > s[j].end
+}
+func (s byPos) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// objKind returns the kind of obj: "package", "const", "type", "var",
+// "field", "func", or "method".
+func objKind(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Package:
+		return "package"
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	case *types.Field:
+		return "field"
+	case *types.Var:
+		if obj.FieldOf != nil {
+			return "field"
+		}
+		return "var"
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		return "func"
+	}
+	return ""
+}
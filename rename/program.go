@@ -0,0 +1,277 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rename implements type-safe renaming of Go entities.
+//
+// A Program holds a set of packages type-checked from source. Renaming
+// an object changes its declaration and every identifier denoting it,
+// in all packages of the program, and nothing else: objects that merely
+// have the same name are left alone. A renaming is refused if it is
+// unsafe, for instance if the new name would shadow or be shadowed by
+// another object, if a type would no longer implement an interface, or
+// if an object referenced by other packages would become unexported.
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+// A Program is a set of packages type-checked from source.
+type Program struct {
+	Fset     *token.FileSet
+	Packages []*Package // in the order in which they were added
+
+	ctxt    types.Context
+	imports map[string]*types.Package // packages imported via ctxt.Import
+}
+
+// A Package is a package of a Program.
+type Package struct {
+	Path  string
+	Files []*ast.File
+	Types *types.Package // the type-checked package
+	err   error          // first type-checking error, if any
+
+	idents []ident                      // identifiers denoting objects, in type-checking order
+	keys   map[types.Object]interface{} // object -> key; see computeKeys
+	objs   map[*ast.Ident]types.Object  // identifier -> denoted object
+}
+
+// An ident records an identifier and the object it denotes.
+type ident struct {
+	id  *ast.Ident
+	obj types.Object
+}
+
+// NewProgram returns a new, empty Program for packages whose files
+// belong to fset. If ctxt is not nil, it is used for type-checking.
+// Packages not added to the program are imported via ctxt.Import
+// (or the default importer).
+func NewProgram(fset *token.FileSet, ctxt *types.Context) *Program {
+	prog := &Program{
+		Fset:    fset,
+		imports: make(map[string]*types.Package),
+	}
+	if ctxt != nil {
+		prog.ctxt = *ctxt
+	}
+	return prog
+}
+
+// Add type-checks the package with the given path and files and adds
+// it to the program. Packages imported by it must have been added
+// before if they are to be renamed in as well. The first error reported
+// by the type checker is returned; objects can't be renamed while the
+// program has type errors.
+func (prog *Program) Add(path string, files ...*ast.File) error {
+	srcPkgs := make(map[string]*types.Package)
+	for _, p := range prog.Packages {
+		srcPkgs[p.Path] = p.Types
+	}
+	p, err := prog.check(path, files, srcPkgs)
+	if p == nil {
+		return err
+	}
+	prog.Packages = append(prog.Packages, p)
+	return err
+}
+
+// check type-checks a package. Imported packages are taken from srcPkgs
+// if present there.
+func (prog *Program) check(path string, files []*ast.File, srcPkgs map[string]*types.Package) (*Package, error) {
+	p := &Package{Path: path, Files: files}
+
+	ctxt := prog.ctxt
+	ctxt.Ident = func(id *ast.Ident, obj types.Object) {
+		p.idents = append(p.idents, ident{id, obj})
+		if f := prog.ctxt.Ident; f != nil {
+			f(id, obj)
+		}
+	}
+	imp := prog.ctxt.Import
+	if imp == nil {
+		imp = types.GcImport
+	}
+	ctxt.Import = func(imports map[string]*types.Package, path string) (*types.Package, error) {
+		pkg := srcPkgs[path]
+		if pkg == nil {
+			// share packages imported otherwise among all packages
+			// and type-checking runs so that their objects are unique
+			var err error
+			if pkg, err = imp(prog.imports, path); err != nil {
+				return nil, err
+			}
+		}
+		imports[path] = pkg
+		return pkg, nil
+	}
+
+	pkg, err := ctxt.Check(path, prog.Fset, files...)
+	if pkg == nil {
+		return nil, err
+	}
+	p.Types = pkg
+	p.err = err
+
+	p.objs = make(map[*ast.Ident]types.Object)
+	for _, e := range p.idents {
+		p.objs[e.id] = e.obj
+	}
+	p.computeKeys(prog.Fset)
+	return p, err
+}
+
+// computeKeys computes the keys of the objects denoted by the identifiers
+// of package p. A key identifies an object independent of its name and of
+// the type-checking run that produced it, even if the files were parsed
+// again: objects declared in p are identified by their declaring
+// identifier, given by its file name and index in the sequence of
+// identifiers of the file. Objects of other packages are shared among
+// runs and identify themselves.
+//
+func (p *Package) computeKeys(fset *token.FileSet) {
+	idents := make(map[token.Pos]identKey)
+	decls := make(map[*ast.Ident]bool) // identifiers declaring interface methods
+	for _, file := range p.Files {
+		filename := fset.Position(file.Pos()).Filename
+		i := 0
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				idents[n.Pos()] = identKey{filename, i}
+				i++
+			case *ast.InterfaceType:
+				for _, f := range n.Methods.List {
+					for _, name := range f.Names {
+						decls[name] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	p.keys = make(map[types.Object]interface{})
+	for _, e := range p.idents {
+		if key, ok := idents[e.obj.Pos()]; ok && e.obj.Pos().IsValid() {
+			p.keys[e.obj] = key
+		} else if decls[e.id] {
+			// interface methods have no position
+			p.keys[e.obj] = idents[e.id.Pos()]
+		}
+	}
+}
+
+// An identKey is the key of an object declared in the program.
+type identKey struct {
+	filename string
+	index    int // index of the declaring identifier in the file
+}
+
+// An anonymousField is the key of an anonymous struct field, which has
+// no position. It is identified by the struct (or rather the named type
+// declaring it, if any) and the embedded type.
+type anonymousField struct {
+	owner, typ interface{}
+}
+
+// A packageKey is the key of a package (or package name) object.
+type packageKey string
+
+// key returns the key of obj in the packages pkgs; see computeKeys.
+func key(pkgs []*Package, obj types.Object) interface{} {
+	if pkg, ok := obj.(*types.Package); ok {
+		// packages can't be renamed
+		return packageKey(pkg.Path())
+	}
+	for _, p := range pkgs {
+		if key := p.keys[obj]; key != nil {
+			return key
+		}
+	}
+	if f, ok := obj.(*types.Field); ok && f.IsAnonymous {
+		if t, ok := f.Type().Deref().(*types.Named); ok {
+			return anonymousField{key(pkgs, f.FieldOf), key(pkgs, t.Obj())}
+		}
+	}
+	return obj
+}
+
+// pkgOf returns the package of the program declaring obj, or nil.
+func (prog *Program) pkgOf(obj types.Object) *Package {
+	for _, p := range prog.Packages {
+		if p.Types == obj.Pkg() {
+			return p
+		}
+	}
+	return nil
+}
+
+// ObjectAt returns the object denoted by the identifier at pos,
+// or nil if there is no such identifier.
+func (prog *Program) ObjectAt(pos token.Pos) types.Object {
+	for _, p := range prog.Packages {
+		for id, obj := range p.objs {
+			if id.Pos() <= pos && pos < id.End() {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the object with the given qualified name, or nil.
+// A qualified name is the package path followed by the name of a
+// package-level object ("path.Name"), or of a method, struct field,
+// or interface method of the named type T ("path.T.Name").
+func (prog *Program) Lookup(name string) types.Object {
+	for _, p := range prog.Packages {
+		if !strings.HasPrefix(name, p.Path+".") {
+			continue
+		}
+		names := strings.Split(name[len(p.Path)+1:], ".")
+		if len(names) > 2 {
+			continue
+		}
+		obj := p.Types.Scope().Lookup(names[0])
+		if obj == nil || len(names) == 1 {
+			return obj
+		}
+		if obj, ok := obj.(*types.TypeName); ok {
+			if t, ok := obj.Type().(*types.Named); ok {
+				return member(t, names[1])
+			}
+		}
+	}
+	return nil
+}
+
+// member returns the method, struct field, or interface method of t
+// with the given name, or nil.
+func member(t *types.Named, name string) types.Object {
+	for i := 0; i < t.NumMethods(); i++ {
+		if m := t.Method(i); m.Name() == name {
+			return m
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if f := u.Field(i); f.Name() == name {
+				return f
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < u.NumMethods(); i++ {
+			if m := u.Method(i); m.Name() == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements renaming and the detection of conflicts.
+
+package rename
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"unicode"
+
+	"code.google.com/p/go.tools/go/types"
+)
+
+// A Conflict describes why a renaming is unsafe.
+type Conflict struct {
+	Pos token.Position // position of the offending identifier or declaration
+	Msg string
+}
+
+func (c *Conflict) Error() string {
+	return fmt.Sprintf("%s: %s", c.Pos, c.Msg)
+}
+
+// A ConflictList is the error returned for refused renamings.
+type ConflictList []*Conflict
+
+func (list ConflictList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no conflicts"
+	case 1:
+		return list[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more conflicts)", list[0], len(list)-1)
+}
+
+// Rename renames the object obj to newName in all packages of the
+// program. If the renaming is safe, the packages of the program are
+// replaced by the renamed ones (with newly parsed files), and the
+// files that changed are returned. Otherwise, the program is left
+// unchanged and the error is a ConflictList.
+//
+func (prog *Program) Rename(obj types.Object, newName string) ([]*ast.File, error) {
+	r := renamer{prog: prog, obj: obj, from: obj.Name(), to: newName}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	r.collect()
+	r.checkExport()
+	r.checkMembers()
+	r.checkMethods()
+	if len(r.conflicts) == 0 {
+		r.rename()
+	}
+	if len(r.conflicts) > 0 {
+		return nil, r.conflicts
+	}
+	return r.files, nil
+}
+
+// A renamer renames one object.
+type renamer struct {
+	prog      *Program
+	obj       types.Object
+	from, to  string
+	keys      map[interface{}]bool // keys of the objects to rename
+	refs      []*ast.Ident         // identifiers to rename
+	files     []*ast.File          // files containing refs
+	conflicts ConflictList
+}
+
+func (r *renamer) conflict(pos token.Pos, format string, args ...interface{}) {
+	r.conflicts = append(r.conflicts, &Conflict{r.prog.Fset.Position(pos), fmt.Sprintf(format, args...)})
+}
+
+// check reports whether obj can be renamed to the new name at all.
+func (r *renamer) check() error {
+	if !isIdentifier(r.to) || r.to == "_" {
+		return fmt.Errorf("invalid identifier %q", r.to)
+	}
+	if r.to == r.from {
+		return fmt.Errorf("%s is already named %s", r.from, r.to)
+	}
+	for _, p := range r.prog.Packages {
+		if p.err != nil {
+			return fmt.Errorf("package %s has type errors: %s", p.Path, p.err)
+		}
+	}
+	switch obj := r.obj.(type) {
+	case *types.Package:
+		return fmt.Errorf("cannot rename package %s", obj.Name())
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() == nil &&
+			r.prog.pkgOf(obj) != nil && obj.Pkg().Scope().Lookup(obj.Name()) == obj &&
+			(obj.Name() == "init" || obj.Name() == "main" && obj.Pkg().Name() == "main") {
+			return fmt.Errorf("cannot rename function %s", obj.Name())
+		}
+	}
+	if r.prog.pkgOf(r.obj) == nil {
+		return fmt.Errorf("cannot rename %s: not declared in a package of the program", r.from)
+	}
+	return nil
+}
+
+// collect collects the identifiers to rename. If obj is a named type,
+// the anonymous fields of that type are renamed as well.
+func (r *renamer) collect() {
+	pkgs := r.prog.Packages
+	r.keys = map[interface{}]bool{key(pkgs, r.obj): true}
+	for _, p := range pkgs {
+		for _, e := range p.idents {
+			if f, ok := e.obj.(*types.Field); ok && f.IsAnonymous {
+				if t, ok := f.Type().Deref().(*types.Named); ok && t.Obj() == r.obj {
+					r.keys[key(pkgs, f)] = true
+				}
+			}
+		}
+	}
+
+	seen := make(map[*ast.Ident]bool)
+	for _, p := range pkgs {
+		for _, e := range p.idents {
+			if r.keys[key(pkgs, e.obj)] && !seen[e.id] {
+				seen[e.id] = true
+				r.refs = append(r.refs, e.id)
+			}
+		}
+	}
+}
+
+// checkExport reports a conflict for each reference from another package
+// if an exported object would become unexported.
+func (r *renamer) checkExport() {
+	if !ast.IsExported(r.from) || ast.IsExported(r.to) {
+		return
+	}
+	decl := r.prog.pkgOf(r.obj)
+	for _, p := range r.prog.Packages {
+		if p == decl {
+			continue
+		}
+		for _, e := range p.idents {
+			if r.keys[key(r.prog.Packages, e.obj)] {
+				r.conflict(e.id.Pos(), "%s would be unexported but is used in package %s", r.from, p.Path)
+			}
+		}
+	}
+}
+
+// checkMembers reports a conflict if obj is a field or method of a
+// named type that already has a field or method with the new name.
+func (r *renamer) checkMembers() {
+	var t *types.Named
+	switch obj := r.obj.(type) {
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			t, _ = sig.Recv().Type().Deref().(*types.Named)
+		}
+	case *types.Field:
+		if obj.FieldOf != nil {
+			t, _ = obj.FieldOf.Type().(*types.Named)
+		}
+	}
+	if t != nil && member(t, r.from) == r.obj && member(t, r.to) != nil {
+		r.conflict(r.obj.Pos(), "type %s already has a field or method %s", t.Obj().Name(), r.to)
+	}
+}
+
+// checkMethods reports a conflict if obj is a method and renaming it
+// changes the method sets of types such that they no longer implement
+// interfaces.
+//
+func (r *renamer) checkMethods() {
+	m, ok := r.obj.(*types.Func)
+	if !ok {
+		return
+	}
+	sig, ok := m.Type().(*types.Signature)
+	if !ok {
+		return
+	}
+
+	named, ifaces := r.namedTypes()
+
+	if sig.Recv() != nil {
+		// concrete method
+		t, _ := sig.Recv().Type().Deref().(*types.Named)
+		if t == nil {
+			return
+		}
+		for _, iface := range ifaces {
+			it := iface.Underlying().(*types.Interface)
+			if member(iface, r.from) != nil && (types.Implements(t, it) || types.Implements(types.NewPointer(t), it)) {
+				r.conflict(m.Pos(), "renamed method %s.%s would no longer implement interface %s", t.Obj().Name(), r.from, iface.Obj().Name())
+			}
+		}
+		return
+	}
+
+	// interface method
+	for _, iface := range ifaces {
+		it := iface.Underlying().(*types.Interface)
+		if member(iface, r.from) != m {
+			continue
+		}
+		if member(iface, r.to) != nil {
+			r.conflict(r.refs[0].Pos(), "interface %s already has a method %s", iface.Obj().Name(), r.to)
+		}
+		for _, t := range named {
+			if types.Implements(t, it) || types.Implements(types.NewPointer(t), it) {
+				r.conflict(r.refs[0].Pos(), "type %s would no longer implement interface %s", t.Obj().Name(), iface.Obj().Name())
+			}
+		}
+	}
+}
+
+// namedTypes returns the named non-interface types declared in the
+// packages of the program, and the named interfaces declared in the
+// packages or imported by them.
+func (r *renamer) namedTypes() (named, ifaces []*types.Named) {
+	seen := make(map[*types.Package]bool)
+	var collect func(pkg *types.Package, local bool)
+	collect = func(pkg *types.Package, local bool) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		for _, obj := range pkg.Scope().Entries {
+			if obj, ok := obj.(*types.TypeName); ok {
+				if t, ok := obj.Type().(*types.Named); ok {
+					if _, isIface := t.Underlying().(*types.Interface); isIface {
+						ifaces = append(ifaces, t)
+					} else if local {
+						named = append(named, t)
+					}
+				}
+			}
+		}
+		for _, imp := range pkg.Imports() {
+			collect(imp, false)
+		}
+	}
+	for _, p := range r.prog.Packages {
+		collect(p.Types, true)
+	}
+	return
+}
+
+// rename renames the collected identifiers and type-checks the program
+// again, from newly parsed files. If the identifiers don't denote the
+// same objects as before, the renaming is reverted.
+func (r *renamer) rename() {
+	for _, id := range r.refs {
+		id.Name = r.to
+	}
+	defer func() {
+		if len(r.conflicts) > 0 {
+			for _, id := range r.refs {
+				id.Name = r.from
+			}
+		}
+	}()
+
+	var pkgs []*Package
+	srcPkgs := make(map[string]*types.Package)
+	for _, p := range r.prog.Packages {
+		files, err := r.reparse(p.Files)
+		if err != nil {
+			r.conflict(p.Files[0].Pos(), "%s", err)
+			return
+		}
+		q, err := r.prog.check(p.Path, files, srcPkgs)
+		if err != nil {
+			if err, ok := err.(types.Error); ok {
+				r.conflict(err.Pos, "%s", err.Msg)
+			} else {
+				r.conflict(p.Files[0].Pos(), "%s", err)
+			}
+			return
+		}
+		srcPkgs[p.Path] = q.Types
+		pkgs = append(pkgs, q)
+	}
+
+	r.compare(pkgs)
+	if len(r.conflicts) > 0 {
+		return
+	}
+
+	changed := make(map[*ast.File]bool)
+	for _, id := range r.refs {
+		for _, p := range r.prog.Packages {
+			for _, file := range p.Files {
+				if file.Pos() <= id.Pos() && id.Pos() < file.End() {
+					changed[file] = true
+				}
+			}
+		}
+	}
+	for i, p := range r.prog.Packages {
+		for j, file := range p.Files {
+			if changed[file] {
+				r.files = append(r.files, pkgs[i].Files[j])
+			}
+		}
+	}
+	r.prog.Packages = pkgs
+}
+
+// reparse prints and parses the files again.
+func (r *renamer) reparse(files []*ast.File) ([]*ast.File, error) {
+	var res []*ast.File
+	for _, file := range files {
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, r.prog.Fset, file); err != nil {
+			return nil, err
+		}
+		filename := r.prog.Fset.Position(file.Pos()).Filename
+		file, err := parser.ParseFile(r.prog.Fset, filename, buf.Bytes(), parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, file)
+	}
+	return res, nil
+}
+
+// compare reports a conflict for each identifier that denotes a
+// different object in pkgs than before. The files of pkgs correspond
+// to the files of the program; their identifiers are matched up by
+// their order.
+//
+func (r *renamer) compare(pkgs []*Package) {
+	old := r.prog.Packages
+	for i, p := range old {
+		q := pkgs[i]
+		for j, file := range p.Files {
+			ids, newIds := identifiers(file), identifiers(q.Files[j])
+			for k, id := range ids {
+				obj, newObj := p.objs[id], q.objs[newIds[k]]
+				switch {
+				case obj == nil:
+					// nothing to check
+				case newObj == nil:
+					r.conflict(id.Pos(), "%s would be undefined", id.Name)
+				case key(old, obj) == key(pkgs, newObj):
+					// same object
+				case r.keys[key(old, obj)]:
+					r.conflict(id.Pos(), "renamed %s would refer to %s", r.from, r.describe(newObj, pkgs))
+				default:
+					r.conflict(id.Pos(), "%s would refer to renamed %s", r.describe(obj, old), r.from)
+				}
+			}
+		}
+	}
+}
+
+// identifiers returns the identifiers of file in source order.
+func identifiers(file *ast.File) []*ast.Ident {
+	var list []*ast.Ident
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			list = append(list, id)
+		}
+		return true
+	})
+	return list
+}
+
+// describe returns a description of obj of pkgs for conflict messages.
+func (r *renamer) describe(obj types.Object, pkgs []*Package) string {
+	if key, ok := key(pkgs, obj).(identKey); ok {
+		return fmt.Sprintf("%s declared in %s", obj.Name(), key.filename)
+	}
+	return obj.Name()
+}
+
+// isIdentifier reports whether name is a valid Go identifier.
+func isIdentifier(name string) bool {
+	if name == "" || token.Lookup(name).IsKeyword() {
+		return false
+	}
+	for i, ch := range name {
+		if !unicode.IsLetter(ch) && ch != '_' && (i == 0 || !unicode.IsDigit(ch)) {
+			return false
+		}
+	}
+	return true
+}
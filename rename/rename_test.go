@@ -0,0 +1,184 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rename
+
+import (
+	"bytes"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const srcP = `package p
+
+type T struct {
+	X int
+	E
+}
+
+type E struct{}
+
+func (t *T) M() int {
+	X := 1
+	return t.X + X
+}
+
+func (E) N() {}
+
+type I interface {
+	N()
+}
+
+var _ I = E{}
+
+func F(x int) int {
+	y := x
+	{
+		x := 2
+		y += x
+	}
+	return y
+}
+
+var V T
+`
+
+const srcQ = `package q
+
+import "p"
+
+func G() int {
+	var t p.T
+	t.N()
+	return t.M() + t.X + p.V.X
+}
+`
+
+func load(t *testing.T) *Program {
+	fset := token.NewFileSet()
+	prog := NewProgram(fset, nil)
+	for _, f := range []struct{ path, src string }{{"p", srcP}, {"q", srcQ}} {
+		file, err := parser.ParseFile(fset, f.path+".go", f.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := prog.Add(f.path, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return prog
+}
+
+// source returns the source of the files of prog.
+func source(t *testing.T, prog *Program) string {
+	var buf bytes.Buffer
+	for _, p := range prog.Packages {
+		for _, file := range p.Files {
+			if err := printer.Fprint(&buf, prog.Fset, file); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return buf.String()
+}
+
+func TestRename(t *testing.T) {
+	for _, test := range []struct {
+		from, to string
+		repl     []string // old, new pairs applied to srcP+srcQ to obtain the expected result
+		files    int      // number of changed files
+	}{
+		{"p.T.X", "Y", []string{"X\tint\n\tE", "Y\tint\n\tE", "t.X", "t.Y", "p.V.X", "p.V.Y"}, 2},
+		{"p.E", "Embedded", []string{"\tE\n}", "\tEmbedded\n}", "type E struct", "type Embedded struct", "(E) N", "(Embedded) N", "= E{}", "= Embedded{}"}, 1},
+		{"p.V", "W", []string{"var V T", "var W T", "p.V.X", "p.W.X"}, 2},
+		{"p.F", "H", []string{"func F(", "func H("}, 1},
+	} {
+		prog := load(t)
+		obj := prog.Lookup(test.from)
+		if obj == nil {
+			t.Errorf("%s not found", test.from)
+			continue
+		}
+		files, err := prog.Rename(obj, test.to)
+		if err != nil {
+			t.Errorf("renaming %s to %s: %s", test.from, test.to, err)
+			continue
+		}
+		if len(files) != test.files {
+			t.Errorf("renaming %s to %s: got %d changed files; want %d", test.from, test.to, len(files), test.files)
+		}
+		want := strings.NewReplacer(test.repl...).Replace(source(t, load(t)))
+		if got := source(t, prog); got != want {
+			t.Errorf("renaming %s to %s: got\n%s\nwant\n%s", test.from, test.to, got, want)
+		}
+	}
+}
+
+func TestRenameLocal(t *testing.T) {
+	prog := load(t)
+	// the inner x
+	file := prog.Packages[0].Files[0]
+	pos := file.Pos() + token.Pos(strings.Index(srcP, "x := 2"))
+	obj := prog.ObjectAt(pos)
+	if obj == nil || obj.Name() != "x" {
+		t.Fatalf("got object %v at x := 2", obj)
+	}
+	if _, err := prog.Rename(obj, "z"); err != nil {
+		t.Fatal(err)
+	}
+	got := source(t, prog)
+	for _, s := range []string{"y := x\n", "z := 2", "y += z", "func F(x int)"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("renamed source doesn't contain %q:\n%s", s, got)
+		}
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	for _, test := range []struct {
+		from, to string
+		err      string // substring of expected error
+	}{
+		{"p.T.X", "E", "already has a field or method E"},            // would conflict with anonymous field
+		{"p.T.M", "X", "already has a field or method X"},            // field and method conflict
+		{"p.E.N", "O", "would no longer implement interface I"},      // breaks interface satisfaction
+		{"p.I.N", "O", "would no longer implement interface I"},      // breaks implementations
+		{"p.V", "v", "would be unexported but is used in package q"}, // unexported across packages
+		{"p.F", "func", `invalid identifier "func"`},                 // not an identifier
+		{"p.F", "V", "redeclared"},                                   // redeclaration
+	} {
+		prog := load(t)
+		before := source(t, prog)
+		obj := prog.Lookup(test.from)
+		if obj == nil {
+			t.Errorf("%s not found", test.from)
+			continue
+		}
+		_, err := prog.Rename(obj, test.to)
+		if err == nil {
+			t.Errorf("renaming %s to %s: got no error", test.from, test.to)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("renaming %s to %s: got error %q; want %q", test.from, test.to, err, test.err)
+		}
+		if source(t, prog) != before {
+			t.Errorf("renaming %s to %s: source changed despite error", test.from, test.to)
+		}
+	}
+}
+
+func TestShadowing(t *testing.T) {
+	prog := load(t)
+	file := prog.Packages[0].Files[0]
+	pos := file.Pos() + token.Pos(strings.Index(srcP, "x := 2"))
+	// renaming the inner x to y would capture the reference in y += x
+	_, err := prog.Rename(prog.ObjectAt(pos), "y")
+	if err == nil || !strings.Contains(err.Error(), "would refer to renamed x") {
+		t.Errorf("got error %v; want shadowing conflict", err)
+	}
+}
@@ -80,6 +80,7 @@ func jumpThreading(f *Function, b *BasicBlock) bool {
 		if len(a.Succs) == 2 && a.Succs[0] == c && a.Succs[1] == c {
 			jump := new(Jump)
 			jump.SetBlock(a)
+			dropReferrers(a.Instrs[len(a.Instrs)-1])
 			a.Instrs[len(a.Instrs)-1] = jump
 			a.Succs = a.Succs[:1]
 			c.removePred(b)
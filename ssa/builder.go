@@ -111,7 +111,11 @@ const (
 	SanityCheckFunctions                         // Perform sanity checking of function bodies
 	UseGCImporter                                // Ignore SourceLoader; use gc-compiled object code for all imports
 	NaiveForm                                    // Build naïve SSA form: don't replace local loads/stores with registers
-	BuildSerially                                // Build packages serially, not in parallel.
+	BuildSerially                                // Build packages and functions serially, not in parallel.
+	ConstantPropagation                          // Fold constants and prune infeasible branches (SCCP)
+	ValueNumbering                               // Merge equivalent pure computations (GVN)
+	DeadCodeElimination                          // Delete unused pure computations
+	DebugInfo                                    // Emit DebugRef instructions mapping source expressions to SSA values
 )
 
 // A Builder creates the SSA representation of a single program.
@@ -454,7 +458,7 @@ func (b *Builder) selector(fn *Function, e *ast.SelectorExpr, wantAddr, escaping
 	if !wantAddr {
 		if m, recv := b.findMethod(fn, e.X, id); m != nil {
 			c := &MakeClosure{
-				Fn:       makeBoundMethodThunk(b.Prog, m, recv),
+				Fn:       makeBoundMethodThunk(b.Prog, m),
 				Bindings: []Value{recv},
 			}
 			c.setPos(e.Sel.Pos())
@@ -588,7 +592,8 @@ func (b *Builder) addr(fn *Function, e ast.Expr, escaping bool) lvalue {
 		if !ok {
 			v = fn.lookup(obj, escaping)
 		}
-		return address{v}
+		emitDebugRef(fn, e, v, true)
+		return address{addr: v, expr: e}
 
 	case *ast.CompositeLit:
 		t := fn.Pkg.TypeOf(e).Deref()
@@ -599,7 +604,7 @@ func (b *Builder) addr(fn *Function, e ast.Expr, escaping bool) lvalue {
 			v = fn.addLocal(t, e.Lbrace)
 		}
 		b.compLit(fn, v, e, t) // initialize in place
-		return address{v}
+		return address{addr: v}
 
 	case *ast.ParenExpr:
 		return b.addr(fn, e.X, escaping)
@@ -608,13 +613,13 @@ func (b *Builder) addr(fn *Function, e ast.Expr, escaping bool) lvalue {
 		// p.M where p is a package.
 		if obj := fn.Pkg.isPackageRef(e); obj != nil {
 			if v, ok := b.lookup(fn.Pkg, obj); ok {
-				return address{v}
+				return address{addr: v}
 			}
 			panic("undefined package-qualified name: " + obj.Name())
 		}
 
 		// e.f where e is an expression.
-		return address{b.selector(fn, e, true, escaping)}
+		return address{addr: b.selector(fn, e, true, escaping)}
 
 	case *ast.IndexExpr:
 		var x Value
@@ -643,10 +648,10 @@ func (b *Builder) addr(fn *Function, e ast.Expr, escaping bool) lvalue {
 			Index: emitConv(fn, b.expr(fn, e.Index), tInt),
 		}
 		v.setType(et)
-		return address{fn.emit(v)}
+		return address{addr: fn.emit(v)}
 
 	case *ast.StarExpr:
-		return address{b.expr(fn, e.X)}
+		return address{addr: b.expr(fn, e.X)}
 	}
 
 	panic(fmt.Sprintf("unexpected address expression: %T", e))
@@ -676,6 +681,9 @@ func (b *Builder) exprInPlace(fn *Function, loc lvalue, e ast.Expr) {
 
 			default:
 				b.compLit(fn, addr.addr, e, typ) // in place
+				if addr.expr != nil {
+					emitDebugRef(fn, addr.expr, addr.addr, true)
+				}
 				return
 			}
 		}
@@ -687,10 +695,18 @@ func (b *Builder) exprInPlace(fn *Function, loc lvalue, e ast.Expr) {
 // to fn and returning the Value defined by the expression.
 //
 func (b *Builder) expr(fn *Function, e ast.Expr) Value {
+	var v Value
 	if lit := fn.Pkg.ValueOf(e); lit != nil {
-		return lit
+		v = lit
+	} else {
+		v = b.expr0(fn, e)
 	}
+	emitDebugRef(fn, e, v, false)
+	return v
+}
 
+// expr0 is the non-constant case of expr, without debug info.
+func (b *Builder) expr0(fn *Function, e ast.Expr) Value {
 	switch e := e.(type) {
 	case *ast.BasicLit:
 		panic("non-constant BasicLit") // unreachable
@@ -1183,7 +1199,7 @@ func (b *Builder) globalValueSpec(init *Function, spec *ast.ValueSpec, g *Global
 					continue
 				}
 				g.spec = nil
-				lval = address{g}
+				lval = address{addr: g, expr: id}
 			} else {
 				// Mode B: initialize all globals.
 				if !isBlankIdent(id) {
@@ -1192,7 +1208,7 @@ func (b *Builder) globalValueSpec(init *Function, spec *ast.ValueSpec, g *Global
 						continue // already done
 					}
 					g2.spec = nil
-					lval = address{g2}
+					lval = address{addr: g2, expr: id}
 				}
 			}
 			if b.Context.Mode&LogSource != 0 {
@@ -1245,7 +1261,7 @@ func (b *Builder) localValueSpec(fn *Function, spec *ast.ValueSpec) {
 		for i, id := range spec.Names {
 			var lval lvalue = blank{}
 			if !isBlankIdent(id) {
-				lval = address{fn.addNamedLocal(fn.Pkg.ObjectOf(id))}
+				lval = address{addr: fn.addNamedLocal(fn.Pkg.ObjectOf(id)), expr: id}
 			}
 			b.exprInPlace(fn, lval, spec.Values[i])
 		}
@@ -1265,8 +1281,8 @@ func (b *Builder) localValueSpec(fn *Function, spec *ast.ValueSpec) {
 		result := tuple.Type().(*types.Tuple)
 		for i, id := range spec.Names {
 			if !isBlankIdent(id) {
-				lhs := fn.addNamedLocal(fn.Pkg.ObjectOf(id))
-				emitStore(fn, lhs, emitExtract(fn, tuple, i, result.At(i).Type()))
+				lhs := address{addr: fn.addNamedLocal(fn.Pkg.ObjectOf(id)), expr: id}
+				lhs.store(fn, emitExtract(fn, tuple, i, result.At(i).Type()))
 			}
 		}
 	}
@@ -1373,7 +1389,7 @@ func (b *Builder) compLit(fn *Function, addr Value, e *ast.CompositeLit, typ typ
 			}
 			faddr.setType(pointer(sf.Type))
 			fn.emit(faddr)
-			b.exprInPlace(fn, address{faddr}, e)
+			b.exprInPlace(fn, address{addr: faddr}, e)
 		}
 
 	case *types.Array, *types.Slice:
@@ -1406,7 +1422,7 @@ func (b *Builder) compLit(fn *Function, addr Value, e *ast.CompositeLit, typ typ
 			}
 			iaddr.setType(pointer(at.Elem()))
 			fn.emit(iaddr)
-			b.exprInPlace(fn, address{iaddr}, e)
+			b.exprInPlace(fn, address{addr: iaddr}, e)
 		}
 		if t != at { // slice
 			s := &Slice{X: array}
@@ -2307,9 +2323,11 @@ start:
 	}
 }
 
-// buildFunction builds SSA code for the body of function fn.  Idempotent.
+// buildFunction builds SSA code for the body of function fn.
+//
+// buildFunction is idempotent and thread-safe.
 func (b *Builder) buildFunction(fn *Function) {
-	if fn.Blocks != nil {
+	if !atomic.CompareAndSwapInt32(&fn.started, 0, 1) {
 		return // building already started
 	}
 	if fn.syntax == nil {
@@ -2317,21 +2335,14 @@ func (b *Builder) buildFunction(fn *Function) {
 	}
 	if fn.syntax.body == nil {
 		// External function.
-		if fn.Params == nil {
-			// This condition ensures we add a non-empty
-			// params list once only, but we may attempt
-			// the degenerate empty case repeatedly.
-			// TODO(adonovan): opt: don't do that.
-
-			// We set Function.Params even though there is no body
-			// code to reference them.  This simplifies clients.
-			if recv := fn.Signature.Recv(); recv != nil {
-				fn.addParam(recv.Name(), recv.Type())
-			}
-			fn.Signature.Params().ForEach(func(p *types.Var) {
-				fn.addParam(p.Name(), p.Type())
-			})
+		// We set Function.Params even though there is no body
+		// code to reference them.  This simplifies clients.
+		if recv := fn.Signature.Recv(); recv != nil {
+			fn.addParam(recv.Name(), recv.Type())
 		}
+		fn.Signature.Params().ForEach(func(p *types.Var) {
+			fn.addParam(p.Name(), p.Type())
+		})
 		return
 	}
 	if fn.Prog.mode&LogSource != 0 {
@@ -2600,38 +2611,22 @@ func (b *Builder) createPackageImpl(typkg *types.Package, importPath string, fil
 	return p
 }
 
-// buildDecl builds SSA code for all globals, functions or methods
-// declared by decl in package pkg.
+// buildDecl builds SSA code for all globals or init blocks declared
+// by decl in package pkg.  Functions and methods are built only when
+// referenced; see declaredFuncs.
 //
 func (b *Builder) buildDecl(pkg *Package, decl ast.Decl) {
 	switch decl := decl.(type) {
 	case *ast.GenDecl:
-		switch decl.Tok {
-		// Nothing to do for CONST, IMPORT.
-		case token.VAR:
+		// Nothing to do for CONST, IMPORT, TYPE.
+		if decl.Tok == token.VAR {
 			for _, spec := range decl.Specs {
 				b.globalValueSpec(pkg.Init, spec.(*ast.ValueSpec), nil, nil)
 			}
-		case token.TYPE:
-			for _, spec := range decl.Specs {
-				id := spec.(*ast.TypeSpec).Name
-				if isBlankIdent(id) {
-					continue
-				}
-				obj := pkg.ObjectOf(id).(*types.TypeName)
-				nt := obj.Type().(*types.Named)
-				nt.ForEachMethod(func(m *types.Func) {
-					b.buildFunction(b.Prog.concreteMethods[m])
-				})
-			}
 		}
 
 	case *ast.FuncDecl:
-		id := decl.Name
-		if isBlankIdent(id) {
-			// no-op
-
-		} else if decl.Recv == nil && id.Name == "init" {
+		if decl.Recv == nil && decl.Name.Name == "init" {
 			// init() block
 			if b.Context.Mode&LogSource != 0 {
 				fmt.Fprintln(os.Stderr, "build init block @", b.Prog.Files.Position(decl.Pos()))
@@ -2654,18 +2649,49 @@ func (b *Builder) buildDecl(pkg *Package, decl ast.Decl) {
 			emitJump(init, next)
 			init.targets = init.targets.tail
 			init.currentBlock = next
-
-		} else if m, ok := b.globals[pkg.ObjectOf(id)]; ok {
-			// Package-level function.
-			b.buildFunction(m.(*Function))
 		}
 	}
+}
 
+// declaredFuncs returns the package-level functions and the methods
+// declared in package pkg, in source order.
+func (b *Builder) declaredFuncs(pkg *Package) []*Function {
+	var fns []*Function
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					id := spec.(*ast.TypeSpec).Name
+					if isBlankIdent(id) {
+						continue
+					}
+					nt := pkg.ObjectOf(id).Type().(*types.Named)
+					nt.ForEachMethod(func(m *types.Func) {
+						fns = append(fns, b.Prog.concreteMethods[m])
+					})
+				}
+
+			case *ast.FuncDecl:
+				if isBlankIdent(decl.Name) || decl.Recv != nil {
+					continue // methods are found via their type
+				}
+				if fn, ok := b.globals[pkg.ObjectOf(decl.Name)].(*Function); ok {
+					fns = append(fns, fn) // (not an init block)
+				}
+			}
+		}
+	}
+	return fns
 }
 
 // BuildAllPackages constructs the SSA representation of the bodies of
 // all functions in all packages known to the Builder.  Construction
-// occurs in parallel unless the BuildSerially mode flag was set.
+// of packages, and of functions within each package, occurs in
+// parallel unless the BuildSerially mode flag was set.
 //
 // BuildAllPackages is idempotent and thread-safe.
 //
@@ -2746,15 +2772,31 @@ func (b *Builder) BuildPackage(p *Package) {
 	// topological order.  We visit them transitively through
 	// functions of the same package, but we don't treat functions
 	// as roots.
-	//
-	// We also ensure all functions and methods are built, even if
-	// they are unreachable.
 	for _, file := range p.Files {
 		for _, decl := range file.Decls {
 			b.buildDecl(p, decl)
 		}
 	}
 
+	// Now ensure all functions and methods are built, even if
+	// they are unreachable.  All vars have been initialized, so
+	// building them emits no further init() code, and the result
+	// does not depend on the order.  Construction occurs in
+	// parallel unless the BuildSerially mode flag was set.
+	var wg sync.WaitGroup
+	for _, fn := range b.declaredFuncs(p) {
+		if b.Context.Mode&BuildSerially != 0 {
+			b.buildFunction(fn)
+		} else {
+			wg.Add(1)
+			go func(fn *Function) {
+				b.buildFunction(fn)
+				wg.Done()
+			}(fn)
+		}
+	}
+	wg.Wait()
+
 	// Clear out the typed ASTs unless otherwise requested.
 	if retain := b.Context.RetainAST; retain == nil || !retain(p) {
 		p.Files = nil
@@ -0,0 +1,86 @@
+package ssa_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"sort"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+const parallelSrc = `package main
+
+func f() int { return b + 1 }
+
+type T int
+
+func (t T) m() int { return int(t) + a }
+
+var a = f()
+
+var b, c = g(), h()
+
+func g() int { return len(s) }
+
+func h() int { return T(2).m() }
+
+var s = []int{1, 2, 3}
+
+func init() { a++ }
+
+func main() {
+	println(a, b, c, func() int { return a * b }())
+}
+`
+
+// dumpPackage returns the text of the init function and
+// package-level functions of pkg, and their anonymous functions.
+func dumpPackage(t *testing.T, pkg *ssa.Package) string {
+	var names []string
+	for name, mem := range pkg.Members {
+		if _, ok := mem.(*ssa.Function); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	fns := []*ssa.Function{pkg.Init}
+	for _, name := range names {
+		fns = append(fns, pkg.Func(name))
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(fns); i++ {
+		if err := fns[i].WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		fns = append(fns, fns[i].AnonFuncs...)
+	}
+	return buf.String()
+}
+
+// TestParallelBuild checks that building the functions of a package
+// in parallel yields the same code, and in particular the same init
+// order, as building them serially.
+func TestParallelBuild(t *testing.T) {
+	build := func(mode ssa.BuilderMode) string {
+		b := ssa.NewBuilder(&ssa.Context{Mode: mode | ssa.SanityCheckFunctions, Loader: ssa.MakeGoBuildLoader(nil)})
+		file, err := parser.ParseFile(b.Prog.Files, "main.go", parallelSrc, parser.DeclarationErrors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, err := b.CreatePackage("main", []*ast.File{file})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.BuildPackage(pkg)
+		return dumpPackage(t, pkg)
+	}
+
+	want := build(ssa.BuildSerially)
+	for i := 0; i < 10; i++ {
+		if got := build(0); got != want {
+			t.Fatalf("parallel build differs from serial build:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Package callgraph constructs whole-program call graphs for programs
+// in SSA form.
+//
+// A call graph is a directed graph whose nodes are the functions of a
+// program and whose edges are the possible calls between them.  Each
+// edge is labelled by its call site, i.e. the *ssa.Call, *ssa.Go or
+// *ssa.Defer instruction that makes the call; a single call site may
+// have several callees.
+//
+// Static calls (see ssa.CallCommon.StaticCallee) have a single
+// callee.  The callees of dynamic calls are computed by one of two
+// conservative algorithms:
+//
+// Class Hierarchy Analysis (CHA) assumes that an "invoke"-mode call of
+// method m of interface I may dispatch to method m of any type in the
+// program that implements I, and that a call of a function value of
+// type F may call any address-taken function of type F.  All functions
+// of the program are nodes of the graph.
+//
+// Rapid Type Analysis (RTA) starts from the main and init functions of
+// the program and considers only reachable functions: an "invoke"-mode
+// call may dispatch only to methods of the types converted to an
+// interface (by MakeInterface) in reachable code, and a dynamic
+// function call may call only functions whose address is taken in
+// reachable code.  Only reachable functions are nodes of the graph.
+// RTA is more precise than CHA but requires a whole program.
+//
+// Both algorithms treat calls made by the runtime (e.g. via reflection,
+// or the finalizers set by runtime.SetFinalizer) as unknown.
+package callgraph
+
+import (
+	"fmt"
+	"sort"
+
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/ssa"
+)
+
+// An Algorithm selects the method used to compute the callees of
+// dynamic calls.
+type Algorithm int
+
+const (
+	CHA Algorithm = iota // Class Hierarchy Analysis
+	RTA                  // Rapid Type Analysis
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case CHA:
+		return "CHA"
+	case RTA:
+		return "RTA"
+	}
+	return fmt.Sprintf("Algorithm(%d)", int(a))
+}
+
+// A Graph is a call graph of a program.
+type Graph struct {
+	Prog  *ssa.Program
+	Roots []*Node                 // for RTA, the main and init functions; for CHA, nil
+	Nodes map[*ssa.Function]*Node // all nodes of the graph, keyed by function
+}
+
+// A Node represents a function in the call graph.
+type Node struct {
+	Func *ssa.Function
+	In   []*Edge // incoming edges, i.e. calls of Func
+	Out  []*Edge // outgoing edges, i.e. calls made by Func
+}
+
+// An Edge represents a possible call from Caller to Callee at the
+// call site Site, which is an *ssa.Call, *ssa.Go or *ssa.Defer
+// instruction of Caller.Func.
+type Edge struct {
+	Caller *Node
+	Site   ssa.Instruction
+	Callee *Node
+}
+
+func (e *Edge) String() string {
+	return fmt.Sprintf("%s --> %s", e.Caller.Func.FullName(), e.Callee.Func.FullName())
+}
+
+// Common returns the call of the edge's call site.
+func (e *Edge) Common() *ssa.CallCommon {
+	return callCommon(e.Site)
+}
+
+// Build computes the call graph of prog, whose packages must have been
+// built, using the specified algorithm.
+func Build(prog *ssa.Program, algo Algorithm) *Graph {
+	g := New(prog)
+	switch algo {
+	case CHA:
+		cha(g)
+	case RTA:
+		rta(g)
+	default:
+		panic(fmt.Sprintf("callgraph: unknown algorithm %s", algo))
+	}
+	return g
+}
+
+// New returns a call graph of prog with no nodes, for use by clients
+// that compute their own call graphs (such as pointer analyses) with
+// CreateNode and AddEdge.
+func New(prog *ssa.Program) *Graph {
+	return &Graph{
+		Prog:  prog,
+		Nodes: make(map[*ssa.Function]*Node),
+	}
+}
+
+// CreateNode returns the node for fn, creating it if necessary.
+func (g *Graph) CreateNode(fn *ssa.Function) *Node {
+	n := g.Nodes[fn]
+	if n == nil {
+		n = &Node{Func: fn}
+		g.Nodes[fn] = n
+	}
+	return n
+}
+
+// AddEdge adds an edge from caller to callee at site, unless it
+// exists, creating the callee's node if necessary.
+func (g *Graph) AddEdge(caller *Node, site ssa.Instruction, callee *ssa.Function) {
+	to := g.CreateNode(callee)
+	for _, e := range caller.Out {
+		if e.Site == site && e.Callee == to {
+			return
+		}
+	}
+	e := &Edge{caller, site, to}
+	caller.Out = append(caller.Out, e)
+	to.In = append(to.In, e)
+}
+
+// Callees returns the functions possibly called at site, an
+// instruction of a function of the graph, in no particular order.
+func (g *Graph) Callees(site ssa.Instruction) []*ssa.Function {
+	n := g.Nodes[site.Block().Func]
+	if n == nil {
+		return nil
+	}
+	var callees []*ssa.Function
+	for _, e := range n.Out {
+		if e.Site == site {
+			callees = append(callees, e.Callee.Func)
+		}
+	}
+	return callees
+}
+
+// SortedNodes returns the nodes of the graph sorted by the full names
+// and positions of their functions.
+func (g *Graph) SortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Sort(byName(nodes))
+	return nodes
+}
+
+type byName []*Node
+
+func (p byName) Len() int      { return len(p) }
+func (p byName) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byName) Less(i, j int) bool {
+	x, y := p[i].Func, p[j].Func
+	if xname, yname := x.FullName(), y.FullName(); xname != yname {
+		return xname < yname
+	}
+	return x.Pos() < y.Pos()
+}
+
+// callCommon returns the call of instr if it is a call site, or nil.
+func callCommon(instr ssa.Instruction) *ssa.CallCommon {
+	switch instr := instr.(type) {
+	case *ssa.Call:
+		return &instr.Call
+	case *ssa.Go:
+		return &instr.Call
+	case *ssa.Defer:
+		return &instr.Call
+	}
+	return nil
+}
+
+// A siteKind classifies a call site.
+type siteKind int
+
+const (
+	staticCall  siteKind = iota // call of a statically known function
+	invokeCall                  // "invoke"-mode call of an interface method
+	dynamicCall                 // call of a function value
+	builtinCall                 // call of a built-in function
+)
+
+// classify returns the kind of the call c.
+func classify(c *ssa.CallCommon) siteKind {
+	switch {
+	case c.IsInvoke():
+		return invokeCall
+	case c.StaticCallee() != nil:
+		return staticCall
+	}
+	if _, ok := c.Func.(*ssa.Builtin); ok {
+		return builtinCall
+	}
+	return dynamicCall
+}
+
+// implements reports whether a type with method set mset implements
+// the interface iface.
+func implements(mset ssa.MethodSet, iface *types.Interface) bool {
+	for i, n := 0, iface.NumMethods(); i < n; i++ {
+		m := iface.Method(i)
+		fn := mset[ssa.MakeId(m.Name(), m.Pkg())]
+		if fn == nil || !types.IsIdentical(fn.Signature, m.Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// A typeSet is a set of types, up to identity.  Distinct but identical
+// types, such as unnamed struct types, have distinct method sets that
+// must be considered only once.
+type typeSet map[string][]types.Type // types, by their string (a hash)
+
+// add adds typ to s and reports whether it was not already present.
+func (s typeSet) add(typ types.Type) bool {
+	key := typ.String()
+	for _, t := range s[key] {
+		if types.IsIdentical(t, typ) {
+			return false
+		}
+	}
+	s[key] = append(s[key], typ)
+	return true
+}
+
+// signature returns the function type of the function value
+// called by c, which must be in "call" mode.
+func signature(c *ssa.CallCommon) *types.Signature {
+	return c.Func.Type().Underlying().(*types.Signature)
+}
+
+// addressTaken calls f for each function used as a value (rather
+// than called directly) by instr.
+func addressTaken(instr ssa.Instruction, f func(*ssa.Function)) {
+	if mc, ok := instr.(*ssa.MakeClosure); ok {
+		// A closure that is only called directly is not
+		// address-taken; its calls are static.
+		if !onlyCalled(mc) {
+			f(mc.Fn.(*ssa.Function))
+		}
+		return
+	}
+	var callee *ssa.Value // operand in callee position, if any
+	if c := callCommon(instr); c != nil && !c.IsInvoke() {
+		callee = &c.Func
+	}
+	var rands [10]*ssa.Value // reuse storage
+	for _, rand := range instr.Operands(rands[:0]) {
+		if fn, ok := (*rand).(*ssa.Function); ok && rand != callee {
+			f(fn)
+		}
+	}
+}
+
+// onlyCalled reports whether the closure mc is only ever called
+// directly.
+func onlyCalled(mc *ssa.MakeClosure) bool {
+	for _, ref := range *mc.Referrers() {
+		c := callCommon(ref)
+		if c == nil || c.IsInvoke() || c.Func != mc {
+			return false
+		}
+		for _, arg := range c.Args {
+			if arg == mc {
+				return false
+			}
+		}
+	}
+	return true
+}
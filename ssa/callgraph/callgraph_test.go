@@ -0,0 +1,141 @@
+package callgraph_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/callgraph"
+)
+
+const src = `package main
+
+type I interface{ f() }
+
+type A struct{}
+
+func (A) f() {}
+
+type B struct{}
+
+func (*B) f() {}
+
+type C struct{} // never converted to an interface
+
+func (C) f() {}
+
+type D struct{ A } // has bridge methods D.f and (*D).f
+
+func g() {}
+
+func h() {} // never used as a value
+
+var fn = g
+
+func main() {
+	var i I = A{}
+	i.f()
+	fn()
+	_ = C{}
+	m(struct{ A }{})
+	m(k())
+}
+
+// k converts a struct type identical to the one in main to I.
+func k() I { return struct{ A }{} }
+
+func m(i I) { i.f() }
+`
+
+func build(t *testing.T) *ssa.Program {
+	b := ssa.NewBuilder(&ssa.Context{Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", src, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return b.Prog
+}
+
+// callees returns the sorted names of the functions called by the
+// function named name.
+func callees(g *callgraph.Graph, name string) []string {
+	var names []string
+	for fn, n := range g.Nodes {
+		if fn.FullName() != name {
+			continue
+		}
+		for _, e := range n.Out {
+			names = append(names, e.Callee.Func.FullName())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasNode reports whether g has a node for the function named name.
+func hasNode(g *callgraph.Graph, name string) bool {
+	for fn := range g.Nodes {
+		if fn.FullName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCHA(t *testing.T) {
+	g := callgraph.Build(build(t), callgraph.CHA)
+	// The method sets of A and *A share (main.A).f; only promoted
+	// methods need bridges.  The identical struct types converted in
+	// main and k contribute one method set.
+	want := []string{"(*main.B).f", "(*main.D).f", "(main.A).f", "(main.C).f", "(main.D).f", "(struct{main.A}).f", "main.g", "main.k", "main.m", "main.m"}
+	if got := callees(g, "main.main"); !reflect.DeepEqual(got, want) {
+		t.Errorf("CHA: callees of main: got %v; want %v", got, want)
+	}
+	if !hasNode(g, "main.h") {
+		t.Errorf("CHA: no node for main.h")
+	}
+}
+
+func TestRTA(t *testing.T) {
+	g := callgraph.Build(build(t), callgraph.RTA)
+	want := []string{"(main.A).f", "(struct{main.A}).f", "main.g", "main.k", "main.m", "main.m"}
+	if got := callees(g, "main.main"); !reflect.DeepEqual(got, want) {
+		t.Errorf("RTA: callees of main: got %v; want %v", got, want)
+	}
+	for _, name := range []string{"main.h", "(main.C).f", "(*main.B).f"} {
+		if hasNode(g, name) {
+			t.Errorf("RTA: unreachable function %s has a node", name)
+		}
+	}
+	if len(g.Roots) != 2 {
+		t.Errorf("RTA: got %d roots; want 2 (main.init, main.main)", len(g.Roots))
+	}
+}
+
+func TestOutput(t *testing.T) {
+	g := callgraph.Build(build(t), callgraph.RTA)
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if dot := buf.String(); !strings.HasPrefix(dot, "digraph callgraph {") || !strings.Contains(dot, `"main.g"`) {
+		t.Errorf("unexpected DOT output:\n%s", dot)
+	}
+	buf.Reset()
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"func": "main.main"`) {
+		t.Errorf("unexpected JSON output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,145 @@
+package callgraph
+
+// This file implements Class Hierarchy Analysis.
+
+import (
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/ssa"
+)
+
+// A chaState holds the state of the CHA algorithm: the set of all
+// functions of the program, the method sets of all its types with
+// methods, and the set of address-taken functions.
+type chaState struct {
+	g         *Graph
+	queue     []*ssa.Function                      // functions not yet scanned
+	msets     []ssa.MethodSet                      // method sets of all types with methods
+	seenTypes typeSet                              // types whose method set is in msets
+	addrTaken []*ssa.Function                      // address-taken functions
+	seenAddr  map[*ssa.Function]bool               // set of elements of addrTaken
+	impls     map[*types.Interface][]ssa.MethodSet // cache of implementations
+}
+
+// cha builds the call graph g using Class Hierarchy Analysis.
+func cha(g *Graph) {
+	s := &chaState{
+		g:         g,
+		seenTypes: make(typeSet),
+		seenAddr:  make(map[*ssa.Function]bool),
+		impls:     make(map[*types.Interface][]ssa.MethodSet),
+	}
+
+	// Enumerate the functions and types of the program, starting
+	// from the package members; the scan finds anonymous functions,
+	// bridge methods and thunks.
+	for _, pkg := range g.Prog.Packages {
+		if pkg.Init != nil {
+			s.addFunc(pkg.Init)
+		}
+		for _, mem := range pkg.Members {
+			switch mem := mem.(type) {
+			case *ssa.Function:
+				s.addFunc(mem)
+			case *ssa.Type:
+				// Type.PtrMethods may not yet be populated, so
+				// ask the Program, which creates bridge methods.
+				ptr := types.NewPointer(mem.NamedType)
+				s.addMethods(mem.NamedType, g.Prog.MethodSet(mem.NamedType))
+				s.addMethods(ptr, g.Prog.MethodSet(ptr))
+			}
+		}
+	}
+	for len(s.queue) > 0 {
+		fn := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		s.scan(fn)
+	}
+
+	// Add the edges of all call sites.
+	for fn, n := range g.Nodes {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				c := callCommon(instr)
+				if c == nil {
+					continue
+				}
+				switch classify(c) {
+				case staticCall:
+					g.AddEdge(n, instr, c.StaticCallee())
+				case invokeCall:
+					id := c.MethodId()
+					for _, mset := range s.implementations(c.Recv.Type().Underlying().(*types.Interface)) {
+						g.AddEdge(n, instr, mset[id])
+					}
+				case dynamicCall:
+					sig := signature(c)
+					for _, fn := range s.addrTaken {
+						if types.IsIdentical(fn.Signature, sig) {
+							g.AddEdge(n, instr, fn)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// addFunc adds fn to the graph and queues it for scanning.
+func (s *chaState) addFunc(fn *ssa.Function) {
+	if _, ok := s.g.Nodes[fn]; !ok {
+		s.g.CreateNode(fn)
+		s.queue = append(s.queue, fn)
+	}
+}
+
+// addMethods records mset, the method set of type typ.
+func (s *chaState) addMethods(typ types.Type, mset ssa.MethodSet) {
+	if mset == nil || !s.seenTypes.add(typ) {
+		return
+	}
+	s.msets = append(s.msets, mset)
+	for _, fn := range mset {
+		s.addFunc(fn)
+	}
+}
+
+// scan adds the functions and types referenced by fn.
+func (s *chaState) scan(fn *ssa.Function) {
+	for _, anon := range fn.AnonFuncs {
+		s.addFunc(anon)
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if c := callCommon(instr); c != nil {
+				if callee := c.StaticCallee(); callee != nil {
+					s.addFunc(callee) // e.g. a thunk
+				}
+			}
+			if mi, ok := instr.(*ssa.MakeInterface); ok {
+				s.addMethods(mi.X.Type(), mi.Methods)
+			}
+			addressTaken(instr, func(fn *ssa.Function) {
+				s.addFunc(fn)
+				if !s.seenAddr[fn] {
+					s.seenAddr[fn] = true
+					s.addrTaken = append(s.addrTaken, fn)
+				}
+			})
+		}
+	}
+}
+
+// implementations returns the method sets of all types that
+// implement iface.
+func (s *chaState) implementations(iface *types.Interface) []ssa.MethodSet {
+	msets, ok := s.impls[iface]
+	if !ok {
+		for _, mset := range s.msets {
+			if implements(mset, iface) {
+				msets = append(msets, mset)
+			}
+		}
+		s.impls[iface] = msets
+	}
+	return msets
+}
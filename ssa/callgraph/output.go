@@ -0,0 +1,104 @@
+package callgraph
+
+// This file implements the DOT and JSON output of call graphs.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// site returns a description of the call site of e: its position,
+// or, for calls in synthetic code, the call instruction.
+func (g *Graph) site(e *Edge) string {
+	if pos := e.Common().Pos(); pos.IsValid() {
+		return g.Prog.Files.Position(pos).String()
+	}
+	return e.Site.String()
+}
+
+// position returns the position of the declaration of n's function,
+// or "" if it is synthetic.
+func (g *Graph) position(n *Node) string {
+	if pos := n.Func.Pos(); pos.IsValid() {
+		return g.Prog.Files.Position(pos).String()
+	}
+	return ""
+}
+
+// WriteDOT writes the call graph to w in the DOT language of
+// Graphviz.  Each node is labelled by the full name of its function,
+// and each edge by the position of its call site.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	nodes := g.SortedNodes()
+	ids := make(map[*Node]int)
+	for i, n := range nodes {
+		ids[n] = i
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph callgraph {")
+	for i, n := range nodes {
+		fmt.Fprintf(bw, "\tn%d [label=%s];\n", i, strconv.Quote(n.Func.FullName()))
+	}
+	for i, n := range nodes {
+		for _, e := range n.Out {
+			fmt.Fprintf(bw, "\tn%d -> n%d [label=%s];\n", i, ids[e.Callee], strconv.Quote(g.site(e)))
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// JSON encodings of nodes and edges.
+type (
+	jsonNode struct {
+		ID      int        `json:"id"`
+		Func    string     `json:"func"`
+		Pos     string     `json:"pos,omitempty"`
+		Root    bool       `json:"root,omitempty"`
+		Callees []jsonEdge `json:"callees,omitempty"`
+	}
+
+	jsonEdge struct {
+		Site   string `json:"site"`
+		Callee int    `json:"callee"` // id of the callee's node
+	}
+)
+
+// WriteJSON writes the call graph to w as a JSON array of nodes,
+// sorted by function name.  Each node has a numeric id, the full name
+// and position of its function, and the list of its outgoing edges,
+// each with the position of the call site and the id of the callee.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	nodes := g.SortedNodes()
+	ids := make(map[*Node]int)
+	for i, n := range nodes {
+		ids[n] = i
+	}
+	roots := make(map[*Node]bool)
+	for _, n := range g.Roots {
+		roots[n] = true
+	}
+
+	out := make([]jsonNode, len(nodes))
+	for i, n := range nodes {
+		out[i] = jsonNode{
+			ID:   i,
+			Func: n.Func.FullName(),
+			Pos:  g.position(n),
+			Root: roots[n],
+		}
+		for _, e := range n.Out {
+			out[i].Callees = append(out[i].Callees, jsonEdge{g.site(e), ids[e.Callee]})
+		}
+	}
+	data, err := json.MarshalIndent(out, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,155 @@
+package callgraph
+
+// This file implements Rapid Type Analysis.
+//
+// The algorithm discovers the reachable functions of the program
+// starting from its roots.  It maintains the set of runtime types
+// (the types converted to interfaces in reachable code) and the set
+// of address-taken functions (those used as values in reachable
+// code), together with the dynamic call sites of reachable code.
+// Each new element of either set is matched against the call sites
+// already found, and each new call site against both sets, so the
+// result does not depend on the order of discovery.
+
+import (
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/ssa"
+)
+
+// An invokeSite is an "invoke"-mode call site in reachable code.
+type invokeSite struct {
+	caller *Node
+	instr  ssa.Instruction
+	iface  *types.Interface
+	id     ssa.Id
+}
+
+// A dynamicSite is a call of a function value in reachable code.
+type dynamicSite struct {
+	caller *Node
+	instr  ssa.Instruction
+	sig    *types.Signature
+}
+
+type rtaState struct {
+	g            *Graph
+	queue        []*ssa.Function        // reachable functions not yet scanned
+	msets        []ssa.MethodSet        // method sets of runtime types
+	seenTypes    typeSet                // runtime types
+	addrTaken    []*ssa.Function        // address-taken functions
+	seenAddr     map[*ssa.Function]bool // set of elements of addrTaken
+	invokeSites  []invokeSite
+	dynamicSites []dynamicSite
+}
+
+// rta builds the call graph g using Rapid Type Analysis.
+func rta(g *Graph) {
+	s := &rtaState{
+		g:         g,
+		seenTypes: make(typeSet),
+		seenAddr:  make(map[*ssa.Function]bool),
+	}
+
+	// The roots are the init functions of all packages
+	// and the main function of the main package(s).
+	for _, pkg := range g.Prog.Packages {
+		if pkg.Init != nil {
+			g.Roots = append(g.Roots, s.addReachable(pkg.Init))
+		}
+		if pkg.Types.Name() == "main" {
+			if main, ok := pkg.Members["main"].(*ssa.Function); ok {
+				g.Roots = append(g.Roots, s.addReachable(main))
+			}
+		}
+	}
+	for len(s.queue) > 0 {
+		fn := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		s.scan(fn)
+	}
+}
+
+// addReachable marks fn as reachable and returns its node.
+func (s *rtaState) addReachable(fn *ssa.Function) *Node {
+	n, ok := s.g.Nodes[fn]
+	if !ok {
+		n = s.g.CreateNode(fn)
+		s.queue = append(s.queue, fn)
+	}
+	return n
+}
+
+// addEdge adds a call edge and marks the callee reachable.
+func (s *rtaState) addEdge(caller *Node, instr ssa.Instruction, callee *ssa.Function) {
+	s.addReachable(callee)
+	s.g.AddEdge(caller, instr, callee)
+}
+
+// scan processes the instructions of the reachable function fn.
+func (s *rtaState) scan(fn *ssa.Function) {
+	n := s.g.Nodes[fn]
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if c := callCommon(instr); c != nil {
+				switch classify(c) {
+				case staticCall:
+					s.addEdge(n, instr, c.StaticCallee())
+				case invokeCall:
+					s.addInvokeSite(invokeSite{n, instr, c.Recv.Type().Underlying().(*types.Interface), c.MethodId()})
+				case dynamicCall:
+					s.addDynamicSite(dynamicSite{n, instr, signature(c)})
+				}
+			}
+			if mi, ok := instr.(*ssa.MakeInterface); ok {
+				s.addRuntimeType(mi.X.Type(), mi.Methods)
+			}
+			addressTaken(instr, s.addAddrTaken)
+		}
+	}
+}
+
+func (s *rtaState) addInvokeSite(site invokeSite) {
+	s.invokeSites = append(s.invokeSites, site)
+	for _, mset := range s.msets {
+		if implements(mset, site.iface) {
+			s.addEdge(site.caller, site.instr, mset[site.id])
+		}
+	}
+}
+
+func (s *rtaState) addDynamicSite(site dynamicSite) {
+	s.dynamicSites = append(s.dynamicSites, site)
+	for _, fn := range s.addrTaken {
+		if types.IsIdentical(fn.Signature, site.sig) {
+			s.addEdge(site.caller, site.instr, fn)
+		}
+	}
+}
+
+// addRuntimeType records that values of type typ, whose method set
+// is mset, may be converted to interfaces.
+func (s *rtaState) addRuntimeType(typ types.Type, mset ssa.MethodSet) {
+	if mset == nil || !s.seenTypes.add(typ) {
+		return
+	}
+	s.msets = append(s.msets, mset)
+	for _, site := range s.invokeSites {
+		if implements(mset, site.iface) {
+			s.addEdge(site.caller, site.instr, mset[site.id])
+		}
+	}
+}
+
+// addAddrTaken records that fn is used as a value.
+func (s *rtaState) addAddrTaken(fn *ssa.Function) {
+	if s.seenAddr[fn] {
+		return
+	}
+	s.seenAddr[fn] = true
+	s.addrTaken = append(s.addrTaken, fn)
+	for _, site := range s.dynamicSites {
+		if types.IsIdentical(fn.Signature, site.sig) {
+			s.addEdge(site.caller, site.instr, fn)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package dataflow provides a solver for monotone dataflow problems
+// over the control-flow graph of a function in SSA form, and some
+// common analyses built upon it.
+//
+// A Problem defines a lattice of Facts (by its Bottom, Join and Equal
+// methods), the direction in which facts flow, and the transfer
+// function of each instruction.  Problems may optionally refine facts
+// along individual CFG edges (EdgeProblem), e.g. to exploit the
+// condition of an If instruction in each of its successors, and may
+// provide a widening operator (WideningProblem) to ensure termination
+// for lattices of infinite height.
+//
+// Solve computes the least fixed point by iterating over the blocks in
+// reverse postorder (or postorder, for backward problems), revisiting
+// only those blocks whose inputs have changed.  Widening is applied at
+// the entries of loops, through which every cycle of the CFG passes.
+//
+// The canned analyses LiveValues, LiveVariables and ReachingDefs work
+// on both lifted code and code built in ssa.NaiveForm.  In lifted
+// code most local variables have become SSA registers, so the liveness
+// of registers (LiveValues) is usually what matters; in NaiveForm,
+// variables are Alloc cells, whose liveness and definitions are
+// described by LiveVariables and ReachingDefs.
+package dataflow
+
+import (
+	"code.google.com/p/go.tools/ssa"
+)
+
+// A Fact is an element of the lattice of a Problem.
+// Facts are treated as immutable values.
+type Fact interface{}
+
+// A Direction is the direction in which facts flow through the CFG.
+type Direction int
+
+const (
+	Forward  Direction = iota // from the entry block towards the exits
+	Backward                  // from the exits towards the entry block
+)
+
+// A Problem defines a monotone dataflow problem.
+//
+// Implementations must not modify the Facts passed to their methods.
+type Problem interface {
+	// Direction returns the direction of the problem.
+	Direction() Direction
+
+	// Bottom returns the least element of the lattice, the
+	// initial fact of each block; it is the identity of Join.
+	Bottom() Fact
+
+	// Boundary returns the fact at the start of the entry block
+	// (Forward) or at the end of each block without successors
+	// (Backward).
+	Boundary() Fact
+
+	// Join returns the least upper bound of x and y.
+	Join(x, y Fact) Fact
+
+	// Equal reports whether x and y are the same element.
+	Equal(x, y Fact) bool
+
+	// Transfer returns the fact after instr (Forward) or before
+	// it (Backward), given the fact on its other side.
+	Transfer(instr ssa.Instruction, fact Fact) Fact
+}
+
+// An EdgeProblem is a Problem that refines the facts flowing along
+// each edge of the CFG.
+type EdgeProblem interface {
+	Problem
+
+	// EdgeTransfer returns the fact that flows along the edge
+	// from→to, given the fact at the end of from (Forward) or at
+	// the start of to (Backward).
+	EdgeTransfer(from, to *ssa.BasicBlock, fact Fact) Fact
+}
+
+// A WideningProblem is a Problem whose lattice may have infinite
+// ascending chains.
+type WideningProblem interface {
+	Problem
+
+	// Widen returns an upper bound of old and new, such that
+	// any sequence x, Widen(x, y1), Widen(Widen(x, y1), y2), ...
+	// eventually stabilizes.
+	Widen(old, new Fact) Fact
+}
+
+// IfEdge reports whether the edge from→to is one of the two edges of
+// the If instruction ending block from, and if so, the If's condition
+// and the value it has when control follows the edge.
+func IfEdge(from, to *ssa.BasicBlock) (cond ssa.Value, value bool, ok bool) {
+	if len(from.Instrs) == 0 {
+		return nil, false, false
+	}
+	if instr, isIf := from.Instrs[len(from.Instrs)-1].(*ssa.If); isIf {
+		switch to {
+		case from.Succs[0]:
+			return instr.Cond, true, true
+		case from.Succs[1]:
+			return instr.Cond, false, true
+		}
+	}
+	return nil, false, false
+}
+
+// A Result holds the solution of a Problem for a function.
+type Result struct {
+	Func *ssa.Function
+	In   []Fact // fact at the start of each block, by Block.Index
+	Out  []Fact // fact at the end of each block, by Block.Index
+
+	p Problem
+}
+
+// Before returns the fact immediately before instr.
+func (r *Result) Before(instr ssa.Instruction) Fact {
+	facts, i := r.blockFacts(instr)
+	return facts[i]
+}
+
+// After returns the fact immediately after instr.
+func (r *Result) After(instr ssa.Instruction) Fact {
+	facts, i := r.blockFacts(instr)
+	return facts[i+1]
+}
+
+// blockFacts returns the facts at each point of the block of instr,
+// such that facts[i] and facts[i+1] are the facts before and after
+// b.Instrs[i], and the index i of instr.
+func (r *Result) blockFacts(instr ssa.Instruction) ([]Fact, int) {
+	b := instr.Block()
+	index := -1
+	for i, x := range b.Instrs {
+		if x == instr {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		panic("instruction not in its block: " + instr.String())
+	}
+	facts := make([]Fact, len(b.Instrs)+1)
+	if r.p.Direction() == Forward {
+		facts[0] = r.In[b.Index]
+		for i, instr := range b.Instrs {
+			facts[i+1] = r.p.Transfer(instr, facts[i])
+		}
+	} else {
+		facts[len(b.Instrs)] = r.Out[b.Index]
+		for i := len(b.Instrs) - 1; i >= 0; i-- {
+			facts[i] = r.p.Transfer(b.Instrs[i], facts[i+1])
+		}
+	}
+	return facts, index
+}
+
+// Solve computes the least solution of problem p for fn, which must
+// have a body.  It terminates if the lattice has no infinite ascending
+// chains, or if p is a WideningProblem.
+func Solve(fn *ssa.Function, p Problem) *Result {
+	n := len(fn.Blocks)
+	r := &Result{
+		Func: fn,
+		In:   make([]Fact, n),
+		Out:  make([]Fact, n),
+		p:    p,
+	}
+	for i := range fn.Blocks {
+		r.In[i] = p.Bottom()
+		r.Out[i] = p.Bottom()
+	}
+
+	ep, _ := p.(EdgeProblem)
+	wp, _ := p.(WideningProblem)
+	var widen []bool // widening points, by Block.Index
+	if wp != nil {
+		widen = make([]bool, n)
+		var mark func(loops []*ssa.Loop)
+		mark = func(loops []*ssa.Loop) {
+			for _, l := range loops {
+				for _, entry := range l.Entries {
+					widen[entry.Index] = true
+				}
+				mark(l.Children)
+			}
+		}
+		mark(fn.LoopForest().Roots)
+	}
+
+	edge := func(from, to *ssa.BasicBlock, fact Fact) Fact {
+		if ep != nil {
+			return ep.EdgeTransfer(from, to, fact)
+		}
+		return fact
+	}
+
+	forward := p.Direction() == Forward
+	order := postorder(fn)
+	if forward {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	dirty := make([]bool, n)
+	for i := range dirty {
+		dirty[i] = true
+	}
+	for progress := true; progress; {
+		progress = false
+		for _, b := range order {
+			i := b.Index
+			if !dirty[i] {
+				continue
+			}
+			dirty[i] = false
+			progress = true
+
+			if forward {
+				in := p.Bottom()
+				if i == 0 {
+					in = p.Boundary()
+				}
+				for _, pred := range b.Preds {
+					in = p.Join(in, edge(pred, b, r.Out[pred.Index]))
+				}
+				if widen != nil && widen[i] {
+					in = wp.Widen(r.In[i], in)
+				}
+				r.In[i] = in
+				out := in
+				for _, instr := range b.Instrs {
+					out = p.Transfer(instr, out)
+				}
+				if !p.Equal(out, r.Out[i]) {
+					r.Out[i] = out
+					for _, succ := range b.Succs {
+						dirty[succ.Index] = true
+					}
+				}
+			} else {
+				out := p.Bottom()
+				if len(b.Succs) == 0 {
+					out = p.Boundary()
+				}
+				for _, succ := range b.Succs {
+					out = p.Join(out, edge(b, succ, r.In[succ.Index]))
+				}
+				if widen != nil && widen[i] {
+					out = wp.Widen(r.Out[i], out)
+				}
+				r.Out[i] = out
+				in := out
+				for j := len(b.Instrs) - 1; j >= 0; j-- {
+					in = p.Transfer(b.Instrs[j], in)
+				}
+				if !p.Equal(in, r.In[i]) {
+					r.In[i] = in
+					for _, pred := range b.Preds {
+						dirty[pred.Index] = true
+					}
+				}
+			}
+		}
+	}
+	return r
+}
+
+// postorder returns the blocks of fn in postorder of a depth-first
+// traversal of the CFG from the entry block.  Blocks unreachable from
+// the entry, if any, are appended at the end.
+func postorder(fn *ssa.Function) []*ssa.BasicBlock {
+	order := make([]*ssa.BasicBlock, 0, len(fn.Blocks))
+	seen := make([]bool, len(fn.Blocks))
+	var visit func(b *ssa.BasicBlock)
+	visit = func(b *ssa.BasicBlock) {
+		seen[b.Index] = true
+		for _, succ := range b.Succs {
+			if !seen[succ.Index] {
+				visit(succ)
+			}
+		}
+		order = append(order, b)
+	}
+	visit(fn.Blocks[0])
+	for _, b := range fn.Blocks {
+		if !seen[b.Index] {
+			visit(b)
+		}
+	}
+	return order
+}
@@ -0,0 +1,150 @@
+package dataflow_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/dataflow"
+)
+
+const src = `package main
+
+func f(c bool) int {
+	x := 1
+	if c {
+		x = 2
+	}
+	return x
+}
+
+func g(a, b int) int {
+	s := a
+	if a > 0 {
+		s = b
+	}
+	return s
+}
+
+func main() {
+	f(true)
+	g(1, 2)
+}
+`
+
+func build(t *testing.T, mode ssa.BuilderMode) *ssa.Package {
+	b := ssa.NewBuilder(&ssa.Context{Mode: mode, Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", src, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pkg
+}
+
+// block returns the block of fn with the specified comment.
+func block(t *testing.T, fn *ssa.Function, comment string) *ssa.BasicBlock {
+	for _, b := range fn.Blocks {
+		if b.Comment == comment {
+			return b
+		}
+	}
+	t.Fatalf("%s: no block %q", fn, comment)
+	return nil
+}
+
+// variable returns the Alloc of the named local variable of fn, and
+// its load.
+func variable(t *testing.T, fn *ssa.Function, name string) (*ssa.Alloc, *ssa.UnOp) {
+	var alloc *ssa.Alloc
+	for _, l := range fn.Locals {
+		if l.Name() == name {
+			alloc = l
+		}
+	}
+	if alloc == nil {
+		t.Fatalf("%s: no variable %s", fn, name)
+	}
+	for _, ref := range *alloc.Referrers() {
+		if load, ok := ref.(*ssa.UnOp); ok && load.Op == token.MUL {
+			return alloc, load
+		}
+	}
+	t.Fatalf("%s: no load of %s", fn, name)
+	return nil, nil
+}
+
+func TestReachingDefs(t *testing.T) {
+	fn := build(t, ssa.NaiveForm).Func("f")
+	_, load := variable(t, fn, "x")
+
+	// The zero definition by the Alloc is killed by x := 1.
+	defs := dataflow.Defs(dataflow.ReachingDefs(fn), load)
+	if len(defs) != 2 {
+		t.Fatalf("Defs(%s) = %v, want two stores", load.Name(), defs)
+	}
+	for _, def := range defs {
+		if _, ok := def.(*ssa.Store); !ok {
+			t.Errorf("Defs(%s) includes %s, want only stores", load.Name(), def)
+		}
+	}
+}
+
+func TestLiveVariables(t *testing.T) {
+	fn := build(t, ssa.NaiveForm).Func("f")
+	alloc, _ := variable(t, fn, "x")
+	r := dataflow.LiveVariables(fn)
+
+	// x is live after the If, since it may be loaded without
+	// being stored, but not at the start of the then-block.
+	if !r.Out[0].(dataflow.ValueSet)[alloc] {
+		t.Errorf("x not live at end of entry block")
+	}
+	if then := block(t, fn, "if.then"); r.In[then.Index].(dataflow.ValueSet)[alloc] {
+		t.Errorf("x live at start of %s", then)
+	}
+	if r.In[0].(dataflow.ValueSet)[alloc] {
+		t.Errorf("x live at start of entry block")
+	}
+}
+
+func TestLiveValues(t *testing.T) {
+	fn := build(t, 0).Func("g")
+	a, b := fn.Params[0], fn.Params[1]
+	then := block(t, fn, "if.then")
+	done := block(t, fn, "if.done")
+	r := dataflow.LiveValues(fn)
+
+	if in := r.In[0].(dataflow.ValueSet); !in[a] || !in[b] {
+		t.Errorf("params not live at entry: %v", in)
+	}
+	// a flows only into the φ-node along the edge from the entry block.
+	if in := r.In[then.Index].(dataflow.ValueSet); in[a] || !in[b] {
+		t.Errorf("live at start of %s: %v, want only b", then, in)
+	}
+	if in := r.In[done.Index].(dataflow.ValueSet); in[a] || in[b] {
+		t.Errorf("live at start of %s: %v, want neither a nor b", done, in)
+	}
+}
+
+func TestIfEdge(t *testing.T) {
+	fn := build(t, 0).Func("g")
+	entry := fn.Blocks[0]
+	then := block(t, fn, "if.then")
+	done := block(t, fn, "if.done")
+	if cond, value, ok := dataflow.IfEdge(entry, then); !ok || !value || cond == nil {
+		t.Errorf("IfEdge(%s, %s) = %v, %t, %t", entry, then, cond, value, ok)
+	}
+	if _, value, ok := dataflow.IfEdge(entry, done); !ok || value {
+		t.Errorf("IfEdge(%s, %s) = _, %t, %t", entry, done, value, ok)
+	}
+	if _, _, ok := dataflow.IfEdge(then, done); ok {
+		t.Errorf("IfEdge(%s, %s) reported an If edge", then, done)
+	}
+}
@@ -0,0 +1,113 @@
+package dataflow
+
+// This file defines the liveness analyses.
+
+import (
+	"go/token"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+// LiveValues computes the SSA registers of fn (its Parameters and
+// Captures, and the values of its instructions) that are live at each
+// point of fn: those whose value may be used later.  Each Fact of the
+// result is a ValueSet.
+//
+// The operands of a Phi are live at the end of the corresponding
+// predecessor block, not at the start of the Phi's block.
+func LiveValues(fn *ssa.Function) *Result {
+	return Solve(fn, liveValues{})
+}
+
+type liveValues struct{}
+
+func (liveValues) Direction() Direction { return Backward }
+func (liveValues) Bottom() Fact         { return ValueSet{} }
+func (liveValues) Boundary() Fact       { return ValueSet{} }
+func (liveValues) Join(x, y Fact) Fact  { return x.(ValueSet).join(y.(ValueSet)) }
+func (liveValues) Equal(x, y Fact) bool { return x.(ValueSet).equal(y.(ValueSet)) }
+
+func (liveValues) Transfer(instr ssa.Instruction, fact Fact) Fact {
+	live := fact.(ValueSet).copy()
+	if v, ok := instr.(ssa.Value); ok {
+		delete(live, v)
+	}
+	if _, ok := instr.(*ssa.Phi); !ok {
+		for _, op := range instr.Operands(nil) {
+			if isRegister(*op) {
+				live[*op] = true
+			}
+		}
+	}
+	return live
+}
+
+func (liveValues) EdgeTransfer(from, to *ssa.BasicBlock, fact Fact) Fact {
+	live := fact.(ValueSet)
+	copied := false
+	k := -1
+	for i, pred := range to.Preds {
+		if pred == from {
+			k = i
+		}
+	}
+	for _, instr := range to.Instrs {
+		phi, ok := instr.(*ssa.Phi)
+		if !ok {
+			break // φ-nodes come first
+		}
+		if v := phi.Edges[k]; isRegister(v) && !live[v] {
+			if !copied {
+				live = live.copy()
+				copied = true
+			}
+			live[v] = true
+		}
+	}
+	return live
+}
+
+// isRegister reports whether v is an SSA register local to a function.
+func isRegister(v ssa.Value) bool {
+	switch v.(type) {
+	case *ssa.Parameter, *ssa.Capture:
+		return true
+	case ssa.Instruction:
+		return true
+	}
+	return false
+}
+
+// LiveVariables computes the variables of fn that are live at each
+// point of fn: those whose value may be loaded before it is next
+// stored.  The variables are the Alloc cells whose only uses are loads
+// and stores; an Alloc instruction itself defines its cell as zero.
+// Each Fact of the result is a ValueSet of *ssa.Alloc.
+func LiveVariables(fn *ssa.Function) *Result {
+	return Solve(fn, &liveVariables{vars: variables(fn)})
+}
+
+type liveVariables struct {
+	vars map[*ssa.Alloc]bool
+}
+
+func (*liveVariables) Direction() Direction { return Backward }
+func (*liveVariables) Bottom() Fact         { return ValueSet{} }
+func (*liveVariables) Boundary() Fact       { return ValueSet{} }
+func (*liveVariables) Join(x, y Fact) Fact  { return x.(ValueSet).join(y.(ValueSet)) }
+func (*liveVariables) Equal(x, y Fact) bool { return x.(ValueSet).equal(y.(ValueSet)) }
+
+func (p *liveVariables) Transfer(instr ssa.Instruction, fact Fact) Fact {
+	live := fact.(ValueSet)
+	if v := definedVar(p.vars, instr); v != nil && live[v] {
+		live = live.copy()
+		delete(live, v)
+	}
+	if load, ok := instr.(*ssa.UnOp); ok && load.Op == token.MUL {
+		if v, ok := load.X.(*ssa.Alloc); ok && p.vars[v] && !live[v] {
+			live = live.copy()
+			live[v] = true
+		}
+	}
+	return live
+}
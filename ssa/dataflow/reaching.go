@@ -0,0 +1,62 @@
+package dataflow
+
+// This file defines the reaching definitions analysis.
+
+import (
+	"code.google.com/p/go.tools/ssa"
+)
+
+// ReachingDefs computes the definitions of the variables of fn that
+// reach each point of fn: those that may be the last definition of
+// their variable on some path to that point.  The variables are the
+// Alloc cells whose only uses are loads and stores; their definitions
+// are the Stores to them and the Alloc instructions themselves, which
+// define them as zero.  Each Fact of the result is an InstrSet.
+//
+// In lifted code such variables have become SSA registers, whose
+// single definition trivially reaches all their uses; the analysis is
+// mostly useful for code built in ssa.NaiveForm.
+func ReachingDefs(fn *ssa.Function) *Result {
+	return Solve(fn, &reachingDefs{vars: variables(fn)})
+}
+
+// Defs returns the definitions of the variable loaded by load, an
+// *ssa.UnOp of a variable, that reach it, ordered by block and
+// instruction index.  r must be the result of ReachingDefs.
+func Defs(r *Result, load *ssa.UnOp) []ssa.Instruction {
+	vars := r.p.(*reachingDefs).vars
+	reaching := r.Before(load).(InstrSet)
+	var defs []ssa.Instruction
+	for _, b := range r.Func.Blocks {
+		for _, instr := range b.Instrs {
+			if reaching[instr] && definedVar(vars, instr) == load.X {
+				defs = append(defs, instr)
+			}
+		}
+	}
+	return defs
+}
+
+type reachingDefs struct {
+	vars map[*ssa.Alloc]bool
+}
+
+func (*reachingDefs) Direction() Direction { return Forward }
+func (*reachingDefs) Bottom() Fact         { return InstrSet{} }
+func (*reachingDefs) Boundary() Fact       { return InstrSet{} }
+func (*reachingDefs) Join(x, y Fact) Fact  { return x.(InstrSet).join(y.(InstrSet)) }
+func (*reachingDefs) Equal(x, y Fact) bool { return x.(InstrSet).equal(y.(InstrSet)) }
+
+func (p *reachingDefs) Transfer(instr ssa.Instruction, fact Fact) Fact {
+	v := definedVar(p.vars, instr)
+	if v == nil {
+		return fact
+	}
+	defs := InstrSet{instr: true}
+	for def := range fact.(InstrSet) {
+		if definedVar(p.vars, def) != v {
+			defs[def] = true
+		}
+	}
+	return defs
+}
@@ -0,0 +1,138 @@
+package dataflow
+
+// This file defines the set types used as the Facts of the canned
+// analyses, and their lattice operations.
+
+import (
+	"go/token"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+// A ValueSet is a set of SSA values.
+type ValueSet map[ssa.Value]bool
+
+func (s ValueSet) copy() ValueSet {
+	t := make(ValueSet, len(s))
+	for v := range s {
+		t[v] = true
+	}
+	return t
+}
+
+// join returns the union of s and t, which is s itself if t ⊆ s.
+func (s ValueSet) join(t ValueSet) ValueSet {
+	for v := range t {
+		if !s[v] {
+			u := s.copy()
+			for v := range t {
+				u[v] = true
+			}
+			return u
+		}
+	}
+	return s
+}
+
+func (s ValueSet) equal(t ValueSet) bool {
+	if len(s) != len(t) {
+		return false
+	}
+	for v := range s {
+		if !t[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// An InstrSet is a set of SSA instructions.
+type InstrSet map[ssa.Instruction]bool
+
+func (s InstrSet) copy() InstrSet {
+	t := make(InstrSet, len(s))
+	for instr := range s {
+		t[instr] = true
+	}
+	return t
+}
+
+// join returns the union of s and t, which is s itself if t ⊆ s.
+func (s InstrSet) join(t InstrSet) InstrSet {
+	for instr := range t {
+		if !s[instr] {
+			u := s.copy()
+			for instr := range t {
+				u[instr] = true
+			}
+			return u
+		}
+	}
+	return s
+}
+
+func (s InstrSet) equal(t InstrSet) bool {
+	if len(s) != len(t) {
+		return false
+	}
+	for instr := range s {
+		if !t[instr] {
+			return false
+		}
+	}
+	return true
+}
+
+// Variables ----------------------------------------
+
+// variables returns the set of Alloc cells of fn whose only uses are
+// loads and stores (and DebugRefs), i.e. whose address does not
+// escape.  These are the "variables" of LiveVariables and ReachingDefs;
+// as in the lifting pass of package ssa, no other instruction can
+// access them.
+func variables(fn *ssa.Function) map[*ssa.Alloc]bool {
+	vars := make(map[*ssa.Alloc]bool)
+	for _, b := range fn.Blocks {
+	instrs:
+		for _, instr := range b.Instrs {
+			alloc, ok := instr.(*ssa.Alloc)
+			if !ok {
+				continue
+			}
+			for _, ref := range *alloc.Referrers() {
+				switch ref := ref.(type) {
+				case *ssa.Store:
+					if ref.Val == alloc {
+						continue instrs // address used as value
+					}
+				case *ssa.UnOp:
+					if ref.Op != token.MUL {
+						continue instrs // not a load
+					}
+				case *ssa.DebugRef:
+					// no effect
+				default:
+					continue instrs // some other instruction
+				}
+			}
+			vars[alloc] = true
+		}
+	}
+	return vars
+}
+
+// definedVar returns the variable defined by instr (its zeroing
+// Alloc, or a Store to it), or nil.
+func definedVar(vars map[*ssa.Alloc]bool, instr ssa.Instruction) *ssa.Alloc {
+	switch instr := instr.(type) {
+	case *ssa.Alloc:
+		if vars[instr] {
+			return instr
+		}
+	case *ssa.Store:
+		if alloc, ok := instr.Addr.(*ssa.Alloc); ok && vars[alloc] {
+			return alloc
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package ssa
+
+// This file defines the dead code elimination pass, enabled by the
+// DeadCodeElimination builder mode.
+//
+// The pass deletes each instruction that defines a value with no
+// referrers, provided that executing it has no effect other than
+// computing that value, i.e. it cannot panic, block, or modify
+// memory.  Deleting an instruction may make its operands dead too.
+
+import (
+	"go/token"
+
+	"code.google.com/p/go.tools/go/exact"
+	"code.google.com/p/go.tools/go/types"
+)
+
+// isPure reports whether instr, an instruction that defines a value,
+// may be deleted if its value is unused.
+func isPure(instr Instruction) bool {
+	switch instr := instr.(type) {
+	case *Alloc, *Phi, *MakeClosure, *MakeInterface,
+		*ChangeType, *ChangeInterface, *Convert, *Field, *Extract:
+		return true
+
+	case *MakeMap:
+		return instr.Reserve == nil // a negative size panics
+
+	case *BinOp:
+		if instr.Op == token.QUO || instr.Op == token.REM {
+			// Integer division by zero panics.
+			if t, ok := instr.Type().Underlying().(*types.Basic); ok && t.Info()&types.IsInteger != 0 {
+				lit, ok := instr.Y.(*Literal)
+				return ok && exact.Sign(lit.Value) != 0
+			}
+		}
+		if instr.Op == token.EQL || instr.Op == token.NEQ {
+			// Comparison of uncomparable dynamic types panics.
+			return !hasInterface(instr.X.Type()) && !hasInterface(instr.Y.Type())
+		}
+		return true
+
+	case *UnOp:
+		switch instr.Op {
+		case token.ARROW:
+			return false // receive blocks
+		case token.MUL:
+			return isNonNilAddr(instr.X) // load of nil pointer panics
+		}
+		return true
+
+	case *FieldAddr:
+		return isNonNilAddr(instr.X)
+
+	case *Index:
+		// The type checker has already checked constant
+		// indices of arrays.
+		_, ok := instr.Index.(*Literal)
+		return ok
+
+	case *Lookup:
+		// Map lookups cannot fail unless the dynamic type of
+		// an interface key is unhashable; string indexing can.
+		m, ok := instr.X.Type().Underlying().(*types.Map)
+		return ok && !hasInterface(m.Key())
+
+	case *TypeAssert:
+		return instr.CommaOk
+	}
+	return false
+}
+
+// hasInterface reports whether values of type t may contain interface
+// values, whose dynamic types may be uncomparable.
+func hasInterface(t types.Type) bool {
+	switch t := t.Underlying().(type) {
+	case *types.Interface:
+		return true
+	case *types.Array:
+		return hasInterface(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasInterface(t.Field(i).Type()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNonNilAddr reports whether v is an address that cannot be nil.
+func isNonNilAddr(v Value) bool {
+	switch v.(type) {
+	case *Alloc, *Global, *FieldAddr, *IndexAddr:
+		return true
+	}
+	return false
+}
+
+// eliminateDeadCode deletes the unused pure instructions of f, whose
+// Referrers must be up-to-date.  It reports whether any were deleted.
+func eliminateDeadCode(f *Function) bool {
+	var work []Instruction
+	for _, b := range f.Blocks {
+		work = append(work, b.Instrs...)
+	}
+	dead := make(map[Instruction]bool)
+	for len(work) > 0 {
+		instr := work[len(work)-1]
+		work = work[:len(work)-1]
+		v, ok := instr.(Value)
+		if !ok || dead[instr] || !isPure(instr) || len(*v.Referrers()) > 0 {
+			continue
+		}
+		dead[instr] = true
+		// Its operands may now be dead.
+		for _, rand := range instr.Operands(nil) {
+			if op, ok := (*rand).(Instruction); ok {
+				work = append(work, op)
+			}
+		}
+		dropReferrers(instr)
+	}
+	if len(dead) == 0 {
+		return false
+	}
+
+	for _, b := range f.Blocks {
+		for i, instr := range b.Instrs {
+			if dead[instr] {
+				b.Instrs[i] = nil
+				b.gaps++
+			}
+		}
+		compactInstrs(b)
+	}
+
+	// Remove any f.Locals that were deleted.
+	j := 0
+	for _, l := range f.Locals {
+		if !dead[l] {
+			f.Locals[j] = l
+			j++
+		}
+	}
+	// Nil out f.Locals[j:] to aid GC.
+	for i := j; i < len(f.Locals); i++ {
+		f.Locals[i] = nil
+	}
+	f.Locals = f.Locals[:j]
+	return true
+}
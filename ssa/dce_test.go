@@ -0,0 +1,48 @@
+package ssa_test
+
+import (
+	"go/token"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+func TestDeadCodeElimination(t *testing.T) {
+	isAccess := func(instr ssa.Instruction) bool {
+		switch instr.(type) {
+		case *ssa.Field, *ssa.FieldAddr, *ssa.Index, *ssa.IndexAddr:
+			return true
+		}
+		return false
+	}
+
+	fn := buildOpt(t, 0).Func("g")
+	if countInstrs(fn, isAccess) == 0 {
+		t.Errorf("%s: without DeadCodeElimination, unused accesses should remain", fn)
+	}
+
+	fn = buildOpt(t, ssa.DeadCodeElimination).Func("g")
+	if n := countInstrs(fn, isAccess); n != 0 {
+		t.Errorf("%s: %d unused accesses remain", fn, n)
+	}
+	// Only the division that may panic remains.
+	if n := countInstrs(fn, isBinOp(token.QUO)); n != 1 {
+		t.Errorf("%s: got %d divisions, want 1", fn, n)
+	}
+}
+
+func TestDeadCodeEliminationInterfaces(t *testing.T) {
+	fn := buildOpt(t, ssa.DeadCodeElimination).Func("l")
+	isLookup := func(instr ssa.Instruction) bool {
+		_, ok := instr.(*ssa.Lookup)
+		return ok
+	}
+	// Only the lookup with an interface key remains.
+	if n := countInstrs(fn, isLookup); n != 1 {
+		t.Errorf("%s: got %d lookups, want 1", fn, n)
+	}
+	// Only the comparisons involving interfaces remain.
+	if n := countInstrs(fn, isBinOp(token.EQL)) + countInstrs(fn, isBinOp(token.NEQ)); n != 2 {
+		t.Errorf("%s: got %d comparisons, want 2", fn, n)
+	}
+}
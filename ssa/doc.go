@@ -59,6 +59,7 @@
 //   *ChangeType        ✔               ✔
 //   *Constant                                          ✔ (const)
 //   *Convert           ✔               ✔
+//   *DebugRef                          ✔
 //   *Defer                             ✔
 //   *Extract           ✔               ✔
 //   *Field             ✔               ✔
@@ -20,9 +20,75 @@ import (
 	"os"
 )
 
+// Dominance information is exposed through the Idom, Dominees and
+// Dominates methods of BasicBlock, and Function.DomPreorder and
+// Function.DomFrontier.  It is computed by the builder for every
+// function with a body, and remains valid only as long as the CFG is
+// not modified.
+
+// Idom returns the block that immediately dominates b: its parent in
+// the dominator tree, if any.  The entry block (b.Index == 0) has no
+// parent.
+func (b *BasicBlock) Idom() *BasicBlock {
+	if b.dom.Idom == nil {
+		return nil
+	}
+	return b.dom.Idom.Block
+}
+
+// Dominees returns a new slice containing the blocks that b
+// immediately dominates: its children in the dominator tree.
+func (b *BasicBlock) Dominees() []*BasicBlock {
+	var children []*BasicBlock
+	for _, child := range b.dom.Children {
+		children = append(children, child.Block)
+	}
+	return children
+}
+
+// Dominates reports whether b dominates c.  Every block dominates
+// itself.
+func (b *BasicBlock) Dominates(c *BasicBlock) bool {
+	return b.dom.Pre <= c.dom.Pre && c.dom.Post <= b.dom.Post
+}
+
+// DomPreorder returns a new slice containing the blocks of f in
+// dominator tree preorder: each block appears before all the blocks
+// it dominates.
+func (f *Function) DomPreorder() []*BasicBlock {
+	order := make([]*BasicBlock, len(f.Blocks))
+	for _, b := range f.Blocks {
+		order[b.dom.Pre] = b
+	}
+	return order
+}
+
+// DomFrontier returns the dominance frontier of each block of f,
+// indexed by Block.Index.  The dominance frontier of block b is the
+// set of blocks c such that b dominates a predecessor of c but does
+// not strictly dominate c.  Each set is free of duplicates.
+func (f *Function) DomFrontier() [][]*BasicBlock {
+	// This is the algorithm of Cooper, Harvey and Kennedy (see lift.go).
+	df := make([][]*BasicBlock, len(f.Blocks))
+	for _, b := range f.Blocks {
+		if len(b.Preds) < 2 {
+			continue
+		}
+		idom := b.Idom()
+		for _, pred := range b.Preds {
+			for runner := pred; runner != idom; runner = runner.Idom() {
+				p := &df[runner.Index]
+				if len(*p) > 0 && (*p)[len(*p)-1] == b {
+					break // already visited from another predecessor
+				}
+				*p = append(*p, b)
+			}
+		}
+	}
+	return df
+}
+
 // domNode represents a node in the dominator tree.
-//
-// TODO(adonovan): export this, when ready.
 type domNode struct {
 	Block     *BasicBlock // the basic block; n.Block.dom == n
 	Idom      *domNode    // immediate dominator (parent in dominator tree)
@@ -85,7 +151,10 @@ func buildDomTree(f *Function) {
 			dom = &domNode{Block: b}
 			b.dom = dom
 		} else {
-			dom.Block = b // reuse
+			// reuse
+			dom.Block = b
+			dom.Idom = nil
+			dom.Children = dom.Children[:0]
 		}
 	}
 
@@ -158,7 +227,7 @@ func buildDomTree(f *Function) {
 
 	numberDomTree(root, 0, 0, 0)
 
-	// printDomTreeDot(os.Stderr, f)        // debugging
+	// f.WriteDomTreeDot(os.Stderr)         // debugging
 	// printDomTreeText(os.Stderr, root, 0) // debugging
 
 	if f.Prog.mode&SanityCheckFunctions != 0 {
@@ -184,11 +253,203 @@ func numberDomTree(v *domNode, pre, post, level int) (int, int) {
 	return pre, post
 }
 
-// dominates returns true if b dominates c.
-// Requires that dominance information is up-to-date.
+// Post-dominator tree construction ----------------------------------------
+
+// A PostDomTree is the post-dominator tree of a function.
 //
-func dominates(b, c *BasicBlock) bool {
-	return b.dom.Pre <= c.dom.Pre && c.dom.Post <= b.dom.Post
+// Block b post-dominates block c if every path from c to an exit of
+// the function passes through b.  The exits are the blocks without
+// successors, e.g. those ending in a Ret or Panic instruction.  The
+// tree is rooted at a virtual exit node, whose children are the roots
+// returned by Roots.  Blocks from which no exit is reachable, such as
+// those of an infinite loop, are not in the tree.
+//
+// Unlike the dominator tree, a PostDomTree is not maintained by the
+// builder: it is a snapshot of the CFG at the time it was computed.
+type PostDomTree struct {
+	fn       *Function
+	idom     []int           // immediate post-dominator, by Block.Index; exit or -1
+	children [][]*BasicBlock // immediate post-dominees, by Block.Index; exit is last
+	pre      []int           // preorder number within tree, by Block.Index
+	post     []int           // postorder number within tree, by Block.Index
+}
+
+// PostDomTree computes the post-dominator tree of f, which must have a
+// body.
+//
+// We use the iterative algorithm of Cooper, Harvey and Kennedy (see
+// lift.go) on the reverse CFG, which is simpler than the LT algorithm
+// in the presence of multiple exits and unreachable nodes.
+func (f *Function) PostDomTree() *PostDomTree {
+	n := len(f.Blocks)
+	exit := n // the virtual exit node
+
+	// Number the nodes by postorder of a DFS of the reverse CFG.
+	order := make([]int, n+1) // postorder number of each node
+	var postorder []int
+	seen := make([]bool, n+1)
+	var visit func(v int)
+	visit = func(v int) {
+		seen[v] = true
+		if v == exit {
+			for _, b := range f.Blocks {
+				if len(b.Succs) == 0 && !seen[b.Index] {
+					visit(b.Index)
+				}
+			}
+		} else {
+			for _, pred := range f.Blocks[v].Preds {
+				if !seen[pred.Index] {
+					visit(pred.Index)
+				}
+			}
+		}
+		order[v] = len(postorder)
+		postorder = append(postorder, v)
+	}
+	visit(exit)
+
+	idom := make([]int, n+1)
+	for i := range idom {
+		idom[i] = -1
+	}
+	idom[exit] = exit
+	intersect := func(x, y int) int {
+		for x != y {
+			for order[x] < order[y] {
+				x = idom[x]
+			}
+			for order[y] < order[x] {
+				y = idom[y]
+			}
+		}
+		return x
+	}
+
+	// Iterate in reverse postorder until fixed point.  The
+	// predecessors of a block in the reverse CFG are its
+	// successors, and the exit node if it has none.
+	for changed := true; changed; {
+		changed = false
+		for i := len(postorder) - 2; i >= 0; i-- { // skip exit
+			v := postorder[i]
+			b := f.Blocks[v]
+			ipdom := -1
+			if len(b.Succs) == 0 {
+				ipdom = exit
+			}
+			for _, succ := range b.Succs {
+				if s := succ.Index; idom[s] >= 0 {
+					if ipdom < 0 {
+						ipdom = s
+					} else {
+						ipdom = intersect(s, ipdom)
+					}
+				}
+			}
+			if idom[v] != ipdom {
+				idom[v] = ipdom
+				changed = true
+			}
+		}
+	}
+
+	t := &PostDomTree{
+		fn:       f,
+		idom:     idom[:n],
+		children: make([][]*BasicBlock, n+1),
+		pre:      make([]int, n),
+		post:     make([]int, n),
+	}
+	for _, b := range f.Blocks {
+		if p := idom[b.Index]; p >= 0 {
+			t.children[p] = append(t.children[p], b)
+		}
+	}
+	for i := range t.pre {
+		t.pre[i] = -1
+		t.post[i] = -1
+	}
+	pre, post := 0, 0
+	var number func(v int)
+	number = func(v int) {
+		t.pre[v] = pre
+		pre++
+		for _, child := range t.children[v] {
+			number(child.Index)
+		}
+		t.post[v] = post
+		post++
+	}
+	for _, root := range t.Roots() {
+		number(root.Index)
+	}
+	return t
+}
+
+// Roots returns the blocks immediately post-dominated by the virtual
+// exit node: the exit blocks of the function, and any other blocks
+// not post-dominated by a single block, e.g. one that branches to two
+// different exits.
+func (t *PostDomTree) Roots() []*BasicBlock {
+	return t.children[len(t.fn.Blocks)]
+}
+
+// Idom returns the block that immediately post-dominates b: its
+// parent in the tree.  The result is nil if b is a root or is not in
+// the tree.
+func (t *PostDomTree) Idom(b *BasicBlock) *BasicBlock {
+	if p := t.idom[b.Index]; p >= 0 && p < len(t.fn.Blocks) {
+		return t.fn.Blocks[p]
+	}
+	return nil
+}
+
+// Dominees returns the blocks that b immediately post-dominates: its
+// children in the tree.  The result must not be modified.
+func (t *PostDomTree) Dominees(b *BasicBlock) []*BasicBlock {
+	return t.children[b.Index]
+}
+
+// Dominates reports whether b post-dominates c.  Every block in the
+// tree post-dominates itself; a block not in the tree neither
+// post-dominates nor is post-dominated by any block.
+func (t *PostDomTree) Dominates(b, c *BasicBlock) bool {
+	if t.pre[b.Index] < 0 || t.pre[c.Index] < 0 {
+		return false
+	}
+	return t.pre[b.Index] <= t.pre[c.Index] && t.post[c.Index] <= t.post[b.Index]
+}
+
+// Frontier returns the post-dominance frontier of each block in the
+// tree, indexed by Block.Index.  The post-dominance frontier of block b
+// is the set of blocks c such that b post-dominates a successor of c
+// but does not strictly post-dominate c; in other words, the blocks
+// whose branch decides whether b executes.  Each block of the
+// function is thus control-dependent on the blocks of its frontier.
+// Each set is free of duplicates.
+func (t *PostDomTree) Frontier() [][]*BasicBlock {
+	n := len(t.fn.Blocks)
+	pdf := make([][]*BasicBlock, n)
+	for _, b := range t.fn.Blocks {
+		idom := t.idom[b.Index]
+		if idom < 0 || len(b.Succs) < 2 {
+			continue
+		}
+		for _, succ := range b.Succs {
+			if t.idom[succ.Index] < 0 {
+				continue // not in tree
+			}
+			for runner := succ.Index; runner != idom && runner != n; runner = t.idom[runner] {
+				p := &pdf[runner]
+				if len(*p) > 0 && (*p)[len(*p)-1] == b {
+					break // already visited from another successor
+				}
+				*p = append(*p, b)
+			}
+		}
+	}
+	return pdf
 }
 
 // Testing utilities ----------------------------------------
@@ -249,7 +510,7 @@ func sanityCheckDomTree(f *Function) {
 	for i := 0; i < n; i++ {
 		for j := 0; j < n; j++ {
 			b, c := f.Blocks[i], f.Blocks[j]
-			actual := dominates(b, c)
+			actual := b.Dominates(c)
 			expected := D[j].Bit(i) == 1
 			if actual != expected {
 				fmt.Fprintf(os.Stderr, "dominates(%s, %s)==%t, want %t\n", b, c, actual, expected)
@@ -272,9 +533,9 @@ func printDomTreeText(w io.Writer, v *domNode, indent int) {
 	}
 }
 
-// printDomTreeDot prints the dominator tree of f in AT&T GraphViz
-// (.dot) format.
-func printDomTreeDot(w io.Writer, f *Function) {
+// WriteDomTreeDot writes the dominator tree of f in AT&T GraphViz
+// (.dot) format, along with the edges of the CFG.
+func (f *Function) WriteDomTreeDot(w io.Writer) {
 	fmt.Fprintln(w, "//", f.FullName())
 	fmt.Fprintln(w, "digraph domtree {")
 	for i, b := range f.Blocks {
@@ -0,0 +1,155 @@
+package ssa_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+const domSrc = `package main
+
+func f(c bool) int {
+	x := 0
+	if c {
+		x = 1
+	} else {
+		x = 2
+	}
+	return x
+}
+
+func g(c bool) {
+	if c {
+		panic("c")
+	}
+	for {
+	}
+}
+
+func main() {
+	f(true)
+	g(false)
+}
+`
+
+func buildDomFunc(t *testing.T, mode ssa.BuilderMode, name string) *ssa.Function {
+	b := ssa.NewBuilder(&ssa.Context{Mode: mode, Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", domSrc, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pkg.Func(name)
+}
+
+// block returns the block of fn with the specified comment.
+func block(t *testing.T, fn *ssa.Function, comment string) *ssa.BasicBlock {
+	for _, b := range fn.Blocks {
+		if b.Comment == comment {
+			return b
+		}
+	}
+	t.Fatalf("%s: no block %q", fn, comment)
+	return nil
+}
+
+func contains(blocks []*ssa.BasicBlock, b *ssa.BasicBlock) bool {
+	for _, x := range blocks {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDominators(t *testing.T) {
+	// Dominance information must be available even without lifting.
+	for _, mode := range []ssa.BuilderMode{0, ssa.NaiveForm} {
+		f := buildDomFunc(t, mode, "f")
+		entry := f.Blocks[0]
+		then := block(t, f, "if.then")
+		els := block(t, f, "if.else")
+		done := block(t, f, "if.done")
+
+		if entry.Idom() != nil {
+			t.Errorf("entry.Idom() = %s, want nil", entry.Idom())
+		}
+		for _, b := range []*ssa.BasicBlock{then, els, done} {
+			if b.Idom() != entry {
+				t.Errorf("%s.Idom() = %s, want %s", b, b.Idom(), entry)
+			}
+			if !contains(entry.Dominees(), b) {
+				t.Errorf("%s not in entry.Dominees()", b)
+			}
+			if !entry.Dominates(b) || b.Dominates(entry) {
+				t.Errorf("Dominates(%s, %s) relation is wrong", entry, b)
+			}
+		}
+		if then.Dominates(done) {
+			t.Errorf("%s dominates %s", then, done)
+		}
+
+		preorder := f.DomPreorder()
+		if len(preorder) != len(f.Blocks) || preorder[0] != entry {
+			t.Errorf("DomPreorder() = %v", preorder)
+		}
+
+		df := f.DomFrontier()
+		for _, b := range []*ssa.BasicBlock{then, els} {
+			if got := df[b.Index]; len(got) != 1 || got[0] != done {
+				t.Errorf("DomFrontier()[%s] = %v, want [%s]", b, got, done)
+			}
+		}
+		if got := df[entry.Index]; len(got) != 0 {
+			t.Errorf("DomFrontier()[%s] = %v, want []", entry, got)
+		}
+	}
+}
+
+func TestPostDominators(t *testing.T) {
+	f := buildDomFunc(t, 0, "f")
+	entry := f.Blocks[0]
+	then := block(t, f, "if.then")
+	els := block(t, f, "if.else")
+	done := block(t, f, "if.done")
+
+	pdt := f.PostDomTree()
+	if roots := pdt.Roots(); len(roots) != 1 || roots[0] != done {
+		t.Errorf("Roots() = %v, want [%s]", roots, done)
+	}
+	for _, b := range []*ssa.BasicBlock{entry, then, els} {
+		if pdt.Idom(b) != done {
+			t.Errorf("Idom(%s) = %s, want %s", b, pdt.Idom(b), done)
+		}
+		if !pdt.Dominates(done, b) || pdt.Dominates(b, done) {
+			t.Errorf("Dominates(%s, %s) relation is wrong", done, b)
+		}
+	}
+
+	// Both branches are control-dependent on the entry block.
+	pdf := pdt.Frontier()
+	for _, b := range []*ssa.BasicBlock{then, els} {
+		if got := pdf[b.Index]; len(got) != 1 || got[0] != entry {
+			t.Errorf("Frontier()[%s] = %v, want [%s]", b, got, entry)
+		}
+	}
+
+	// In g, the infinite loop cannot reach an exit.
+	g := buildDomFunc(t, 0, "g")
+	pdt = g.PostDomTree()
+	then = block(t, g, "if.then")
+	if roots := pdt.Roots(); len(roots) != 1 || roots[0] != then {
+		t.Errorf("Roots() = %v, want [%s]", roots, then)
+	}
+	for _, b := range g.Blocks {
+		if b != then && b != g.Blocks[0] && pdt.Dominates(b, b) {
+			t.Errorf("block %s of infinite loop is in post-dominator tree", b)
+		}
+	}
+}
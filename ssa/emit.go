@@ -3,6 +3,7 @@ package ssa
 // Helpers for emitting SSA instructions.
 
 import (
+	"go/ast"
 	"go/token"
 
 	"code.google.com/p/go.tools/go/types"
@@ -197,10 +198,26 @@ func emitConv(f *Function, val Value, typ types.Type) Value {
 // emitStore emits to f an instruction to store value val at location
 // addr, applying implicit conversions as required by assignabilty rules.
 //
-func emitStore(f *Function, addr, val Value) {
-	f.emit(&Store{
+func emitStore(f *Function, addr, val Value) *Store {
+	s := &Store{
 		Addr: addr,
 		Val:  emitConv(f, val, addr.Type().Deref()),
+	}
+	f.emit(s)
+	return s
+}
+
+// emitDebugRef emits to f a DebugRef pseudo-instruction associating
+// expression e with value v, or with the address v if isAddr.
+// It is a no-op unless f was built with the DebugInfo mode.
+func emitDebugRef(f *Function, e ast.Expr, v Value, isAddr bool) {
+	if !f.debugInfo() {
+		return
+	}
+	f.emit(&DebugRef{
+		X:      v,
+		Expr:   e,
+		IsAddr: isAddr,
 	})
 }
 
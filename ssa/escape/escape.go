@@ -0,0 +1,160 @@
+// Package escape implements an escape analysis for programs in SSA
+// form.
+//
+// The analysis determines which objects allocated by a function (by
+// the instructions Alloc, MakeMap, MakeChan, MakeSlice, MakeClosure
+// and MakeInterface) may outlive the activation of the function, i.e.
+// may be referenced after it returns.  Such objects must be allocated
+// on the heap.  For each escaping object, the analysis reports the
+// chain of reasons, e.g. "stored in t2, which escapes: returned".
+//
+// Each function is analyzed separately, using a flow-insensitive
+// points-to analysis of its local objects.  Calls of functions with
+// known bodies are handled using summaries of the callees, which
+// record for each parameter (and free variable) whether it escapes and
+// whether it, or the objects reachable from it, may be returned; the
+// summaries are computed to a fixed
+// point, so recursion is handled.  Arguments of dynamic calls, and of
+// calls of functions without bodies, are assumed to escape.
+//
+// Unlike the Heap flag of ssa.Alloc, which the builder sets
+// syntactically (e.g. for variables captured by closures), the
+// analysis considers the actual uses of an object: a variable
+// captured by a closure that is only called directly need not escape.
+package escape
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+
+	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/callgraph"
+)
+
+// A Reason explains why an object escapes.  The chain of Reasons
+// linked by Next is ordered from the object outwards.
+type Reason struct {
+	Pos  token.Pos // position of the responsible instruction, if known
+	Msg  string    // e.g. "returned", "stored in t2"
+	Next *Reason   // the reason why the thing Msg refers to escapes, or nil
+}
+
+// Format returns the chain of reasons as a single line of text, using
+// fset to print positions.
+func (r *Reason) Format(fset *token.FileSet) string {
+	var buf bytes.Buffer
+	for ; r != nil; r = r.Next {
+		buf.WriteString(r.Msg)
+		if r.Pos.IsValid() {
+			fmt.Fprintf(&buf, " at %s", fset.Position(r.Pos))
+		}
+		if r.Next != nil {
+			buf.WriteString(", which escapes: ")
+		}
+	}
+	return buf.String()
+}
+
+func (r *Reason) String() string {
+	var buf bytes.Buffer
+	for ; r != nil; r = r.Next {
+		buf.WriteString(r.Msg)
+		if r.Next != nil {
+			buf.WriteString(", which escapes: ")
+		}
+	}
+	return buf.String()
+}
+
+// A Summary describes how a function treats its parameters and free
+// variables, as seen by its callers.
+type Summary struct {
+	Params   []Param // one per Function.Params
+	FreeVars []Param // one per Function.FreeVars
+}
+
+// A Param describes a parameter or free variable of a function.
+type Param struct {
+	Escapes          *Reason // why the objects it points to (or their contents) escape, or nil
+	Returned         bool    // the objects it points to may be returned
+	ContentsReturned bool    // the objects reachable from those may be returned
+}
+
+// A Result holds the results of the analysis of a program.
+type Result struct {
+	escapes   map[ssa.Value]*Reason
+	summaries map[*ssa.Function]*Summary
+}
+
+// Escapes returns the reason why the object allocated by site escapes,
+// or nil if it does not escape.
+func (r *Result) Escapes(site ssa.Value) *Reason {
+	return r.escapes[site]
+}
+
+// Summary returns the summary of function fn.
+func (r *Result) Summary(fn *ssa.Function) *Summary {
+	return r.summaries[fn]
+}
+
+// Analyze performs the escape analysis of all functions of prog, whose
+// packages must have been built.
+func Analyze(prog *ssa.Program) *Result {
+	a := &analysis{
+		result: &Result{
+			escapes:   make(map[ssa.Value]*Reason),
+			summaries: make(map[*ssa.Function]*Summary),
+		},
+		dependents: make(map[*ssa.Function]map[*ssa.Function]bool),
+		queued:     make(map[*ssa.Function]bool),
+	}
+
+	// The CHA call graph enumerates all functions of the program.
+	for _, n := range callgraph.Build(prog, callgraph.CHA).SortedNodes() {
+		fn := n.Func
+		a.result.summaries[fn] = &Summary{
+			Params:   make([]Param, len(fn.Params)),
+			FreeVars: make([]Param, len(fn.FreeVars)),
+		}
+		a.enqueue(fn)
+	}
+
+	// Analyze functions until no summary changes.
+	for len(a.queue) > 0 {
+		fn := a.queue[0]
+		a.queue = a.queue[1:]
+		a.queued[fn] = false
+		if a.analyze(fn) {
+			for dep := range a.dependents[fn] {
+				a.enqueue(dep)
+			}
+		}
+	}
+	return a.result
+}
+
+type analysis struct {
+	result     *Result
+	dependents map[*ssa.Function]map[*ssa.Function]bool // functions using each summary
+	queue      []*ssa.Function                          // functions to (re)analyze
+	queued     map[*ssa.Function]bool                   // set of elements of queue
+}
+
+func (a *analysis) enqueue(fn *ssa.Function) {
+	if !a.queued[fn] {
+		a.queued[fn] = true
+		a.queue = append(a.queue, fn)
+	}
+}
+
+// summary returns the summary of callee, as used by fn.
+func (a *analysis) summary(fn, callee *ssa.Function) *Summary {
+	deps := a.dependents[callee]
+	if deps == nil {
+		deps = make(map[*ssa.Function]bool)
+		a.dependents[callee] = deps
+	}
+	deps[fn] = true
+	return a.result.summaries[callee]
+}
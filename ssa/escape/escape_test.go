@@ -0,0 +1,98 @@
+package escape_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"strings"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/escape"
+)
+
+const src = `package main
+
+type T struct{ p *int }
+
+var g *int
+
+func leak(p *int)       { g = p }
+func keep(p *int) int   { return *p }
+func ident(p *int) *int { return p }
+func get(p **int) *int  { return *p }
+
+func f1() int  { x := 1; return keep(&x) }
+func f2()      { y := 2; leak(&y) }
+func f3() *int { z := 3; return ident(&z) }
+func f4() *T   { w := 4; return &T{&w} }
+func f5() *int { v := 5; pv := &v; return get(&pv) }
+
+func main() {
+	f1()
+	f2()
+	f3()
+	f4()
+	f5()
+}
+`
+
+// local returns the Alloc of the local variable name in fn.
+func local(fn *ssa.Function, name string) *ssa.Alloc {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if alloc, ok := instr.(*ssa.Alloc); ok && alloc.Name() == name {
+				return alloc
+			}
+		}
+	}
+	return nil
+}
+
+func TestEscapes(t *testing.T) {
+	b := ssa.NewBuilder(&ssa.Context{Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", src, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	res := escape.Analyze(b.Prog)
+
+	for _, test := range []struct {
+		fn, local string
+		want      string // prefix of the reason; "" if the local does not escape
+	}{
+		{"f1", "x", ""},
+		{"f2", "y", "passed to main.leak, which escapes: stored via g"},
+		{"f3", "z", "returned"},
+		{"f4", "w", "stored in "},
+		{"f5", "v", "returned"},
+		{"f5", "pv", ""},
+	} {
+		alloc := local(pkg.Func(test.fn), test.local)
+		if alloc == nil {
+			t.Errorf("%s: no Alloc for %s", test.fn, test.local)
+			continue
+		}
+		got := res.Escapes(alloc).String()
+		if test.want == "" && got != "" || !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s: %s escapes: got %q; want %q", test.fn, test.local, got, test.want)
+		}
+	}
+
+	if p := res.Summary(pkg.Func("leak")).Params[0]; p.Escapes == nil {
+		t.Errorf("parameter of leak does not escape")
+	}
+	if p := res.Summary(pkg.Func("keep")).Params[0]; p.Escapes != nil || p.Returned {
+		t.Errorf("parameter of keep: got %+v; want neither escaping nor returned", p)
+	}
+	if p := res.Summary(pkg.Func("ident")).Params[0]; p.Escapes != nil || !p.Returned || p.ContentsReturned {
+		t.Errorf("parameter of ident: got %+v; want returned", p)
+	}
+	if p := res.Summary(pkg.Func("get")).Params[0]; p.Escapes != nil || p.Returned || !p.ContentsReturned {
+		t.Errorf("parameter of get: got %+v; want contents returned", p)
+	}
+}
@@ -0,0 +1,515 @@
+package escape
+
+// This file implements the analysis of a single function.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/ssa"
+)
+
+// An object is an abstract object of the analysis of a function: a
+// local allocation site, a parameter or free variable (standing for
+// the objects the caller passes in), or the heap (standing for all
+// other objects).
+type object struct {
+	site     ssa.Value // *ssa.Alloc etc., *ssa.Parameter or *ssa.Capture; nil for the heap
+	param    *Param    // the summary of a parameter or free variable; nil otherwise
+	indirect bool      // for a parameter, stands for the objects reachable from its objects
+	contents objset    // the objects it may point to
+	escapes  *Reason   // non-nil if the object escapes
+}
+
+func (o *object) isLocal() bool { return o.site != nil && o.param == nil }
+
+type objset map[*object]bool
+
+// A funcState holds the state of the analysis of a function.
+type funcState struct {
+	a       *analysis
+	fn      *ssa.Function
+	heap    *object
+	objects map[ssa.Value]*object // objects by site
+	pts     map[ssa.Value]objset  // points-to sets of values
+	changed bool                  // a points-to set has changed
+}
+
+// analyze analyzes fn, records the escaping allocation sites and
+// updates its summary.  It reports whether the summary changed.
+func (a *analysis) analyze(fn *ssa.Function) bool {
+	f := &funcState{
+		a:       a,
+		fn:      fn,
+		heap:    &object{escapes: &Reason{Msg: "heap"}},
+		objects: make(map[ssa.Value]*object),
+		pts:     make(map[ssa.Value]objset),
+	}
+	f.heap.contents = objset{f.heap: true}
+
+	// Parameters and free variables point to their own objects,
+	// whose fate is recorded in the summary.
+	sum := a.result.summaries[fn]
+	params := make([]Param, len(fn.Params))
+	for i, p := range fn.Params {
+		f.param(p, &params[i])
+	}
+	freeVars := make([]Param, len(fn.FreeVars))
+	for i, fv := range fn.FreeVars {
+		f.param(fv, &freeVars[i])
+	}
+
+	// Compute the points-to sets to a fixed point, then the
+	// escaping objects.
+	for f.changed = true; f.changed; {
+		f.changed = false
+		f.visit(f.flow)
+	}
+	f.visit(f.escape)
+
+	for site, o := range f.objects {
+		if o.isLocal() {
+			if o.escapes != nil {
+				a.result.escapes[site] = o.escapes
+			} else {
+				delete(a.result.escapes, site)
+			}
+		}
+	}
+	return sum.update(params, freeVars)
+}
+
+// update sets the parameters and free variables of s, preserving the
+// old reasons, and reports whether s changed.
+func (s *Summary) update(params, freeVars []Param) bool {
+	changed := false
+	merge := func(old []Param, new []Param) {
+		for i := range old {
+			if old[i].Escapes == nil && new[i].Escapes != nil {
+				old[i].Escapes = new[i].Escapes
+				changed = true
+			}
+			if !old[i].Returned && new[i].Returned {
+				old[i].Returned = true
+				changed = true
+			}
+			if !old[i].ContentsReturned && new[i].ContentsReturned {
+				old[i].ContentsReturned = true
+				changed = true
+			}
+		}
+	}
+	merge(s.Params, params)
+	merge(s.FreeVars, freeVars)
+	return changed
+}
+
+// param creates the objects of the parameter or free variable v,
+// whose summary is p: the objects v points to, and the objects
+// reachable from them, which point to each other.
+func (f *funcState) param(v ssa.Value, p *Param) {
+	reach := &object{site: v, param: p, indirect: true}
+	reach.contents = objset{reach: true}
+	o := &object{site: v, param: p, contents: objset{reach: true}}
+	f.objects[v] = o
+	f.pts[v] = objset{o: true}
+}
+
+// visit calls visitor for each instruction of the function.
+func (f *funcState) visit(visitor func(ssa.Instruction)) {
+	for _, b := range f.fn.Blocks {
+		for _, instr := range b.Instrs {
+			visitor(instr)
+		}
+	}
+}
+
+// object returns the local object allocated by site.
+func (f *funcState) object(site ssa.Value) *object {
+	o := f.objects[site]
+	if o == nil {
+		o = &object{site: site, contents: make(objset)}
+		f.objects[site] = o
+	}
+	return o
+}
+
+// pointsTo returns the points-to set of v.
+func (f *funcState) pointsTo(v ssa.Value) objset {
+	if pts, ok := f.pts[v]; ok {
+		return pts
+	}
+	pts := make(objset)
+	if _, ok := v.(*ssa.Global); ok {
+		pts[f.heap] = true
+	}
+	f.pts[v] = pts
+	return pts
+}
+
+// addAll adds the objects of src to dst.
+func (f *funcState) addAll(dst, src objset) {
+	for o := range src {
+		if !dst[o] {
+			dst[o] = true
+			f.changed = true
+		}
+	}
+}
+
+// copy adds the points-to set of src to that of dst.
+func (f *funcState) copy(dst, src ssa.Value) {
+	f.addAll(f.pointsTo(dst), f.pointsTo(src))
+}
+
+// load adds the contents of the objects pointed to by src to the
+// points-to set of dst.
+func (f *funcState) load(dst, src ssa.Value) {
+	f.addAll(f.pointsTo(dst), f.contents(src))
+}
+
+// contents returns the union of the contents of the objects pointed
+// to by v.
+func (f *funcState) contents(v ssa.Value) objset {
+	union := make(objset)
+	for o := range f.pointsTo(v) {
+		for c := range o.contents {
+			union[c] = true
+		}
+	}
+	return union
+}
+
+// reachable returns the objects transitively pointed to by the
+// objects v points to.
+func (f *funcState) reachable(v ssa.Value) objset {
+	union := make(objset)
+	var work []*object
+	for o := range f.pointsTo(v) {
+		work = append(work, o)
+	}
+	for len(work) > 0 {
+		o := work[len(work)-1]
+		work = work[:len(work)-1]
+		for c := range o.contents {
+			if !union[c] {
+				union[c] = true
+				work = append(work, c)
+			}
+		}
+	}
+	return union
+}
+
+// store adds the points-to set of src to the contents of the local
+// objects pointed to by dst.  (Stores to other objects are escapes.)
+func (f *funcState) store(dst, src ssa.Value) {
+	for o := range f.pointsTo(dst) {
+		if o.isLocal() {
+			f.addAll(o.contents, f.pointsTo(src))
+		}
+	}
+}
+
+// flow computes the points-to sets for instr.
+func (f *funcState) flow(instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.Alloc, *ssa.MakeMap, *ssa.MakeChan, *ssa.MakeSlice:
+		v := instr.(ssa.Value)
+		f.addAll(f.pointsTo(v), objset{f.object(v): true})
+
+	case *ssa.MakeInterface:
+		o := f.object(instr)
+		f.addAll(f.pointsTo(instr), objset{o: true})
+		f.addAll(o.contents, f.pointsTo(instr.X))
+
+	case *ssa.MakeClosure:
+		o := f.object(instr)
+		f.addAll(f.pointsTo(instr), objset{o: true})
+		for _, b := range instr.Bindings {
+			f.addAll(o.contents, f.pointsTo(b))
+		}
+
+	case *ssa.Phi:
+		for _, edge := range instr.Edges {
+			f.copy(instr, edge)
+		}
+
+	case *ssa.UnOp:
+		switch instr.Op {
+		case token.MUL, token.ARROW:
+			f.load(instr, instr.X)
+		}
+
+	case *ssa.Store:
+		f.store(instr.Addr, instr.Val)
+	case *ssa.Send:
+		f.store(instr.Chan, instr.X)
+	case *ssa.MapUpdate:
+		f.store(instr.Map, instr.Key)
+		f.store(instr.Map, instr.Value)
+
+	case *ssa.Lookup:
+		if _, ok := instr.X.Type().Underlying().(*types.Map); ok {
+			f.load(instr, instr.X)
+		}
+	case *ssa.Next:
+		if !instr.IsString {
+			f.load(instr, instr.Iter)
+		}
+	case *ssa.Select:
+		for _, st := range instr.States {
+			if st.Dir == ast.SEND {
+				f.store(st.Chan, st.Send)
+			} else {
+				f.load(instr, st.Chan)
+			}
+		}
+	case *ssa.TypeAssert:
+		if _, ok := instr.AssertedType.Underlying().(*types.Interface); ok {
+			f.copy(instr, instr.X)
+		} else {
+			f.load(instr, instr.X) // the contents of the interface value
+		}
+
+	// Objects are not split into fields or elements.
+	case *ssa.FieldAddr:
+		f.copy(instr, instr.X)
+	case *ssa.Field:
+		f.copy(instr, instr.X)
+	case *ssa.IndexAddr:
+		f.copy(instr, instr.X)
+	case *ssa.Index:
+		f.copy(instr, instr.X)
+	case *ssa.Slice:
+		f.copy(instr, instr.X)
+	case *ssa.Range:
+		f.copy(instr, instr.X)
+	case *ssa.Extract:
+		f.copy(instr, instr.Tuple)
+	case *ssa.ChangeType:
+		f.copy(instr, instr.X)
+	case *ssa.ChangeInterface:
+		f.copy(instr, instr.X)
+	case *ssa.Convert:
+		f.copy(instr, instr.X)
+
+	case *ssa.Call:
+		f.flowCall(instr)
+	}
+}
+
+// flowCall computes the points-to set of the result of call.
+func (f *funcState) flowCall(call *ssa.Call) {
+	c := &call.Call
+	pts := f.pointsTo(call)
+	if b, ok := c.Func.(*ssa.Builtin); ok {
+		switch b.Name() {
+		case "append":
+			// The result is the first argument or a new array;
+			// the elements of the second are added to it.
+			f.copy(call, c.Args[0])
+			f.addAll(pts, objset{f.heap: true})
+			elems := f.contents(c.Args[1])
+			for o := range pts {
+				if o.isLocal() {
+					f.addAll(o.contents, elems)
+				}
+			}
+		case "copy":
+			elems := f.contents(c.Args[1])
+			for o := range f.pointsTo(c.Args[0]) {
+				if o.isLocal() {
+					f.addAll(o.contents, elems)
+				}
+			}
+		case "recover":
+			f.addAll(pts, objset{f.heap: true})
+		}
+		return
+	}
+
+	// The result may point to objects allocated by the callee
+	// (which escape), and to the arguments it returns or the
+	// objects reachable from them.
+	f.addAll(pts, objset{f.heap: true})
+	if callee := c.StaticCallee(); callee != nil && callee.Blocks != nil {
+		sum := f.a.summary(f.fn, callee)
+		for i, p := range sum.Params {
+			if i < len(c.Args) {
+				f.flowReturned(call, c.Args[i], p)
+			}
+		}
+		if mc, ok := c.Func.(*ssa.MakeClosure); ok {
+			for i, p := range sum.FreeVars {
+				f.flowReturned(call, mc.Bindings[i], p)
+			}
+		}
+	}
+}
+
+// flowReturned adds the objects of arg that the callee may return
+// according to p, the summary of its parameter, to the points-to set
+// of call.
+func (f *funcState) flowReturned(call *ssa.Call, arg ssa.Value, p Param) {
+	if p.Returned {
+		f.copy(call, arg)
+	}
+	if p.ContentsReturned {
+		f.addAll(f.pointsTo(call), f.reachable(arg))
+	}
+}
+
+// escape marks the objects that escape because of instr.
+func (f *funcState) escape(instr ssa.Instruction) {
+	pos := instr.Pos()
+	switch instr := instr.(type) {
+	case *ssa.Ret:
+		for _, res := range instr.Results {
+			for o := range f.pointsTo(res) {
+				switch {
+				case o.indirect:
+					o.param.ContentsReturned = true
+				case o.param != nil:
+					o.param.Returned = true
+				default:
+					f.mark(o, &Reason{pos, "returned", nil})
+				}
+			}
+		}
+
+	case *ssa.Store:
+		f.escapeStore(instr.Addr, instr.Val, pos)
+	case *ssa.MapUpdate:
+		f.escapeStore(instr.Map, instr.Key, pos)
+		f.escapeStore(instr.Map, instr.Value, pos)
+
+	case *ssa.Send:
+		f.markAll(instr.X, &Reason{pos, "sent on a channel", nil})
+	case *ssa.Select:
+		for _, st := range instr.States {
+			if st.Dir == ast.SEND {
+				f.markAll(st.Send, &Reason{pos, "sent on a channel", nil})
+			}
+		}
+	case *ssa.Panic:
+		f.markAll(instr.X, &Reason{pos, "passed to panic", nil})
+
+	case *ssa.MakeClosure:
+		// The free variables of a closure escape as its body
+		// dictates, even if the closure itself does not.
+		callee := instr.Fn.(*ssa.Function)
+		sum := f.a.summary(f.fn, callee)
+		for i, b := range instr.Bindings {
+			if r := sum.FreeVars[i].Escapes; r != nil {
+				f.markAll(b, &Reason{pos, fmt.Sprintf("captured by %s", callee.Name()), r})
+			}
+		}
+
+	case *ssa.Call:
+		f.escapeCall(&instr.Call, "", pos)
+	case *ssa.Defer:
+		f.escapeCall(&instr.Call, "", pos)
+	case *ssa.Go:
+		f.escapeCall(&instr.Call, "go statement", pos)
+	}
+
+}
+
+// escapeStore marks the objects pointed to by val, stored in the
+// objects pointed to by addr, that escape because addr may point to
+// an object of the caller or of the heap.
+func (f *funcState) escapeStore(addr, val ssa.Value, pos token.Pos) {
+	for o := range f.pointsTo(addr) {
+		switch {
+		case o == f.heap:
+			f.markAll(val, &Reason{pos, fmt.Sprintf("stored via %s, which points to the heap", addr.Name()), nil})
+		case o.param != nil:
+			f.markAll(val, &Reason{pos, fmt.Sprintf("stored in parameter %s", o.site.Name()), nil})
+		}
+	}
+}
+
+// escapeCall marks the arguments of call c that escape.
+func (f *funcState) escapeCall(c *ssa.CallCommon, kind string, pos token.Pos) {
+	if _, ok := c.Func.(*ssa.Builtin); ok {
+		return // append, copy: handled by flow; others: no escape
+	}
+	callee := c.StaticCallee()
+	var why string
+	switch {
+	case kind != "":
+		why = "passed to " + kind
+	case callee == nil:
+		why = "passed to dynamic call"
+	case callee.Blocks == nil:
+		why = "passed to " + callee.FullName() + ", which has no body"
+	}
+	if why != "" {
+		if c.Recv != nil {
+			f.markAll(c.Recv, &Reason{pos, why, nil})
+		}
+		if mc, ok := c.Func.(*ssa.MakeClosure); ok {
+			f.markAll(mc, &Reason{pos, why, nil})
+		} else if callee == nil {
+			f.markAll(c.Func, &Reason{pos, why, nil})
+		}
+		for _, arg := range c.Args {
+			f.markAll(arg, &Reason{pos, why, nil})
+		}
+		return
+	}
+
+	sum := f.a.summary(f.fn, callee)
+	for i, arg := range c.Args {
+		if i < len(sum.Params) && sum.Params[i].Escapes != nil {
+			f.markAll(arg, &Reason{pos, fmt.Sprintf("passed to %s", callee.FullName()), sum.Params[i].Escapes})
+		}
+	}
+}
+
+// markAll marks the objects pointed to by v as escaping.
+func (f *funcState) markAll(v ssa.Value, r *Reason) {
+	for o := range f.pointsTo(v) {
+		f.mark(o, r)
+	}
+}
+
+// mark marks o as escaping, unless it already is.
+func (f *funcState) mark(o *object, r *Reason) {
+	if o == f.heap {
+		return
+	}
+	if o.param != nil {
+		if o.param.Escapes == nil {
+			o.param.Escapes = r
+		}
+		return
+	}
+	if o.escapes == nil {
+		o.escapes = r
+		f.markContents(o)
+	}
+}
+
+// markContents marks the contents of the escaping object o:
+// everything stored in an escaping object escapes too.
+func (f *funcState) markContents(o *object) {
+	for c := range o.contents {
+		if c.escapes == nil && (c.param == nil || c.param.Escapes == nil) {
+			f.mark(c, &Reason{valuePos(o.site), fmt.Sprintf("stored in %s", o.site.Name()), o.escapes})
+		}
+	}
+}
+
+// valuePos returns the position of v, if known.
+func valuePos(v ssa.Value) token.Pos {
+	if v, ok := v.(interface {
+		Pos() token.Pos
+	}); ok {
+		return v.Pos()
+	}
+	return token.NoPos
+}
@@ -93,6 +93,7 @@ func (b *BasicBlock) removePred(p *BasicBlock) {
 	phis := b.phis()
 
 	// We must preserve edge order for φ-nodes.
+	var struck []Value // φ-edges struck out, parallel to phis
 	j := 0
 	for i, pred := range b.Preds {
 		if pred != p {
@@ -103,6 +104,10 @@ func (b *BasicBlock) removePred(p *BasicBlock) {
 				phi.Edges[j] = phi.Edges[i]
 			}
 			j++
+		} else {
+			for _, instr := range phis {
+				struck = append(struck, instr.(*Phi).Edges[i])
+			}
 		}
 	}
 	// Nil out b.Preds[j:] and φ-edges[j:] to aid GC.
@@ -117,6 +122,14 @@ func (b *BasicBlock) removePred(p *BasicBlock) {
 		phi := instr.(*Phi)
 		phi.Edges = phi.Edges[:j]
 	}
+
+	// Keep Referrers up-to-date for values no longer used by a φ.
+	for i, v := range struck {
+		phi := phis[i%len(phis)].(*Phi)
+		if v != nil && !uses(phi, v) {
+			removeReferrer(v, phi)
+		}
+	}
 }
 
 // Destinations associated with unlabelled for/switch/select stmts.
@@ -302,6 +315,64 @@ func buildReferrers(f *Function) {
 	}
 }
 
+// dropReferrers removes instr from the Referrers of its operands,
+// e.g. because instr is being deleted.
+func dropReferrers(instr Instruction) {
+	for _, rand := range instr.Operands(nil) {
+		if r := *rand; r != nil {
+			removeReferrer(r, instr)
+		}
+	}
+}
+
+// removeReferrer removes all occurrences of instr from v.Referrers().
+func removeReferrer(v Value, instr Instruction) {
+	if refs := v.Referrers(); refs != nil {
+		j := 0
+		for _, ref := range *refs {
+			if ref != instr {
+				(*refs)[j] = ref
+				j++
+			}
+		}
+		for i := j; i < len(*refs); i++ {
+			(*refs)[i] = nil // aid GC
+		}
+		*refs = (*refs)[:j]
+	}
+}
+
+// uses reports whether v is an operand of instr.
+func uses(instr Instruction, v Value) bool {
+	for _, rand := range instr.Operands(nil) {
+		if *rand == v {
+			return true
+		}
+	}
+	return false
+}
+
+// compactInstrs eliminates the nil Instrs of b, of which there are
+// b.gaps, and resets b.gaps.
+func compactInstrs(b *BasicBlock) {
+	if b.gaps == 0 {
+		return
+	}
+	j := 0
+	for _, instr := range b.Instrs {
+		if instr != nil {
+			b.Instrs[j] = instr
+			j++
+		}
+	}
+	// Nil out b.Instrs[j:] to aid GC.
+	for i := j; i < len(b.Instrs); i++ {
+		b.Instrs[i] = nil
+	}
+	b.Instrs = b.Instrs[:j]
+	b.gaps = 0
+}
+
 // finishBody() finalizes the function after SSA code generation of its body.
 func (f *Function) finishBody() {
 	f.objects = nil
@@ -334,6 +405,18 @@ func (f *Function) finishBody() {
 		// f.DumpTo(os.Stderr)
 
 		lift(f)
+	} else {
+		buildDomTree(f) // done by lift otherwise
+	}
+
+	if f.Prog.mode&ConstantPropagation != 0 && propagateConstants(f) {
+		buildDomTree(f) // the CFG has changed
+	}
+	if f.Prog.mode&ValueNumbering != 0 {
+		numberValues(f)
+	}
+	if f.Prog.mode&DeadCodeElimination != 0 {
+		eliminateDeadCode(f)
 	}
 
 	numberRegisters(f)
@@ -425,6 +508,12 @@ func (f *Function) emit(instr Instruction) Value {
 	return f.currentBlock.emit(instr)
 }
 
+// debugInfo reports whether f is being built with DebugRef
+// instructions.
+func (f *Function) debugInfo() bool {
+	return f.Prog.mode&DebugInfo != 0
+}
+
 // FullName returns the full name of this function, qualified by
 // package name, receiver type, etc.
 //
@@ -0,0 +1,161 @@
+package ssa
+
+// This file defines the global value numbering pass, enabled by the
+// ValueNumbering builder mode.
+//
+// The pass is a dominator-based common subexpression elimination: it
+// walks the dominator tree in preorder, keeping a scoped table of the
+// pure computations performed by the dominating instructions, and
+// replaces each instruction equivalent to one in the table (the same
+// operation on the same operands, yielding the same type) by the
+// earlier instruction.  Since the walk visits definitions before uses,
+// operands are already numbered when their users are visited.
+//
+// Unlike the instructions deleted by dead code elimination, the
+// instructions considered here need not be free of panics: if the
+// dominating computation completed, so will an equivalent one.  But
+// they must not depend on memory (e.g. loads or conversions of []byte
+// to string) or allocate a new object (e.g. MakeMap or conversions of
+// strings to []byte), and φ-nodes are not considered.
+
+import (
+	"fmt"
+	"go/token"
+
+	"code.google.com/p/go.tools/go/exact"
+	"code.google.com/p/go.tools/go/types"
+)
+
+// A gvnKey identifies a computation, up to the type of its result.
+type gvnKey struct {
+	op      string      // the kind of instruction and its operator, if any
+	x, y, z interface{} // operands, as by gvnOperand
+	aux     int         // e.g. the field index
+}
+
+// gvnKeyOf returns the key of instr, and whether it is a candidate
+// for numbering.
+func gvnKeyOf(instr Instruction) (gvnKey, bool) {
+	switch instr := instr.(type) {
+	case *BinOp:
+		return gvnKey{op: "binop " + instr.Op.String(), x: gvnOperand(instr.X), y: gvnOperand(instr.Y)}, true
+	case *UnOp:
+		if instr.Op == token.MUL || instr.Op == token.ARROW {
+			return gvnKey{}, false // depends on memory
+		}
+		return gvnKey{op: "unop " + instr.Op.String(), x: gvnOperand(instr.X)}, true
+	case *Convert:
+		// Conversions to []byte and []rune allocate, and
+		// conversions from them depend on memory.
+		if isSlice(instr.X.Type()) || isSlice(instr.Type()) {
+			return gvnKey{}, false
+		}
+		return gvnKey{op: "convert", x: gvnOperand(instr.X)}, true
+	case *ChangeType:
+		return gvnKey{op: "changetype", x: gvnOperand(instr.X)}, true
+	case *ChangeInterface:
+		return gvnKey{op: "changeinterface", x: gvnOperand(instr.X)}, true
+	case *Field:
+		return gvnKey{op: "field", x: gvnOperand(instr.X), aux: instr.Field}, true
+	case *FieldAddr:
+		return gvnKey{op: "fieldaddr", x: gvnOperand(instr.X), aux: instr.Field}, true
+	case *Index:
+		return gvnKey{op: "index", x: gvnOperand(instr.X), y: gvnOperand(instr.Index)}, true
+	case *IndexAddr:
+		return gvnKey{op: "indexaddr", x: gvnOperand(instr.X), y: gvnOperand(instr.Index)}, true
+	case *Slice:
+		return gvnKey{op: "slice", x: gvnOperand(instr.X), y: gvnOperand(instr.Low), z: gvnOperand(instr.High)}, true
+	case *Extract:
+		return gvnKey{op: "extract", x: gvnOperand(instr.Tuple), aux: instr.Index}, true
+	case *TypeAssert:
+		return gvnKey{op: fmt.Sprintf("typeassert %t", instr.CommaOk), x: gvnOperand(instr.X)}, true
+	}
+	return gvnKey{}, false
+}
+
+// isSlice reports whether t is a slice type.
+func isSlice(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Slice)
+	return ok
+}
+
+// literalKey identifies a literal by its type and exact value.
+type literalKey struct {
+	typ, val string
+}
+
+// gvnOperand returns the key of operand v: v itself, or for a
+// literal, its type and value, since equal literals are not shared.
+func gvnOperand(v Value) interface{} {
+	if lit, ok := v.(*Literal); ok {
+		return literalKey{lit.Type().String(), exact.Literal(lit.Value, exact.Decimal, -1)}
+	}
+	return v
+}
+
+// gvnState holds the state of the numbering of a function.
+type gvnState struct {
+	table   map[gvnKey][]Value // values of dominating computations, innermost last
+	changed bool
+}
+
+// numberValues performs global value numbering of f, whose Referrers
+// and dominator tree must be up-to-date.  It reports whether any
+// instructions were deleted.
+func numberValues(f *Function) bool {
+	s := &gvnState{table: make(map[gvnKey][]Value)}
+	s.visit(f.Blocks[0])
+	if s.changed {
+		for _, b := range f.Blocks {
+			compactInstrs(b)
+		}
+	}
+	return s.changed
+}
+
+// visit numbers the instructions of b and the blocks it dominates.
+func (s *gvnState) visit(b *BasicBlock) {
+	var added []gvnKey
+	for i, instr := range b.Instrs {
+		key, ok := gvnKeyOf(instr)
+		if !ok {
+			continue
+		}
+		v := instr.(Value)
+		if w := s.lookup(key, v.Type()); w != nil {
+			replaceAll(v, w)
+			dropReferrers(instr)
+			b.Instrs[i] = nil
+			b.gaps++
+			s.changed = true
+			continue
+		}
+		s.table[key] = append(s.table[key], v)
+		added = append(added, key)
+	}
+
+	for _, child := range b.dom.Children {
+		s.visit(child.Block)
+	}
+
+	// Leave the scope of b.
+	for _, key := range added {
+		vs := s.table[key]
+		if len(vs) == 1 {
+			delete(s.table, key)
+		} else {
+			s.table[key] = vs[:len(vs)-1]
+		}
+	}
+}
+
+// lookup returns a value in scope with the specified key and type, or
+// nil if there is none.
+func (s *gvnState) lookup(key gvnKey, typ types.Type) Value {
+	for _, v := range s.table[key] {
+		if types.IsIdentical(v.Type(), typ) {
+			return v
+		}
+	}
+	return nil
+}
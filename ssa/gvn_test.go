@@ -0,0 +1,130 @@
+package ssa_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+const optSrc = `package main
+
+type T struct{ a, b int }
+
+func f(x, y int) int {
+	a := x*y + 1
+	if x > 0 {
+		return x*y + 1
+	}
+	return a
+}
+
+func g(t T, s [3]int, x int) int {
+	_ = t.a
+	_ = s[1]
+	_ = x / 2
+	_ = x / x // may panic
+	return x
+}
+
+func h(s string, p []byte) (byte, byte, string, string) {
+	a := []byte(s)
+	b := []byte(s) // a new slice
+	a[0] = 'x'
+	s1 := string(p)
+	p[0] = 'z'
+	s2 := string(p) // different contents
+	return a[0], b[0], s1, s2
+}
+
+func k(x float64) (float64, float64) {
+	// The literals agree in the first 10 digits.
+	return x * 1.00000000001e100, x * 1.00000000002e100
+}
+
+func l(m map[interface{}]int, n map[string]int, k, x, y interface{}, t, u struct{ x interface{} }) {
+	_ = m[k]   // may panic
+	_ = n["a"]
+	_ = x == y // may panic
+	_ = t != u // may panic
+	_ = 1 == len(n)
+}
+
+func main() {
+	f(1, 2)
+	g(T{}, [3]int{}, 1)
+}
+`
+
+func buildOpt(t *testing.T, mode ssa.BuilderMode) *ssa.Package {
+	b := ssa.NewBuilder(&ssa.Context{Mode: mode | ssa.SanityCheckFunctions, Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", optSrc, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pkg
+}
+
+// countInstrs returns the number of instructions of fn for which pred
+// is true.
+func countInstrs(fn *ssa.Function, pred func(ssa.Instruction) bool) int {
+	n := 0
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if pred(instr) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func isBinOp(op token.Token) func(ssa.Instruction) bool {
+	return func(instr ssa.Instruction) bool {
+		b, ok := instr.(*ssa.BinOp)
+		return ok && b.Op == op
+	}
+}
+
+func TestValueNumbering(t *testing.T) {
+	for _, test := range []struct {
+		mode      ssa.BuilderMode
+		mul, adds int
+	}{
+		{0, 2, 2},
+		{ssa.ValueNumbering, 1, 1}, // x*y+1 is computed once
+	} {
+		fn := buildOpt(t, test.mode).Func("f")
+		if n := countInstrs(fn, isBinOp(token.MUL)); n != test.mul {
+			t.Errorf("mode %d: %s has %d multiplications, want %d", test.mode, fn, n, test.mul)
+		}
+		if n := countInstrs(fn, isBinOp(token.ADD)); n != test.adds {
+			t.Errorf("mode %d: %s has %d additions, want %d", test.mode, fn, n, test.adds)
+		}
+	}
+}
+
+func TestValueNumberingConversions(t *testing.T) {
+	fn := buildOpt(t, ssa.ValueNumbering).Func("h")
+	isConvert := func(instr ssa.Instruction) bool {
+		_, ok := instr.(*ssa.Convert)
+		return ok
+	}
+	if n := countInstrs(fn, isConvert); n != 4 {
+		t.Errorf("%s has %d conversions, want 4", fn, n)
+	}
+}
+
+func TestValueNumberingLiterals(t *testing.T) {
+	fn := buildOpt(t, ssa.ValueNumbering).Func("k")
+	if n := countInstrs(fn, isBinOp(token.MUL)); n != 2 {
+		t.Errorf("%s has %d multiplications, want 2", fn, n)
+	}
+}
@@ -207,6 +207,7 @@ func lift(fn *Function) {
 		j := 0
 		for _, np := range nps {
 			if len(*np.phi.Referrers()) == 0 {
+				dropReferrers(np.phi)
 				continue // unreferenced phi
 			}
 			nps[j] = np
@@ -317,7 +318,7 @@ func liftAlloc(df domFrontier, alloc *Alloc, newPhis newPhiMap) bool {
 	for _, instr := range *alloc.Referrers() {
 		// Bail out if we discover the alloc is not liftable;
 		// the only operations permitted to use the alloc are
-		// loads/stores into the cell.
+		// loads/stores into the cell, and debug references.
 		switch instr := instr.(type) {
 		case *Store:
 			if instr.Val == alloc {
@@ -334,6 +335,8 @@ func liftAlloc(df domFrontier, alloc *Alloc, newPhis newPhiMap) bool {
 			if instr.X != alloc {
 				panic("Alloc.Referrers is inconsistent")
 			}
+		case *DebugRef:
+			// ok; deleted by rename
 		default:
 			return false // some other instruction
 		}
@@ -431,7 +434,7 @@ func renamed(renaming []Value, alloc *Alloc) Value {
 // preorder traversal of the dominator tree replacing all loads of
 // Alloc cells with the value stored to that cell by the dominating
 // store instruction.  For lifting, we need only consider loads,
-// stores and φ-nodes.
+// stores, debug references and φ-nodes.
 //
 // renaming is a map from *Alloc (keyed by index number) to its
 // dominating stored value; newPhis[x] is the set of new φ-nodes to be
@@ -452,6 +455,7 @@ func rename(u *BasicBlock, renaming []Value, newPhis newPhiMap) {
 		case *Store:
 			if alloc, ok := instr.Addr.(*Alloc); ok && alloc.index != -1 { // store to Alloc cell
 				// Delete the Store.
+				dropReferrers(instr)
 				u.Instrs[i] = nil
 				u.gaps++
 				// Replace dominated loads by the
@@ -477,6 +481,15 @@ func rename(u *BasicBlock, renaming []Value, newPhis newPhiMap) {
 					u.gaps++
 				}
 			}
+		case *DebugRef:
+			if alloc, ok := instr.X.(*Alloc); ok && alloc.index != -1 { // reference to Alloc cell
+				// Delete the DebugRef.  Once lifted, the
+				// variable has no address; the DebugRefs
+				// for its loads and stores remain.
+				dropReferrers(instr)
+				u.Instrs[i] = nil
+				u.gaps++
+			}
 		}
 	}
 
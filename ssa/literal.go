@@ -72,7 +72,20 @@ func (l *Literal) Name() string {
 		}
 		s = strconv.Quote(s)
 	} else {
-		s = l.Value.String()
+		// Typed floating-point values have been rounded by the
+		// type checker; print them with just enough digits to
+		// reproduce the rounded value (9 and 17 significant
+		// digits for float32 and float64, respectively).
+		prec := -1 // exact
+		if t, ok := l.Type_.Underlying().(*types.Basic); ok {
+			switch t.Kind() {
+			case types.Float32, types.Complex64:
+				prec = 9
+			case types.Float64, types.Complex128:
+				prec = 17
+			}
+		}
+		s = exact.Literal(l.Value, exact.Decimal, prec)
 	}
 	return s + ":" + l.Type_.String()
 }
@@ -96,10 +109,9 @@ func (l *Literal) IsNil() bool {
 func (l *Literal) Int64() int64 {
 	switch x := l.Value; x.Kind() {
 	case exact.Int:
-		if i, ok := exact.Int64Val(x); ok {
-			return i
-		}
-		return 0
+		x, _ = exact.ConvertInt(x, 8, true)
+		i, _ := exact.Int64Val(x)
+		return i
 	case exact.Float:
 		f, _ := exact.Float64Val(x)
 		return int64(f)
@@ -113,10 +125,9 @@ func (l *Literal) Int64() int64 {
 func (l *Literal) Uint64() uint64 {
 	switch x := l.Value; x.Kind() {
 	case exact.Int:
-		if u, ok := exact.Uint64Val(x); ok {
-			return u
-		}
-		return 0
+		x, _ = exact.ConvertInt(x, 8, false)
+		u, _ := exact.Uint64Val(x)
+		return u
 	case exact.Float:
 		f, _ := exact.Float64Val(x)
 		return uint64(f)
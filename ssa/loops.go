@@ -0,0 +1,303 @@
+package ssa
+
+// This file defines the loop nesting forest of a function.
+//
+// We use the algorithm of Steensgaard (1993), Sequentializing
+// program dependence graphs for irreducible programs, MSR-TR-93-14:
+// the outermost loops are the non-trivial strongly connected
+// components (SCCs) of the CFG; the loops nested within a loop are
+// found recursively among the SCCs of its body after removing the
+// edges into its entries.
+//
+// For reducible control flow, each loop has a single entry, its
+// header, which dominates all the blocks of the loop; the edges into
+// the header from within the loop are back edges (edges whose target
+// dominates their source), and the loop is the union of the natural
+// loops of those back edges.  Irreducible control flow, which can
+// arise from goto statements, yields loops with multiple entries,
+// none of which dominates the others.
+
+import (
+	"fmt"
+	"io"
+)
+
+// A Loop is a cycle of the CFG of a function that is maximal within
+// its parent loop (or the function).
+type Loop struct {
+	// Header is the unique entry of a natural loop.  For an
+	// irreducible loop it is the entry with the lowest Index.
+	Header *BasicBlock
+
+	// Entries are the blocks of the loop with a predecessor
+	// outside it, ordered by Index.  For a natural loop, it is
+	// just the Header.
+	Entries []*BasicBlock
+
+	// Blocks are the blocks of the loop, including those of
+	// nested loops, ordered by Index.
+	Blocks []*BasicBlock
+
+	// Latches are the blocks of the loop with an edge to an entry,
+	// ordered by Index.  For a natural loop, these are the sources
+	// of its back edges.
+	Latches []*BasicBlock
+
+	// Exits are the blocks outside the loop with a predecessor in
+	// it, ordered by Index.
+	Exits []*BasicBlock
+
+	Parent      *Loop   // the immediately enclosing loop, or nil
+	Children    []*Loop // the loops immediately nested within this one, ordered like Roots
+	Depth       int     // nesting depth; 1 for an outermost loop
+	Irreducible bool    // the loop has more than one entry
+
+	member []bool // member[b.Index] iff b is in Blocks
+}
+
+// Contains reports whether b is a block of loop l, or of a loop
+// nested within it.
+func (l *Loop) Contains(b *BasicBlock) bool {
+	return l.member[b.Index]
+}
+
+func (l *Loop) String() string {
+	kind := "loop"
+	if l.Irreducible {
+		kind = "irreducible loop"
+	}
+	return fmt.Sprintf("%s at %s (depth %d)", kind, l.Header, l.Depth)
+}
+
+// A LoopForest is the loop nesting forest of a function.
+//
+// Like a PostDomTree, it is a snapshot of the CFG at the time it was
+// computed.
+type LoopForest struct {
+	Roots []*Loop // the outermost loops, ordered by their lowest-numbered block
+	loop  []*Loop // innermost loop of each block, by Block.Index
+}
+
+// Loop returns the innermost loop containing b, or nil if b is not in
+// a loop.
+func (lf *LoopForest) Loop(b *BasicBlock) *Loop {
+	return lf.loop[b.Index]
+}
+
+// Depth returns the loop nesting depth of b: zero if b is not in a
+// loop.
+func (lf *LoopForest) Depth(b *BasicBlock) int {
+	if l := lf.loop[b.Index]; l != nil {
+		return l.Depth
+	}
+	return 0
+}
+
+// DumpTo prints a textual description of the forest to w, using
+// indentation to show nesting.
+func (lf *LoopForest) DumpTo(w io.Writer) {
+	var dump func(l *Loop)
+	dump = func(l *Loop) {
+		fmt.Fprintf(w, "%*s%s: blocks %v, latches %v, exits %v\n",
+			4*(l.Depth-1), "", l, l.Blocks, l.Latches, l.Exits)
+		for _, child := range l.Children {
+			dump(child)
+		}
+	}
+	for _, root := range lf.Roots {
+		dump(root)
+	}
+}
+
+// LoopForest computes the loop nesting forest of f, which must have a
+// body.
+func (f *Function) LoopForest() *LoopForest {
+	n := len(f.Blocks)
+	lb := &loopBuilder{
+		fn:     f,
+		forest: &LoopForest{loop: make([]*Loop, n)},
+		cut:    make([]bool, n),
+		index:  make([]int, n),
+		low:    make([]int, n),
+		onStk:  make([]bool, n),
+	}
+	all := make([]bool, n)
+	for i := range all {
+		all[i] = true
+	}
+	lb.findLoops(f.Blocks, all, nil)
+	return lb.forest
+}
+
+// loopBuilder holds the state of the construction of a LoopForest.
+type loopBuilder struct {
+	fn     *Function
+	forest *LoopForest
+	cut    []bool // edges into cut[b.Index] are ignored: b is an entry of an enclosing loop
+
+	// State of Tarjan's SCC algorithm, by Block.Index.
+	index []int  // DFS preorder number plus one; zero if not yet visited
+	low   []int  // lowest index reachable
+	onStk []bool // block is on stack
+	stack []*BasicBlock
+	next  int
+	sccs  [][]*BasicBlock
+}
+
+// edge reports whether the edge to succ is part of the region of the
+// CFG described by member.
+func (lb *loopBuilder) edge(succ *BasicBlock, member []bool) bool {
+	return member[succ.Index] && !lb.cut[succ.Index]
+}
+
+// findLoops adds to the forest the loops among blocks, whose
+// membership is described by member, nested within parent.
+func (lb *loopBuilder) findLoops(blocks []*BasicBlock, member []bool, parent *Loop) {
+	// Find the SCCs of the region.
+	lb.sccs = lb.sccs[:0]
+	for _, b := range blocks {
+		lb.index[b.Index] = 0
+	}
+	for _, b := range blocks {
+		if lb.index[b.Index] == 0 {
+			lb.strongConnect(b, member)
+		}
+	}
+	sccs := lb.sccs
+	lb.sccs = nil
+	sccOf := make(map[*BasicBlock][]*BasicBlock)
+	for _, scc := range sccs {
+		for _, b := range scc {
+			sccOf[b] = scc
+		}
+	}
+
+	// Process them in order of their lowest-numbered block.
+	for _, b := range blocks {
+		scc := sccOf[b]
+		if scc == nil {
+			continue // already processed
+		}
+		for _, b := range scc {
+			delete(sccOf, b)
+		}
+		if len(scc) == 1 && !lb.selfLoop(scc[0], member) {
+			continue // trivial
+		}
+		l := lb.newLoop(scc, parent)
+		for _, entry := range l.Entries {
+			lb.cut[entry.Index] = true
+		}
+		lb.findLoops(l.Blocks, l.member, l)
+	}
+}
+
+// selfLoop reports whether b has an edge to itself within the region.
+func (lb *loopBuilder) selfLoop(b *BasicBlock, member []bool) bool {
+	for _, succ := range b.Succs {
+		if succ == b && lb.edge(succ, member) {
+			return true
+		}
+	}
+	return false
+}
+
+// strongConnect implements the recursive part of Tarjan's algorithm.
+// It appends each SCC found to lb.sccs, in reverse topological order.
+func (lb *loopBuilder) strongConnect(v *BasicBlock, member []bool) {
+	lb.next++
+	lb.index[v.Index] = lb.next
+	lb.low[v.Index] = lb.next
+	lb.stack = append(lb.stack, v)
+	lb.onStk[v.Index] = true
+
+	for _, w := range v.Succs {
+		if !lb.edge(w, member) {
+			continue
+		}
+		if lb.index[w.Index] == 0 {
+			lb.strongConnect(w, member)
+			if lb.low[w.Index] < lb.low[v.Index] {
+				lb.low[v.Index] = lb.low[w.Index]
+			}
+		} else if lb.onStk[w.Index] && lb.index[w.Index] < lb.low[v.Index] {
+			lb.low[v.Index] = lb.index[w.Index]
+		}
+	}
+
+	if lb.low[v.Index] == lb.index[v.Index] {
+		var scc []*BasicBlock
+		for {
+			w := lb.stack[len(lb.stack)-1]
+			lb.stack = lb.stack[:len(lb.stack)-1]
+			lb.onStk[w.Index] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		lb.sccs = append(lb.sccs, scc)
+	}
+}
+
+// newLoop creates the loop whose blocks are scc, nested within parent.
+func (lb *loopBuilder) newLoop(scc []*BasicBlock, parent *Loop) *Loop {
+	l := &Loop{
+		Parent: parent,
+		Depth:  1,
+		member: make([]bool, len(lb.fn.Blocks)),
+	}
+	for _, b := range scc {
+		l.member[b.Index] = true
+	}
+	if parent != nil {
+		l.Depth = parent.Depth + 1
+		parent.Children = append(parent.Children, l)
+	} else {
+		lb.forest.Roots = append(lb.forest.Roots, l)
+	}
+
+	// Visit the function's blocks in order so that each list
+	// is ordered by Index.
+	isEntry := make([]bool, len(lb.fn.Blocks))
+	isExit := make([]bool, len(lb.fn.Blocks))
+	for _, b := range lb.fn.Blocks {
+		if !l.member[b.Index] {
+			continue
+		}
+		l.Blocks = append(l.Blocks, b)
+		lb.forest.loop[b.Index] = l // nested loops will overwrite this
+		if b.Index == 0 {
+			isEntry[b.Index] = true
+		}
+		for _, pred := range b.Preds {
+			if !l.member[pred.Index] {
+				isEntry[b.Index] = true
+			}
+		}
+		for _, succ := range b.Succs {
+			if !l.member[succ.Index] {
+				isExit[succ.Index] = true
+			}
+		}
+	}
+	for _, b := range lb.fn.Blocks {
+		if isEntry[b.Index] {
+			l.Entries = append(l.Entries, b)
+		}
+		if isExit[b.Index] {
+			l.Exits = append(l.Exits, b)
+		}
+	}
+	for _, b := range l.Blocks {
+		for _, succ := range b.Succs {
+			if isEntry[succ.Index] {
+				l.Latches = append(l.Latches, b)
+				break
+			}
+		}
+	}
+	l.Header = l.Entries[0]
+	l.Irreducible = len(l.Entries) > 1
+	return l
+}
@@ -0,0 +1,118 @@
+package ssa_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+const loopsSrc = `package main
+
+func nested(n int) int {
+	s := 0
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			s += j
+		}
+	}
+	return s
+}
+
+func irreducible(c bool) {
+	if c {
+		goto L2
+	}
+L1:
+	println(1)
+L2:
+	println(2)
+	goto L1
+}
+
+func straight() int {
+	return 1
+}
+
+func main() {
+	nested(1)
+	irreducible(false)
+	straight()
+}
+`
+
+func buildLoopsPackage(t *testing.T) *ssa.Package {
+	b := ssa.NewBuilder(&ssa.Context{Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", loopsSrc, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pkg
+}
+
+func TestLoopForest(t *testing.T) {
+	pkg := buildLoopsPackage(t)
+
+	if lf := pkg.Func("straight").LoopForest(); len(lf.Roots) != 0 {
+		t.Errorf("straight: got %d loops, want 0", len(lf.Roots))
+	}
+
+	// Two natural loops, one nested within the other.
+	fn := pkg.Func("nested")
+	lf := fn.LoopForest()
+	if len(lf.Roots) != 1 {
+		t.Fatalf("nested: got %d outermost loops, want 1", len(lf.Roots))
+	}
+	outer := lf.Roots[0]
+	if len(outer.Children) != 1 {
+		t.Fatalf("nested: got %d inner loops, want 1", len(outer.Children))
+	}
+	inner := outer.Children[0]
+	for _, l := range []*ssa.Loop{outer, inner} {
+		if l.Irreducible || len(l.Entries) != 1 || l.Header != l.Entries[0] {
+			t.Errorf("%s: want a natural loop with a single entry", l)
+		}
+		for _, b := range l.Blocks {
+			if !l.Header.Dominates(b) {
+				t.Errorf("%s: header does not dominate %s", l, b)
+			}
+		}
+		for _, latch := range l.Latches {
+			if !l.Header.Dominates(latch) {
+				t.Errorf("%s: %s -> %s is not a back edge", l, latch, l.Header)
+			}
+		}
+		if len(l.Exits) != 1 || l.Contains(l.Exits[0]) {
+			t.Errorf("%s: got exits %v, want one block outside the loop", l, l.Exits)
+		}
+	}
+	if outer.Depth != 1 || inner.Depth != 2 || inner.Parent != outer {
+		t.Errorf("nested: wrong nesting: %s, %s", outer, inner)
+	}
+	for _, b := range inner.Blocks {
+		if !outer.Contains(b) {
+			t.Errorf("nested: block %s of inner loop not in outer loop", b)
+		}
+		if lf.Loop(b) != inner || lf.Depth(b) != 2 {
+			t.Errorf("nested: innermost loop of %s is %s", b, lf.Loop(b))
+		}
+	}
+	if lf.Loop(fn.Blocks[0]) != nil || lf.Depth(fn.Blocks[0]) != 0 {
+		t.Errorf("nested: entry block is in a loop")
+	}
+
+	// An irreducible loop with two entries.
+	lf = pkg.Func("irreducible").LoopForest()
+	if len(lf.Roots) != 1 {
+		t.Fatalf("irreducible: got %d loops, want 1", len(lf.Roots))
+	}
+	if l := lf.Roots[0]; !l.Irreducible || len(l.Entries) != 2 {
+		t.Errorf("irreducible: got %s with entries %v, want 2 entries", l, l.Entries)
+	}
+}
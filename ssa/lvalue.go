@@ -4,6 +4,8 @@ package ssa
 // expressions.
 
 import (
+	"go/ast"
+
 	"code.google.com/p/go.tools/go/types"
 )
 
@@ -20,6 +22,7 @@ type lvalue interface {
 // An address is an lvalue represented by a true pointer.
 type address struct {
 	addr Value
+	expr ast.Expr // source syntax of the variable, if it is one (for debug info)
 }
 
 func (a address) load(fn *Function) Value {
@@ -27,7 +30,11 @@ func (a address) load(fn *Function) Value {
 }
 
 func (a address) store(fn *Function, v Value) {
-	emitStore(fn, a.addr, v)
+	store := emitStore(fn, a.addr, v)
+	if a.expr != nil {
+		// The variable now holds the stored value.
+		emitDebugRef(fn, a.expr, store.Val, false)
+	}
 }
 
 func (a address) typ() types.Type {
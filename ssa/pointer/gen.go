@@ -0,0 +1,224 @@
+package pointer
+
+// This file implements the generation of constraints for the
+// instructions of a reachable function.
+
+import (
+	"go/ast"
+	"go/token"
+
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/ssa"
+)
+
+// genFunc generates the constraints for the body of fn.
+func (a *analysis) genFunc(fn *ssa.Function) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			a.genInstr(fn, instr)
+		}
+	}
+}
+
+// copy adds the constraint dst ⊇ src.
+func (a *analysis) copy(dst, src ssa.Value) {
+	a.addCopy(a.valueNode(dst), a.valueNode(src))
+}
+
+// load adds the constraint dst ⊇ *src.
+func (a *analysis) load(dst, src ssa.Value) {
+	a.addComplex(a.valueNode(src), &load{a.valueNode(dst)})
+}
+
+// store adds the constraint *dst ⊇ src.
+func (a *analysis) store(dst, src ssa.Value) {
+	a.addComplex(a.valueNode(dst), &store{a.valueNode(src)})
+}
+
+// alloc adds the constraint v ⊇ {o}, where o is the object allocated
+// by v, and returns the contents node of o.
+func (a *analysis) alloc(v ssa.Value) nodeid {
+	obj := a.objectNode(v)
+	a.addressOf(a.valueNode(v), obj)
+	return obj
+}
+
+// genInstr generates the constraints for instr, an instruction of fn.
+func (a *analysis) genInstr(fn *ssa.Function, instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.Alloc, *ssa.MakeMap, *ssa.MakeChan, *ssa.MakeSlice:
+		a.alloc(instr.(ssa.Value))
+
+	case *ssa.MakeInterface:
+		a.addCopy(a.alloc(instr), a.valueNode(instr.X))
+
+	case *ssa.MakeClosure:
+		a.alloc(instr)
+		closure := instr.Fn.(*ssa.Function)
+		for i, b := range instr.Bindings {
+			a.copy(closure.FreeVars[i], b)
+		}
+
+	case *ssa.Phi:
+		for _, edge := range instr.Edges {
+			a.copy(instr, edge)
+		}
+
+	case *ssa.UnOp:
+		switch instr.Op {
+		case token.MUL, token.ARROW:
+			a.load(instr, instr.X)
+		}
+
+	case *ssa.Store:
+		a.store(instr.Addr, instr.Val)
+
+	case *ssa.Send:
+		a.store(instr.Chan, instr.X)
+
+	case *ssa.MapUpdate:
+		a.store(instr.Map, instr.Key)
+		a.store(instr.Map, instr.Value)
+
+	case *ssa.Lookup:
+		if _, ok := instr.X.Type().Underlying().(*types.Map); ok {
+			a.load(instr, instr.X)
+		}
+
+	case *ssa.Next:
+		if !instr.IsString {
+			a.load(instr, instr.Iter)
+		}
+
+	case *ssa.Select:
+		for _, st := range instr.States {
+			if st.Dir == ast.SEND {
+				a.store(st.Chan, st.Send)
+			} else {
+				a.load(instr, st.Chan)
+			}
+		}
+
+	// Since objects are not split into fields or elements,
+	// the following instructions propagate their operand.
+	case *ssa.FieldAddr:
+		a.copy(instr, instr.X)
+	case *ssa.Field:
+		a.copy(instr, instr.X)
+	case *ssa.IndexAddr:
+		a.copy(instr, instr.X)
+	case *ssa.Index:
+		a.copy(instr, instr.X)
+	case *ssa.Slice:
+		a.copy(instr, instr.X)
+	case *ssa.Range:
+		a.copy(instr, instr.X)
+	case *ssa.Extract:
+		a.copy(instr, instr.Tuple)
+	case *ssa.ChangeType:
+		a.copy(instr, instr.X)
+	case *ssa.ChangeInterface:
+		a.copy(instr, instr.X)
+
+	case *ssa.Convert:
+		a.genConvert(instr)
+
+	case *ssa.TypeAssert:
+		if _, ok := instr.AssertedType.Underlying().(*types.Interface); ok {
+			a.copy(instr, instr.X)
+		} else {
+			a.addComplex(a.valueNode(instr.X), &typeFilter{a.valueNode(instr), instr.AssertedType})
+		}
+
+	case *ssa.Panic:
+		a.addCopy(a.panics, a.valueNode(instr.X))
+
+	case *ssa.Ret:
+		for _, res := range instr.Results {
+			a.addCopy(a.resultNode(fn), a.valueNode(res))
+		}
+
+	case *ssa.Call:
+		a.genCall(fn, instr, &instr.Call, a.valueNode(instr))
+	case *ssa.Go:
+		a.genCall(fn, instr, &instr.Call, 0)
+	case *ssa.Defer:
+		a.genCall(fn, instr, &instr.Call, 0)
+	}
+}
+
+// genConvert generates the constraints for a conversion.
+func (a *analysis) genConvert(conv *ssa.Convert) {
+	from, to := conv.X.Type().Underlying(), conv.Type().Underlying()
+	if _, ok := to.(*types.Slice); ok {
+		if _, ok := from.(*types.Slice); !ok {
+			// string to []byte or []rune: a new array
+			a.alloc(conv)
+			return
+		}
+	}
+	if isUnsafePointer(to) {
+		if _, ok := from.(*types.Basic); ok && !isUnsafePointer(from) {
+			// uintptr to unsafe.Pointer
+			a.warnf(conv.Pos(), "conversion of integer to unsafe.Pointer")
+			a.addCopy(a.valueNode(conv), a.unknown)
+			return
+		}
+	}
+	a.copy(conv, conv.X)
+}
+
+func isUnsafePointer(typ types.Type) bool {
+	b, ok := typ.(*types.Basic)
+	return ok && b.Kind() == types.UnsafePointer
+}
+
+// genCall generates the constraints for the call c at site, an
+// instruction of caller; result is the node of the call's value, or
+// 0 for go and defer.
+func (a *analysis) genCall(caller *ssa.Function, site ssa.Instruction, c *ssa.CallCommon, result nodeid) {
+	var args []nodeid
+	for _, arg := range c.Args {
+		args = append(args, a.valueNode(arg))
+	}
+
+	switch {
+	case c.IsInvoke():
+		a.addComplex(a.valueNode(c.Recv), &invoke{caller, site, c.MethodId(), args, result})
+
+	case c.StaticCallee() != nil:
+		a.call(caller, site, c.StaticCallee(), args, result)
+
+	default:
+		if b, ok := c.Func.(*ssa.Builtin); ok {
+			a.genBuiltin(site, b, args, result)
+			return
+		}
+		a.addComplex(a.valueNode(c.Func), &dynCall{caller, site, args, result})
+	}
+}
+
+// genBuiltin generates the constraints for a call of a built-in
+// function at site.  Only append, copy and recover affect pointers.
+func (a *analysis) genBuiltin(site ssa.Instruction, b *ssa.Builtin, args []nodeid, result nodeid) {
+	switch b.Name() {
+	case "append":
+		// The result is the first argument, or a new array
+		// containing its elements; the elements of the second
+		// argument are added to it.
+		a.addCopy(result, args[0])
+		a.addressOf(result, a.objectNode(site.(ssa.Value)))
+		elems := a.addNode()
+		a.addComplex(args[0], &load{elems})
+		a.addComplex(args[1], &load{elems})
+		a.addComplex(result, &store{elems})
+
+	case "copy":
+		elems := a.addNode()
+		a.addComplex(args[1], &load{elems})
+		a.addComplex(args[0], &store{elems})
+
+	case "recover":
+		a.addCopy(result, a.panics)
+	}
+}
@@ -0,0 +1,184 @@
+// Package pointer implements an inclusion-based (Andersen-style)
+// points-to analysis for programs in SSA form.
+//
+// The analysis answers the question "what may this Value point to?"
+// for values of pointer-like type (pointers, slices, maps, channels,
+// functions and interfaces) and computes a call graph on the fly,
+// considering only the functions reachable from the main and init
+// functions of the program.  Since the callees of dynamic calls are
+// determined by the points-to sets of the function values and
+// interface receivers, the call graph is more precise than those
+// computed by CHA or RTA (see package callgraph).
+//
+// Abstract objects are identified by their allocation sites: the
+// instructions Alloc, MakeMap, MakeChan, MakeSlice, MakeClosure and
+// MakeInterface, conversions of strings to slices, calls of the
+// built-in append function, and the package-level variables and
+// functions of the program.  Each such site is modelled by a single
+// object (the analysis is field-insensitive: the fields of a struct
+// and the elements of an array or slice are not distinguished) and
+// the analysis is flow-insensitive and context-insensitive.
+//
+// The analysis is conservative for code it cannot see: the results of
+// calls of functions without bodies (e.g. those implemented in
+// assembly) and of functions of package reflect, and pointers obtained
+// by converting integers to unsafe.Pointer, may point to an unknown
+// object, which is also reachable from anything passed to such code.
+// Each such case is reported as a Warning.
+package pointer
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/callgraph"
+)
+
+// A Label denotes an abstract object: an allocation site, or the
+// unknown object.
+type Label struct {
+	// Value is the allocation site: an *ssa.Alloc, *ssa.MakeMap,
+	// *ssa.MakeChan, *ssa.MakeSlice, *ssa.MakeClosure,
+	// *ssa.MakeInterface, *ssa.Convert or *ssa.Call (of append),
+	// an *ssa.Global or an *ssa.Function; nil for the unknown object.
+	Value ssa.Value
+}
+
+// Pos returns the position of the allocation site, if known.
+func (l *Label) Pos() token.Pos {
+	if v, ok := l.Value.(interface {
+		Pos() token.Pos
+	}); ok {
+		return v.Pos()
+	}
+	return token.NoPos
+}
+
+func (l *Label) String() string {
+	switch v := l.Value.(type) {
+	case nil:
+		return "unknown"
+	case *ssa.Function, *ssa.Global:
+		return v.String()
+	}
+	return fmt.Sprintf("%s = %s", l.Value.Name(), l.Value)
+}
+
+// A Warning reports a place where the analysis was conservative.
+type Warning struct {
+	Pos     token.Pos
+	Message string
+}
+
+// A Result holds the results of the analysis of a program.
+type Result struct {
+	CallGraph *callgraph.Graph // the call graph of the reachable functions
+	Warnings  []Warning        // in order of discovery
+	a         *analysis
+}
+
+// PointsTo returns the points-to set of v, a value of a reachable
+// function or a package-level value.  The set is empty for values of
+// unreachable functions and for values that are not pointer-like.
+func (r *Result) PointsTo(v ssa.Value) PointsToSet {
+	if id, ok := r.a.values[v]; ok {
+		return PointsToSet{r.a, r.a.nodes[id].pts}
+	}
+	return PointsToSet{r.a, nil}
+}
+
+// A PointsToSet is the set of abstract objects a value may point to.
+type PointsToSet struct {
+	a   *analysis
+	pts nodeset
+}
+
+// Labels returns the labels of the objects in s, ordered by position.
+func (s PointsToSet) Labels() []*Label {
+	labels := make([]*Label, 0, len(s.pts))
+	for obj := range s.pts {
+		labels = append(labels, s.a.nodes[obj].label)
+	}
+	sort.Sort(byPos(labels))
+	return labels
+}
+
+// Unknown reports whether s contains the unknown object, i.e. whether
+// the value may point to objects the analysis does not know about.
+func (s PointsToSet) Unknown() bool {
+	return s.pts[s.a.unknown]
+}
+
+// MayAlias reports whether s and t may contain a common object.
+func (s PointsToSet) MayAlias(t PointsToSet) bool {
+	if s.Unknown() && len(t.pts) > 0 || t.Unknown() && len(s.pts) > 0 {
+		return true
+	}
+	for obj := range s.pts {
+		if t.pts[obj] {
+			return true
+		}
+	}
+	return false
+}
+
+// DynamicTypes returns the dynamic types of the interface values that
+// s, the points-to set of a value of interface type, may point to.
+// The result is incomplete if s.Unknown().
+func (s PointsToSet) DynamicTypes() []types.Type {
+	var result []types.Type
+	for _, l := range s.Labels() {
+		if mi, ok := l.Value.(*ssa.MakeInterface); ok {
+			typ := mi.X.Type()
+			dup := false
+			for _, t := range result {
+				if types.IsIdentical(t, typ) {
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				result = append(result, typ)
+			}
+		}
+	}
+	return result
+}
+
+type byPos []*Label
+
+func (p byPos) Len() int      { return len(p) }
+func (p byPos) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byPos) Less(i, j int) bool {
+	if x, y := p[i].Pos(), p[j].Pos(); x != y {
+		return x < y
+	}
+	return p[i].String() < p[j].String()
+}
+
+// Analyze performs the points-to analysis of prog, whose packages
+// must have been built.  The roots of the analysis are the init
+// functions of all packages and the main function of the main
+// package(s).
+func Analyze(prog *ssa.Program) *Result {
+	a := newAnalysis(prog)
+	for _, pkg := range prog.Packages {
+		if pkg.Init != nil {
+			a.addRoot(pkg.Init)
+		}
+		if pkg.Types.Name() == "main" {
+			if main, ok := pkg.Members["main"].(*ssa.Function); ok {
+				a.addRoot(main)
+			}
+		}
+	}
+	a.solve()
+	return &Result{
+		CallGraph: a.cg,
+		Warnings:  a.warnings,
+		a:         a,
+	}
+}
@@ -0,0 +1,173 @@
+package pointer_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"sort"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/pointer"
+)
+
+const src = `package main
+
+type I interface{ f() *int }
+
+type A struct{ p *int }
+
+func (a A) f() *int { return a.p }
+
+type B struct{}
+
+func (B) f() *int { return nil }
+
+var x, y int
+
+func id(p *int) *int { return p }
+
+var g = id
+
+func main() {
+	p := id(&x)
+	q := id(&y)
+	var i I = A{&x}
+	r := i.f()
+	s := g(&y)
+	print(p, q, r, s)
+	w := []*int{r, s}
+	print(w[1])
+	_ = B{}
+}
+`
+
+func analyze(t *testing.T) (*pointer.Result, *ssa.Function) {
+	b := ssa.NewBuilder(&ssa.Context{Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", src, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pointer.Analyze(b.Prog), pkg.Func("main")
+}
+
+// calls returns the call instructions of fn, in order.
+func calls(fn *ssa.Function) []*ssa.Call {
+	var calls []*ssa.Call
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(*ssa.Call); ok {
+				calls = append(calls, call)
+			}
+		}
+	}
+	return calls
+}
+
+func labels(s pointer.PointsToSet) []string {
+	var names []string
+	for _, l := range s.Labels() {
+		names = append(names, l.String())
+	}
+	return names
+}
+
+// printArgs returns the operands of a call of the built-in print.
+// The builder passes all but the first in an array of interfaces, so
+// they are found via the MakeInterface stored in each element.
+func printArgs(call *ssa.Call) []ssa.Value {
+	args := call.Call.Args
+	if len(args) != 2 {
+		return args
+	}
+	slice, ok := args[1].(*ssa.Slice)
+	if !ok {
+		return args
+	}
+	result := []ssa.Value{args[0]}
+	for _, ref := range *slice.X.Referrers() {
+		addr, ok := ref.(*ssa.IndexAddr)
+		if !ok {
+			continue
+		}
+		for _, ref := range *addr.Referrers() {
+			if st, ok := ref.(*ssa.Store); ok {
+				if mi, ok := st.Val.(*ssa.MakeInterface); ok {
+					result = append(result, mi.X)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func TestPointsTo(t *testing.T) {
+	res, main := analyze(t)
+
+	var printCalls []*ssa.Call
+	for _, call := range calls(main) {
+		if b, ok := call.Call.Func.(*ssa.Builtin); ok && b.Name() == "print" {
+			printCalls = append(printCalls, call)
+		}
+	}
+	if len(printCalls) != 2 {
+		t.Fatalf("got %d calls of print in main, want 2", len(printCalls))
+	}
+	printCall := printCalls[0]
+
+	// id is analyzed context-insensitively.
+	args := printArgs(printCall)
+	wants := [][]string{
+		{"main.x", "main.y"}, // p
+		{"main.x", "main.y"}, // q
+		{"main.x"},           // r
+		{"main.x", "main.y"}, // s
+	}
+	if len(args) != len(wants) {
+		t.Fatalf("print has %d arguments, want %d", len(args), len(wants))
+	}
+	for i, want := range wants {
+		pts := res.PointsTo(args[i])
+		if got := labels(pts); !reflect.DeepEqual(got, want) {
+			t.Errorf("print argument %d: got points-to set %v; want %v", i, got, want)
+		}
+		if pts.Unknown() {
+			t.Errorf("print argument %d: unexpected unknown object", i)
+		}
+	}
+
+	// The elements of a slice are not distinguished, so w[1] may
+	// be either of the stored values r or s.
+	elem := printCalls[1].Call.Args[0]
+	if got, want := labels(res.PointsTo(elem)), []string{"main.x", "main.y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("w[1]: got points-to set %v; want %v", got, want)
+	}
+
+	if len(res.Warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+}
+
+func TestCallGraph(t *testing.T) {
+	res, main := analyze(t)
+
+	var got []string
+	for _, call := range calls(main) {
+		if call.Call.IsInvoke() || call.Call.StaticCallee() == nil {
+			for _, fn := range res.CallGraph.Callees(call) {
+				got = append(got, fn.FullName())
+			}
+		}
+	}
+	sort.Strings(got)
+	// Unlike CHA, the analysis knows that i holds only an A.
+	want := []string{"(main.A).f", "main.id"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("callees of dynamic calls: got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,365 @@
+package pointer
+
+// This file defines the constraint graph and the solver.
+//
+// Each node of the constraint graph is either a variable (an SSA value,
+// or the result of a function) or the contents of an abstract object.
+// The points-to set of a node is the set of objects it may point to;
+// an object is represented by (the id of) its contents node.
+//
+// Simple constraints dst ⊇ src are edges of the graph, along which
+// points-to sets are propagated.  Complex constraints (loads, stores,
+// dynamic calls, type assertions) are attached to a node and add new
+// edges for each object in its points-to set.  The solver propagates
+// only the changes of each node's points-to set (difference
+// propagation) until a fixed point is reached; functions are
+// processed as they become reachable.
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+
+	"code.google.com/p/go.tools/go/types"
+	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/callgraph"
+)
+
+// A nodeid identifies a node of the constraint graph.
+// Node 0 is unused; it denotes "no node".
+type nodeid int
+
+type nodeset map[nodeid]bool
+
+type node struct {
+	pts     nodeset      // points-to set
+	prev    nodeset      // subset of pts already propagated
+	copyTo  nodeset      // successors: nodes whose pts include this one's
+	complex []constraint // complex constraints with this node as operand
+	label   *Label       // for the contents node of an object, its label
+	queued  bool         // node is on the work list
+}
+
+// A constraint is a complex constraint, solved anew for each object in
+// the points-to set of the node to which it is attached.
+type constraint interface {
+	solve(a *analysis, obj nodeid)
+}
+
+type analysis struct {
+	prog     *ssa.Program
+	nodes    []*node
+	values   map[ssa.Value]nodeid     // nodes of SSA values
+	objects  map[ssa.Value]nodeid     // contents nodes of allocation sites
+	results  map[*ssa.Function]nodeid // nodes of function results
+	cg       *callgraph.Graph         // the call graph computed so far
+	genq     []*ssa.Function          // reachable functions not yet processed
+	work     []nodeid                 // nodes whose pts have changed
+	unknown  nodeid                   // contents of the unknown object
+	panics   nodeid                   // the values passed to panic
+	warnings []Warning                // warnings in order of discovery
+	warned   map[Warning]bool         // set of elements of warnings
+}
+
+func newAnalysis(prog *ssa.Program) *analysis {
+	a := &analysis{
+		prog:    prog,
+		nodes:   []*node{nil},
+		values:  make(map[ssa.Value]nodeid),
+		objects: make(map[ssa.Value]nodeid),
+		results: make(map[*ssa.Function]nodeid),
+		cg:      callgraph.New(prog),
+		warned:  make(map[Warning]bool),
+	}
+	a.unknown = a.addNode()
+	a.nodes[a.unknown].label = &Label{}
+	a.addressOf(a.unknown, a.unknown)
+	a.panics = a.addNode()
+	return a
+}
+
+func (a *analysis) warnf(pos token.Pos, format string, args ...interface{}) {
+	w := Warning{pos, fmt.Sprintf(format, args...)}
+	if !a.warned[w] {
+		a.warned[w] = true
+		a.warnings = append(a.warnings, w)
+	}
+}
+
+// addNode returns a new node.
+func (a *analysis) addNode() nodeid {
+	id := nodeid(len(a.nodes))
+	a.nodes = append(a.nodes, &node{pts: make(nodeset), prev: make(nodeset), copyTo: make(nodeset)})
+	return id
+}
+
+// valueNode returns the node of the SSA value v.
+func (a *analysis) valueNode(v ssa.Value) nodeid {
+	id, ok := a.values[v]
+	if !ok {
+		id = a.addNode()
+		a.values[v] = id
+		switch v.(type) {
+		case *ssa.Global, *ssa.Function:
+			// The value of a global is the address of the
+			// variable; the value of a function is the function.
+			a.addressOf(id, a.objectNode(v))
+		}
+	}
+	return id
+}
+
+// objectNode returns the contents node of the object allocated at site.
+func (a *analysis) objectNode(site ssa.Value) nodeid {
+	id, ok := a.objects[site]
+	if !ok {
+		id = a.addNode()
+		a.nodes[id].label = &Label{site}
+		a.objects[site] = id
+	}
+	return id
+}
+
+// resultNode returns the node of the results of fn.
+func (a *analysis) resultNode(fn *ssa.Function) nodeid {
+	id, ok := a.results[fn]
+	if !ok {
+		id = a.addNode()
+		a.results[fn] = id
+	}
+	return id
+}
+
+// schedule adds id to the work list.
+func (a *analysis) schedule(id nodeid) {
+	if n := a.nodes[id]; !n.queued {
+		n.queued = true
+		a.work = append(a.work, id)
+	}
+}
+
+// addressOf adds the constraint p ⊇ {obj}.
+func (a *analysis) addressOf(p, obj nodeid) {
+	if n := a.nodes[p]; !n.pts[obj] {
+		n.pts[obj] = true
+		a.schedule(p)
+	}
+}
+
+// addCopy adds the constraint dst ⊇ src.
+func (a *analysis) addCopy(dst, src nodeid) {
+	if dst == src || dst == 0 || src == 0 {
+		return
+	}
+	n := a.nodes[src]
+	if n.copyTo[dst] {
+		return
+	}
+	n.copyTo[dst] = true
+	a.addAll(dst, n.pts)
+}
+
+// addAll adds the objects of pts to the points-to set of dst.
+func (a *analysis) addAll(dst nodeid, pts nodeset) {
+	n := a.nodes[dst]
+	changed := false
+	for obj := range pts {
+		if !n.pts[obj] {
+			n.pts[obj] = true
+			changed = true
+		}
+	}
+	if changed {
+		a.schedule(dst)
+	}
+}
+
+// addComplex attaches the complex constraint c to node id.
+func (a *analysis) addComplex(id nodeid, c constraint) {
+	n := a.nodes[id]
+	n.complex = append(n.complex, c)
+	for _, obj := range n.sortedPrev() {
+		c.solve(a, obj)
+	}
+}
+
+// sortedPrev returns the objects already propagated from n, in a
+// deterministic order; the others are handled by the solver.
+func (n *node) sortedPrev() []nodeid {
+	objs := make([]nodeid, 0, len(n.prev))
+	for obj := range n.prev {
+		objs = append(objs, obj)
+	}
+	sortNodes(objs)
+	return objs
+}
+
+func sortNodes(ids []nodeid) {
+	sort.Sort(nodeids(ids))
+}
+
+type nodeids []nodeid
+
+func (p nodeids) Len() int           { return len(p) }
+func (p nodeids) Less(i, j int) bool { return p[i] < p[j] }
+func (p nodeids) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// addRoot adds fn as a root of the call graph.
+func (a *analysis) addRoot(fn *ssa.Function) {
+	a.cg.Roots = append(a.cg.Roots, a.cg.CreateNode(fn))
+	a.reach(fn)
+}
+
+// reach marks fn as reachable.
+func (a *analysis) reach(fn *ssa.Function) {
+	if _, ok := a.results[fn]; !ok {
+		a.resultNode(fn)
+		a.genq = append(a.genq, fn)
+	}
+}
+
+// solve runs the solver to a fixed point.
+func (a *analysis) solve() {
+	for {
+		if len(a.genq) > 0 {
+			fn := a.genq[0]
+			a.genq = a.genq[1:]
+			a.genFunc(fn)
+			continue
+		}
+		if len(a.work) == 0 {
+			break
+		}
+		id := a.work[0]
+		a.work = a.work[1:]
+		n := a.nodes[id]
+		n.queued = false
+
+		var delta []nodeid
+		deltaSet := make(nodeset)
+		for obj := range n.pts {
+			if !n.prev[obj] {
+				delta = append(delta, obj)
+				deltaSet[obj] = true
+				n.prev[obj] = true
+			}
+		}
+		sortNodes(delta)
+		for i := 0; i < len(n.complex); i++ { // n.complex may grow
+			for _, obj := range delta {
+				n.complex[i].solve(a, obj)
+			}
+		}
+		for succ := range n.copyTo {
+			a.addAll(succ, deltaSet)
+		}
+	}
+}
+
+// call adds the constraints for a call from caller at site to callee
+// with the argument nodes args (including the receiver, if any) and
+// the result node result (0 if the results are unused).
+func (a *analysis) call(caller *ssa.Function, site ssa.Instruction, callee *ssa.Function, args []nodeid, result nodeid) {
+	a.cg.AddEdge(a.cg.CreateNode(caller), site, callee)
+	if isOpaque(callee) {
+		a.warnf(site.Pos(), "call to %s is not analyzed", callee.FullName())
+		for _, arg := range args {
+			a.addCopy(a.unknown, arg) // arguments escape
+		}
+		a.addCopy(result, a.unknown)
+		return
+	}
+	a.reach(callee)
+	for i, arg := range args {
+		if i < len(callee.Params) {
+			a.addCopy(a.valueNode(callee.Params[i]), arg)
+		}
+	}
+	a.addCopy(result, a.resultNode(callee))
+}
+
+// isOpaque reports whether the body of fn is not analyzed.
+func isOpaque(fn *ssa.Function) bool {
+	return fn.Blocks == nil || fn.Pkg != nil && fn.Pkg.Types.Path() == "reflect"
+}
+
+// load is the constraint dst ⊇ *src, attached to src.
+type load struct {
+	dst nodeid
+}
+
+func (c *load) solve(a *analysis, obj nodeid) {
+	a.addCopy(c.dst, obj)
+}
+
+// store is the constraint *dst ⊇ src, attached to dst.
+type store struct {
+	src nodeid
+}
+
+func (c *store) solve(a *analysis, obj nodeid) {
+	a.addCopy(obj, c.src)
+}
+
+// typeFilter is the constraint dst ⊇ *src for the interface values
+// in src whose dynamic type is typ, attached to src; it models the
+// type assertion src.(typ) for a non-interface type typ.
+type typeFilter struct {
+	dst nodeid
+	typ types.Type
+}
+
+func (c *typeFilter) solve(a *analysis, obj nodeid) {
+	switch v := a.nodes[obj].label.Value.(type) {
+	case nil:
+		a.addCopy(c.dst, obj)
+	case *ssa.MakeInterface:
+		if types.IsIdentical(v.X.Type(), c.typ) {
+			a.addCopy(c.dst, obj)
+		}
+	}
+}
+
+// invoke is the constraint for an "invoke"-mode call, attached to the
+// receiver.  The receiver argument of the callee is the contents of
+// the interface value.
+type invoke struct {
+	caller *ssa.Function
+	site   ssa.Instruction
+	id     ssa.Id
+	args   []nodeid // excluding the receiver
+	result nodeid
+}
+
+func (c *invoke) solve(a *analysis, obj nodeid) {
+	switch v := a.nodes[obj].label.Value.(type) {
+	case nil:
+		a.warnf(c.site.Pos(), "callees of dynamic method call are unknown")
+		a.addCopy(c.result, a.unknown)
+	case *ssa.MakeInterface:
+		if fn := v.Methods[c.id]; fn != nil {
+			a.call(c.caller, c.site, fn, append([]nodeid{obj}, c.args...), c.result)
+		}
+	}
+}
+
+// dynCall is the constraint for a call of a function value, attached
+// to the function value.
+type dynCall struct {
+	caller *ssa.Function
+	site   ssa.Instruction
+	args   []nodeid
+	result nodeid
+}
+
+func (c *dynCall) solve(a *analysis, obj nodeid) {
+	switch v := a.nodes[obj].label.Value.(type) {
+	case nil:
+		a.warnf(c.site.Pos(), "callees of dynamic function call are unknown")
+		a.addCopy(c.result, a.unknown)
+	case *ssa.Function:
+		a.call(c.caller, c.site, v, c.args, c.result)
+	case *ssa.MakeClosure:
+		a.call(c.caller, c.site, v.Fn.(*ssa.Function), c.args, c.result)
+	}
+}
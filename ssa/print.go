@@ -355,6 +355,15 @@ func (s *MapUpdate) String() string {
 	return fmt.Sprintf("%s[%s] = %s", relName(s.Map, s), relName(s.Key, s), relName(s.Value, s))
 }
 
+func (s *DebugRef) String() string {
+	p := s.Block().Func.Prog.Files.Position(s.Pos())
+	addr := ""
+	if s.IsAddr {
+		addr = "address of "
+	}
+	return fmt.Sprintf("; %s%T @ %d:%d is %s", addr, s.Expr, p.Line, p.Column, relName(s.X, s))
+}
+
 func (p *Package) String() string {
 	return "Package " + p.Types.Path()
 }
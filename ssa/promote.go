@@ -369,6 +369,8 @@ func makeImethodThunk(prog *Program, typ types.Type, id Id) *Function {
 		Name_:     meth.Name(),
 		Signature: &sig,
 		Prog:      prog,
+		thunkType: typ,
+		thunkId:   id,
 	}
 	fn.startBody()
 	fn.addParam("recv", typ)
@@ -404,18 +406,19 @@ func makeImethodThunk(prog *Program, typ types.Type, id Id) *Function {
 //
 // TODO(adonovan): memoize creation of these functions in the Program.
 //
-func makeBoundMethodThunk(prog *Program, meth *Function, recv Value) *Function {
+func makeBoundMethodThunk(prog *Program, meth *Function) *Function {
 	if prog.mode&LogSource != 0 {
 		defer logStack("makeBoundMethodThunk %s", meth)()
 	}
 	s := meth.Signature
 	fn := &Function{
-		Name_:     "bound$" + meth.FullName(),
-		Signature: types.NewSignature(nil, s.Params(), s.Results(), s.IsVariadic()), // drop recv
-		Prog:      prog,
+		Name_:       "bound$" + meth.FullName(),
+		Signature:   types.NewSignature(nil, s.Params(), s.Results(), s.IsVariadic()), // drop recv
+		Prog:        prog,
+		thunkMethod: meth,
 	}
 
-	cap := &Capture{Name_: "recv", Type_: recv.Type()}
+	cap := &Capture{Name_: "recv", Type_: s.Recv().Type()}
 	fn.FreeVars = []*Capture{cap}
 	fn.startBody()
 	createParams(fn)
@@ -1,7 +1,9 @@
 package ssa
 
 // An optional pass for sanity-checking invariants of the SSA representation.
-// Currently it checks CFG invariants but little at the instruction level.
+// It checks CFG invariants, the duality of Operands and Referrers, and
+// that each value is defined before (i.e. dominates) each of its uses,
+// but little else at the instruction level.
 
 import (
 	"fmt"
@@ -13,6 +15,7 @@ type sanity struct {
 	reporter io.Writer
 	fn       *Function
 	block    *BasicBlock
+	index    map[Instruction]int // index of each instruction of fn within its block
 	insane   bool
 }
 
@@ -125,6 +128,7 @@ func (s *sanity) checkInstr(idx int, instr Instruction) {
 	case *ChangeInterface:
 	case *ChangeType:
 	case *Convert:
+	case *DebugRef:
 	case *Defer:
 	case *Extract:
 	case *Field:
@@ -247,10 +251,6 @@ func (s *sanity) checkBlock(b *BasicBlock, index int) {
 	}
 
 	// Check each instruction is sane.
-	// TODO(adonovan): check Instruction invariants:
-	// - check Operands is dual to Value.Referrers.
-	// - check all Operands that are also Instructions belong to s.fn too
-	//   (and for bonus marks, that their block dominates block b).
 	n := len(b.Instrs)
 	if n == 0 {
 		s.errorf("basic block contains no instructions")
@@ -272,7 +272,105 @@ func (s *sanity) checkBlock(b *BasicBlock, index int) {
 		} else {
 			s.checkFinalInstr(j, instr)
 		}
+		s.checkOperands(instr)
+		if v, ok := instr.(Value); ok {
+			s.checkReferrers(v)
+		}
+	}
+}
+
+// checkOperands checks that instr is among the Referrers of each of
+// its operands, and that each operand local to s.fn is defined before
+// instr on every path, i.e. its definition dominates instr.
+func (s *sanity) checkOperands(instr Instruction) {
+	for i, rand := range instr.Operands(nil) {
+		v := *rand
+		if v == nil {
+			continue
+		}
+		if refs := v.Referrers(); refs != nil {
+			found := false
+			for _, ref := range *refs {
+				if ref == instr {
+					found = true
+					break
+				}
+			}
+			if !found {
+				s.errorf("instruction %s is not among the referrers of its operand %s", instr, v.Name())
+			}
+		}
+
+		switch v := v.(type) {
+		case *Parameter:
+			if !containsParam(s.fn.Params, v) {
+				s.errorf("operand %s of %s is not a parameter of this function", v.Name(), instr)
+			}
+		case *Capture:
+			if !containsCapture(s.fn.FreeVars, v) {
+				s.errorf("operand %s of %s is not a free variable of this function", v.Name(), instr)
+			}
+		case Instruction:
+			name := v.(Value).Name()
+			def, ok := s.index[v]
+			if !ok {
+				s.errorf("operand %s of %s is not an instruction of this function", name, instr)
+				continue
+			}
+			// The operands of a φ-node are used at the end
+			// of the corresponding predecessor.
+			useBlock := s.block
+			if _, ok := instr.(*Phi); ok {
+				if i >= len(s.block.Preds) {
+					continue // already reported
+				}
+				useBlock = s.block.Preds[i]
+			}
+			defBlock := v.Block()
+			if defBlock == useBlock {
+				if _, ok := instr.(*Phi); !ok && def >= s.index[instr] {
+					s.errorf("operand %s of %s is defined after its use", name, instr)
+				}
+			} else if defBlock.dom != nil && useBlock.dom != nil && !defBlock.Dominates(useBlock) {
+				s.errorf("definition of operand %s of %s in %s does not dominate its use in %s",
+					name, instr, defBlock, useBlock)
+			}
+		}
+	}
+}
+
+// checkReferrers checks that each referrer of v is an instruction of
+// s.fn that has v as an operand.
+func (s *sanity) checkReferrers(v Value) {
+	refs := v.Referrers()
+	if refs == nil {
+		return
+	}
+	for _, ref := range *refs {
+		if _, ok := s.index[ref]; !ok {
+			s.errorf("referrer %s of %s is not an instruction of this function", ref, v.Name())
+		} else if !uses(ref, v) {
+			s.errorf("referrer %s of %s does not use it", ref, v.Name())
+		}
+	}
+}
+
+func containsParam(params []*Parameter, p *Parameter) bool {
+	for _, x := range params {
+		if x == p {
+			return true
+		}
 	}
+	return false
+}
+
+func containsCapture(fvs []*Capture, c *Capture) bool {
+	for _, x := range fvs {
+		if x == c {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *sanity) checkFunction(fn *Function) bool {
@@ -295,6 +393,22 @@ func (s *sanity) checkFunction(fn *Function) bool {
 		// they were "optimized" away, even the entry block.
 		s.errorf("Blocks slice is non-nil but empty")
 	}
+	s.index = make(map[Instruction]int)
+	for _, b := range fn.Blocks {
+		if b != nil {
+			for j, instr := range b.Instrs {
+				if instr != nil {
+					s.index[instr] = j
+				}
+			}
+		}
+	}
+	for _, p := range fn.Params {
+		s.checkReferrers(p)
+	}
+	for _, fv := range fn.FreeVars {
+		s.checkReferrers(fv)
+	}
 	for i, b := range fn.Blocks {
 		if b == nil {
 			s.warnf("nil *BasicBlock at f.Blocks[%d]", i)
@@ -304,5 +418,6 @@ func (s *sanity) checkFunction(fn *Function) bool {
 	}
 	s.block = nil
 	s.fn = nil
+	s.index = nil
 	return !s.insane
 }
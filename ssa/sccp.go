@@ -0,0 +1,472 @@
+package ssa
+
+// This file defines the sparse conditional constant propagation pass,
+// enabled by the ConstantPropagation builder mode.
+//
+// Cited papers and resources:
+//
+// Mark N. Wegman and F. Kenneth Zadeck. 1991.  Constant propagation
+// with conditional branches.  ACM TOPLAS 13(2):181-210.
+// http://doi.acm.org/10.1145/103135.103136
+//
+// The pass computes, for each register of a function, whether it has
+// the same constant value in every execution, assuming that only the
+// CFG edges found to be executable are taken.  It then replaces such
+// registers by Literals, replaces If instructions with constant
+// conditions by Jumps, and deletes the blocks that are no longer
+// reachable.
+//
+// Only BinOp, UnOp, Convert and Phi instructions over basic types are
+// evaluated.  Operations whose result depends on the target
+// architecture (e.g. overflow of int) or that would panic at run time
+// (e.g. division by zero) are not folded.
+
+import (
+	"go/token"
+
+	"code.google.com/p/go.tools/go/exact"
+	"code.google.com/p/go.tools/go/types"
+)
+
+// A lattice value is the state of a register during the analysis: nil
+// (not yet known to be defined), a *Literal (a constant), or overdefined.
+type lattice interface{}
+
+// overdefined is the lattice value of registers that are not constant.
+var overdefined lattice = new(int)
+
+type edge struct {
+	from, to *BasicBlock
+}
+
+type sccpState struct {
+	fn         *Function
+	values     map[Value]lattice
+	execBlocks []bool        // executable blocks, by Block.Index
+	execEdges  map[edge]bool // executable edges
+	blockWork  []edge        // edges newly found to be executable
+	instrWork  []Instruction // instructions whose operands have changed
+}
+
+// propagateConstants applies sparse conditional constant propagation
+// to f, whose Referrers must be up-to-date.  It reports whether the
+// CFG was changed.
+func propagateConstants(f *Function) bool {
+	s := &sccpState{
+		fn:         f,
+		values:     make(map[Value]lattice),
+		execBlocks: make([]bool, len(f.Blocks)),
+		execEdges:  make(map[edge]bool),
+	}
+	s.blockWork = append(s.blockWork, edge{nil, f.Blocks[0]})
+	for len(s.blockWork) > 0 || len(s.instrWork) > 0 {
+		for len(s.blockWork) > 0 {
+			e := s.blockWork[len(s.blockWork)-1]
+			s.blockWork = s.blockWork[:len(s.blockWork)-1]
+			s.visitEdge(e)
+		}
+		for len(s.instrWork) > 0 {
+			instr := s.instrWork[len(s.instrWork)-1]
+			s.instrWork = s.instrWork[:len(s.instrWork)-1]
+			if s.execBlocks[instr.Block().Index] {
+				s.visitInstr(instr)
+			}
+		}
+	}
+	return s.rewrite()
+}
+
+// visitEdge marks edge e as executable, and visits its target.
+func (s *sccpState) visitEdge(e edge) {
+	b := e.to
+	if s.execBlocks[b.Index] {
+		// Only the φ-nodes are affected by a new edge.
+		for _, instr := range b.phis() {
+			s.visitInstr(instr)
+		}
+		return
+	}
+	s.execBlocks[b.Index] = true
+	for _, instr := range b.Instrs {
+		s.visitInstr(instr)
+	}
+}
+
+// markEdge records that the edge from b to its successor succ is
+// executable.
+func (s *sccpState) markEdge(b, succ *BasicBlock) {
+	e := edge{b, succ}
+	if !s.execEdges[e] {
+		s.execEdges[e] = true
+		s.blockWork = append(s.blockWork, e)
+	}
+}
+
+// value returns the current lattice value of v.
+func (s *sccpState) value(v Value) lattice {
+	switch v := v.(type) {
+	case *Literal:
+		return v
+	case *Phi, *BinOp, *UnOp, *Convert:
+		return s.values[v]
+	}
+	return overdefined
+}
+
+// setValue updates the lattice value of v, which may only descend.
+func (s *sccpState) setValue(v Value, x lattice) {
+	old := s.values[v]
+	if old == x || old == overdefined {
+		return
+	}
+	if old, ok := old.(*Literal); ok {
+		if x, ok := x.(*Literal); ok && sameLiteral(old, x) {
+			return
+		}
+	}
+	s.values[v] = x
+	s.instrWork = append(s.instrWork, *v.Referrers()...)
+}
+
+func (s *sccpState) visitInstr(instr Instruction) {
+	switch instr := instr.(type) {
+	case *Phi:
+		var x lattice
+		for i, pred := range instr.Block().Preds {
+			if s.execEdges[edge{pred, instr.Block()}] {
+				x = meet(x, s.value(instr.Edges[i]))
+			}
+		}
+		if x != nil {
+			s.setValue(instr, x)
+		}
+
+	case *BinOp:
+		x, y := s.value(instr.X), s.value(instr.Y)
+		if x == overdefined || y == overdefined {
+			s.setValue(instr, overdefined)
+		} else if x != nil && y != nil {
+			s.setValue(instr, foldBinOp(instr, x.(*Literal), y.(*Literal)))
+		}
+
+	case *UnOp:
+		x := s.value(instr.X)
+		if x == overdefined || instr.Op == token.MUL || instr.Op == token.ARROW {
+			s.setValue(instr, overdefined)
+		} else if x != nil {
+			s.setValue(instr, foldUnOp(instr, x.(*Literal)))
+		}
+
+	case *Convert:
+		x := s.value(instr.X)
+		if x == overdefined {
+			s.setValue(instr, overdefined)
+		} else if x != nil {
+			s.setValue(instr, foldConvert(instr, x.(*Literal)))
+		}
+
+	case *If:
+		b := instr.Block()
+		switch cond := s.value(instr.Cond).(type) {
+		case nil:
+			// not yet known
+		case *Literal:
+			if exact.BoolVal(cond.Value) {
+				s.markEdge(b, b.Succs[0])
+			} else {
+				s.markEdge(b, b.Succs[1])
+			}
+		default:
+			s.markEdge(b, b.Succs[0])
+			s.markEdge(b, b.Succs[1])
+		}
+
+	default:
+		// Other instructions yield non-constant values,
+		// and transfer control to all their successors.
+		if v, ok := instr.(Value); ok {
+			s.setValue(v, overdefined)
+		}
+		if b := instr.Block(); instr == b.Instrs[len(b.Instrs)-1] {
+			for _, succ := range b.Succs {
+				s.markEdge(b, succ)
+			}
+		}
+	}
+}
+
+// meet returns the greatest lower bound of lattice values x and y.
+func meet(x, y lattice) lattice {
+	switch {
+	case x == nil:
+		return y
+	case y == nil:
+		return x
+	case x == overdefined || y == overdefined:
+		return overdefined
+	}
+	if x, y := x.(*Literal), y.(*Literal); x == y || sameLiteral(x, y) {
+		return x
+	}
+	return overdefined
+}
+
+// sameLiteral reports whether x and y are literals of the same type
+// and value.
+func sameLiteral(x, y *Literal) bool {
+	if !types.IsIdentical(x.Type(), y.Type()) {
+		return false
+	}
+	if x.Value.Kind() == exact.Nil || y.Value.Kind() == exact.Nil {
+		return x.Value.Kind() == y.Value.Kind()
+	}
+	return exact.Compare(x.Value, token.EQL, y.Value)
+}
+
+// basicType returns the underlying basic type of t, if it is one
+// whose values are represented exactly by literals.
+func basicType(t types.Type) *types.Basic {
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		info := b.Info()
+		if info&(types.IsBoolean|types.IsInteger|types.IsFloat|types.IsString) != 0 && info&types.IsUntyped == 0 {
+			return b
+		}
+	}
+	return nil
+}
+
+// intSize returns the size in bytes of integer type t, or zero if it
+// depends on the target architecture.
+func intSize(t *types.Basic) int {
+	switch t.Kind() {
+	case types.Int8, types.Uint8:
+		return 1
+	case types.Int16, types.Uint16:
+		return 2
+	case types.Int32, types.Uint32:
+		return 4
+	case types.Int64, types.Uint64:
+		return 8
+	}
+	return 0 // int, uint, uintptr
+}
+
+// fit returns the literal of type typ whose value is x, an exact
+// result of an operation, wrapped or rounded as by the operation at run
+// time; it returns overdefined if the value depends on the target
+// architecture or is not representable.
+func fit(x exact.Value, typ types.Type) lattice {
+	t := basicType(typ)
+	if t == nil || x.Kind() == exact.Unknown {
+		return overdefined
+	}
+	info := t.Info()
+	switch {
+	case info&types.IsInteger != 0:
+		if x.Kind() != exact.Int {
+			return overdefined
+		}
+		signed := info&types.IsUnsigned == 0
+		if size := intSize(t); size > 0 {
+			x, _ = exact.ConvertInt(x, size, signed)
+		} else if _, ok := exact.ConvertInt(x, 4, signed); !ok {
+			return overdefined // result would depend on the size of int
+		}
+	case info&types.IsFloat != 0:
+		if t.Kind() == types.Float32 {
+			x = exact.RoundFloat32(x)
+		} else {
+			x = exact.RoundFloat64(x)
+		}
+		if x.Kind() == exact.Unknown {
+			return overdefined // overflow
+		}
+	case info&types.IsBoolean != 0:
+		if x.Kind() != exact.Bool {
+			return overdefined
+		}
+	case info&types.IsString != 0:
+		if x.Kind() != exact.String {
+			return overdefined
+		}
+	}
+	return newLiteral(x, typ)
+}
+
+func foldBinOp(instr *BinOp, x, y *Literal) lattice {
+	t := basicType(x.Type())
+	if t == nil || basicType(y.Type()) == nil {
+		return overdefined
+	}
+	op := instr.Op
+	switch op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return newLiteral(exact.MakeBool(exact.Compare(x.Value, op, y.Value)), instr.Type())
+
+	case token.SHL, token.SHR:
+		s, ok := exact.Uint64Val(y.Value)
+		if !ok || s > 64 || t.Info()&types.IsInteger == 0 {
+			return overdefined
+		}
+		return fit(exact.Shift(x.Value, op, uint(s)), instr.Type())
+
+	case token.QUO, token.REM:
+		if exact.Sign(y.Value) == 0 {
+			return overdefined // division by zero
+		}
+		if t.Info()&types.IsInteger != 0 {
+			if op == token.QUO {
+				op = token.QUO_ASSIGN // integer division
+			}
+		} else if op == token.REM {
+			return overdefined
+		}
+
+	case token.AND, token.OR, token.XOR, token.AND_NOT:
+		// The result of a bitwise operation on operands
+		// representable in a type is also representable.
+		if t.Info()&types.IsInteger == 0 {
+			return overdefined
+		}
+
+	case token.ADD, token.SUB, token.MUL:
+		// ok
+
+	default:
+		return overdefined
+	}
+	return fit(exact.BinaryOp(x.Value, op, y.Value), instr.Type())
+}
+
+func foldUnOp(instr *UnOp, x *Literal) lattice {
+	t := basicType(x.Type())
+	if t == nil {
+		return overdefined
+	}
+	size := -1
+	if instr.Op == token.XOR && t.Info()&types.IsUnsigned != 0 {
+		if size = intSize(t); size == 0 {
+			return overdefined // result would depend on the size of uint
+		}
+	}
+	switch instr.Op {
+	case token.NOT, token.SUB, token.XOR:
+		return fit(exact.UnaryOp(instr.Op, x.Value, size), instr.Type())
+	}
+	return overdefined
+}
+
+func foldConvert(instr *Convert, x *Literal) lattice {
+	from, to := basicType(x.Type()), basicType(instr.Type())
+	if from == nil || to == nil {
+		return overdefined
+	}
+	switch {
+	case from.Info()&types.IsInteger != 0 && to.Info()&types.IsInteger != 0:
+		return fit(x.Value, instr.Type())
+	case from.Info()&types.IsNumeric != 0 && to.Info()&types.IsFloat != 0:
+		return fit(exact.ToFloat(x.Value), instr.Type())
+	case from.Info()&types.IsFloat != 0 && to.Info()&types.IsInteger != 0:
+		// Only integral values are folded, since conversion
+		// truncates towards zero.  The result of converting a
+		// value that does not fit is implementation-specific.
+		v := exact.ToInt(x.Value)
+		if v.Kind() != exact.Int {
+			return overdefined
+		}
+		size, signed := intSize(to), to.Info()&types.IsUnsigned == 0
+		if size == 0 {
+			size = 4 // the size of int, uint or uintptr may be 4
+		}
+		if _, ok := exact.ConvertInt(v, size, signed); !ok {
+			return overdefined
+		}
+		return fit(v, instr.Type())
+	}
+	return overdefined // e.g. string conversions
+}
+
+// rewrite replaces the constant registers of s.fn by literals, folds
+// If instructions with constant conditions, and deletes unexecutable
+// blocks.  It reports whether the CFG changed.
+func (s *sccpState) rewrite() bool {
+	f := s.fn
+
+	// Replace constant registers by literals.
+	for _, b := range f.Blocks {
+		if !s.execBlocks[b.Index] {
+			continue
+		}
+		for i, instr := range b.Instrs {
+			v, ok := instr.(Value)
+			if !ok {
+				continue
+			}
+			if lit, ok := s.values[v].(*Literal); ok {
+				replaceAll(v, lit)
+				dropReferrers(instr)
+				b.Instrs[i] = nil
+				b.gaps++
+			}
+		}
+	}
+
+	// Fold Ifs whose condition is now constant.
+	changed := false
+	for _, b := range f.Blocks {
+		if !s.execBlocks[b.Index] {
+			continue
+		}
+		last := len(b.Instrs) - 1
+		if instr, ok := b.Instrs[last].(*If); ok {
+			if lit, ok := instr.Cond.(*Literal); ok {
+				succ, other := b.Succs[0], b.Succs[1]
+				if !exact.BoolVal(lit.Value) {
+					succ, other = other, succ
+				}
+				jump := new(Jump)
+				jump.SetBlock(b)
+				b.Instrs[last] = jump
+				b.Succs = append(b.succs2[:0], succ)
+				other.removePred(b)
+				changed = true
+			}
+		}
+	}
+
+	// Delete unexecutable blocks.
+	for _, b := range f.Blocks {
+		if !s.execBlocks[b.Index] {
+			for _, instr := range b.Instrs {
+				if instr != nil {
+					dropReferrers(instr)
+				}
+			}
+			changed = true
+		}
+	}
+	if changed {
+		deleteUnreachableBlocks(f)
+	}
+
+	// Compact the instructions, eliminating φ-nodes that now have
+	// a single edge.
+	for _, b := range f.Blocks {
+		if len(b.Preds) == 1 {
+			for i, instr := range b.Instrs {
+				if phi, ok := instr.(*Phi); ok {
+					replaceAll(phi, phi.Edges[0])
+					dropReferrers(phi)
+					b.Instrs[i] = nil
+					b.gaps++
+				}
+			}
+		}
+		compactInstrs(b)
+	}
+
+	if changed {
+		optimizeBlocks(f)
+	}
+	return changed
+}
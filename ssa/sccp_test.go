@@ -0,0 +1,120 @@
+package ssa_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+const sccpSrc = `package main
+
+func f() int {
+	x := 1
+	y := x + 2
+	if y > 2 {
+		return y * 10
+	}
+	return 0
+}
+
+func g(n int) int {
+	k := 3
+	for i := 0; i < n; i++ {
+		if k != 3 {
+			k = n // never executed
+		}
+	}
+	return k
+}
+
+func h(n int8) int8 {
+	x := int8(127)
+	return x + 1 // wraps around
+}
+
+// The results of converting floats that do not fit in the integer
+// type are implementation-specific, so they are not folded.
+func i() int32  { x := 1e10; return int32(x) }
+func j() uint8  { x := -1.0; return uint8(x) }
+func k() int    { x := 1e10; return int(x) }
+func l() uint16 { x := 65535.0; return uint16(x) }
+
+func main() {
+	f()
+	g(1)
+	h(1)
+	i()
+	j()
+	k()
+	l()
+}
+`
+
+func buildSCCP(t *testing.T, mode ssa.BuilderMode) *ssa.Package {
+	b := ssa.NewBuilder(&ssa.Context{Mode: mode, Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", sccpSrc, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pkg
+}
+
+// result returns the value returned by the sole Ret of fn.
+func result(t *testing.T, fn *ssa.Function) ssa.Value {
+	var ret *ssa.Ret
+	for _, b := range fn.Blocks {
+		if r, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Ret); ok {
+			if ret != nil {
+				t.Fatalf("%s: more than one Ret", fn)
+			}
+			ret = r
+		}
+	}
+	if ret == nil {
+		t.Fatalf("%s: no Ret", fn)
+	}
+	return ret.Results[0]
+}
+
+func TestConstantPropagation(t *testing.T) {
+	if fn := buildSCCP(t, 0).Func("f"); len(fn.Blocks) == 1 {
+		t.Errorf("%s: without ConstantPropagation, the If should remain", fn)
+	}
+
+	pkg := buildSCCP(t, ssa.ConstantPropagation|ssa.SanityCheckFunctions)
+	for _, test := range []struct {
+		fn   string
+		want int64
+	}{
+		{"f", 30},
+		{"g", 3},
+		{"h", -128},
+		{"l", 65535},
+	} {
+		fn := pkg.Func(test.fn)
+		lit, ok := result(t, fn).(*ssa.Literal)
+		if !ok {
+			t.Errorf("%s: result is not constant", fn)
+			continue
+		}
+		if got := lit.Int64(); got != test.want {
+			t.Errorf("%s: got result %d, want %d", fn, got, test.want)
+		}
+	}
+	for _, name := range []string{"i", "j", "k"} {
+		fn := pkg.Func(name)
+		if lit, ok := result(t, fn).(*ssa.Literal); ok {
+			t.Errorf("%s: unrepresentable conversion folded to %s", fn, lit)
+		}
+	}
+	if fn := pkg.Func("f"); len(fn.Blocks) != 1 {
+		t.Errorf("%s: got %d blocks, want 1", fn, len(fn.Blocks))
+	}
+}
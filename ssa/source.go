@@ -0,0 +1,125 @@
+package ssa
+
+// This file defines utilities for mapping source syntax to the SSA
+// representation: finding the Function that encloses a syntax node,
+// and the Value computed by a source expression.
+//
+// Clients typically obtain the path from the root of a file's syntax
+// tree to the node under the cursor, and use it to locate first the
+// enclosing function, then the value.  The latter requires that the
+// function was built with the DebugInfo mode.
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// EnclosingFunction returns the function that contains the syntax
+// node denoted by path, or nil if there is none, e.g. the node is part
+// of a type or constant declaration.
+//
+// path is the sequence of nodes enclosing the node of interest,
+// innermost first, as far as the enclosing *ast.File.  The file must
+// be one of the files from which pkg was created.
+//
+// Syntax in package-level var initializers and init functions is
+// attributed to pkg.Init.  Syntax within a function literal is
+// attributed to the anonymous function, which is built only if the
+// enclosing function was.
+func EnclosingFunction(pkg *Package, path []ast.Node) *Function {
+	if len(path) == 0 {
+		return nil
+	}
+
+	// Find the outermost function.  We proceed from the root.
+	var fn *Function
+	i := len(path) - 1
+	for ; i >= 0 && fn == nil; i-- {
+		switch n := path[i].(type) {
+		case *ast.FuncDecl:
+			fn = findDeclaredFunc(pkg, n)
+			if fn == nil {
+				return nil
+			}
+		case *ast.GenDecl:
+			if n.Tok != token.VAR {
+				return nil
+			}
+			fn = pkg.Init
+		}
+	}
+
+	// Descend through any enclosing function literals.
+	for ; i >= 0 && fn != nil; i-- {
+		if lit, ok := path[i].(*ast.FuncLit); ok {
+			fn = findAnonFunc(fn, lit)
+		}
+	}
+	return fn
+}
+
+// findDeclaredFunc returns the Function of package pkg for the
+// function or method declaration decl, or nil if not found.
+func findDeclaredFunc(pkg *Package, decl *ast.FuncDecl) *Function {
+	if decl.Recv == nil {
+		if decl.Name.Name == "init" {
+			return pkg.Init // init blocks aren't functions
+		}
+		if fn, ok := pkg.Members[decl.Name.Name].(*Function); ok && fn.Pos() == decl.Name.Pos() {
+			return fn
+		}
+		return nil
+	}
+	// A method.
+	for _, fn := range pkg.Prog.concreteMethods {
+		if fn.Pkg == pkg && fn.Pos() == decl.Name.Pos() {
+			return fn
+		}
+	}
+	return nil
+}
+
+// findAnonFunc returns the anonymous function of fn for the function
+// literal lit, or nil if not found.
+func findAnonFunc(fn *Function, lit *ast.FuncLit) *Function {
+	for _, anon := range fn.AnonFuncs {
+		if anon.Pos() == lit.Type.Func {
+			return anon
+		}
+	}
+	return nil
+}
+
+// ValueForExpr returns the SSA Value that corresponds to expression
+// e, which must be part of the syntax of function f.
+//
+// It returns nil if no value was found, e.g.
+//   - the expression is not lexically contained within f;
+//   - f was not built with the DebugInfo builder mode;
+//   - e is a type, a package name, or the name of a function or
+//     method that is not a value in this context;
+//   - the code containing e was found to be unreachable and was
+//     deleted.
+//
+// If e is a constant expression, the result is a Literal.
+//
+// If e denotes a variable, the result is generally the value of the
+// variable at that point: the value loaded from it, or for the target
+// of an assignment, the value stored to it.  If there is no such
+// value, as for the operand x of &x, the result is the address of the
+// variable, and isAddr is true.
+func (f *Function) ValueForExpr(e ast.Expr) (value Value, isAddr bool) {
+	for _, b := range f.Blocks {
+		for _, instr := range b.Instrs {
+			if ref, ok := instr.(*DebugRef); ok && ref.Expr == e {
+				if !ref.IsAddr {
+					return ref.X, false
+				}
+				if value == nil {
+					value, isAddr = ref.X, true
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,136 @@
+package ssa_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+const sourceSrc = `package main
+
+type T struct{ f *int }
+
+func (t *T) m() *int { return t.f }
+
+var g = new(int)
+
+func f() *int {
+	x := new(int)
+	y := x
+	q := &y
+	func() {
+		_ = *q
+	}()
+	return y
+}
+
+func main() {
+	f()
+	new(T).m()
+}
+`
+
+func buildSource(t *testing.T, mode ssa.BuilderMode) (*ssa.Package, *ast.File) {
+	b := ssa.NewBuilder(&ssa.Context{Mode: mode | ssa.SanityCheckFunctions, Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", sourceSrc, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pkg, file
+}
+
+// findPath returns the path from the first node in file, in source
+// order, for which pred is true, to the root, innermost first.
+func findPath(t *testing.T, file *ast.File, pred func(ast.Node) bool) []ast.Node {
+	var stack, path []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if path != nil {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, n)
+		if pred(n) {
+			for i := len(stack) - 1; i >= 0; i-- {
+				path = append(path, stack[i])
+			}
+		}
+		return true
+	})
+	if path == nil {
+		t.Fatal("node not found")
+	}
+	return path
+}
+
+// ident returns the path to the nth occurrence of the named identifier.
+func ident(t *testing.T, file *ast.File, name string, n int) []ast.Node {
+	return findPath(t, file, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && id.Name == name {
+			n--
+			return n < 0
+		}
+		return false
+	})
+}
+
+func TestEnclosingFunction(t *testing.T) {
+	pkg, file := buildSource(t, 0)
+	f := pkg.Func("f")
+	for _, test := range []struct {
+		path []ast.Node
+		want *ssa.Function
+	}{
+		{ident(t, file, "x", 0), f},
+		{ident(t, file, "q", 1), f.AnonFuncs[0]},
+		{ident(t, file, "g", 0), pkg.Init},
+		{ident(t, file, "T", 0), nil},
+	} {
+		if got := ssa.EnclosingFunction(pkg, test.path); got != test.want {
+			t.Errorf("EnclosingFunction(%s) = %v, want %v", test.path[0], got, test.want)
+		}
+	}
+
+	// The method (*T).m.
+	if fn := ssa.EnclosingFunction(pkg, ident(t, file, "f", 1)); fn == nil || fn.Name() != "m" {
+		t.Errorf("EnclosingFunction(t.f) = %v, want method m", fn)
+	}
+}
+
+func TestValueForExpr(t *testing.T) {
+	pkg, file := buildSource(t, ssa.DebugInfo)
+	f := pkg.Func("f")
+	value := func(path []ast.Node) (ssa.Value, bool) {
+		return f.ValueForExpr(path[0].(ast.Expr))
+	}
+
+	// x is lifted: both references denote the result of new(int).
+	def, isAddr := value(ident(t, file, "x", 0))
+	if _, ok := def.(*ssa.Alloc); !ok || isAddr {
+		t.Fatalf("ValueForExpr(x) = %v, %t, want the new(int) allocation", def, isAddr)
+	}
+	if use, _ := value(ident(t, file, "x", 1)); use != def {
+		t.Errorf("ValueForExpr(x) in y := x = %v, want %v", use, def)
+	}
+
+	// y is not lifted since its address is taken.
+	addr, isAddr := value(ident(t, file, "y", 1))
+	if alloc, ok := addr.(*ssa.Alloc); !ok || !isAddr || alloc.Name() != "y" {
+		t.Errorf("ValueForExpr(y) in &y = %v, %t, want address of y", addr, isAddr)
+	}
+
+	// Without debug info, there are no values.
+	pkg, file = buildSource(t, 0)
+	if v, _ := pkg.Func("f").ValueForExpr(ident(t, file, "x", 1)[0].(ast.Expr)); v != nil {
+		t.Errorf("ValueForExpr(x) without DebugInfo = %v, want nil", v)
+	}
+}
@@ -267,6 +267,15 @@ type Function struct {
 	Blocks    []*BasicBlock // basic blocks of the function; nil => external
 	AnonFuncs []*Function   // anonymous functions directly beneath this one
 
+	// What a synthetic thunk wraps (see promote.go): the method of
+	// a bound method thunk, or the interface type and method of an
+	// interface method thunk.
+	thunkMethod *Function
+	thunkType   types.Type
+	thunkId     Id
+
+	started int32 // atomically tested and set at start of build phase
+
 	// The following fields are set transiently during building,
 	// then cleared.
 	currentBlock *BasicBlock             // where to emit code
@@ -299,7 +308,7 @@ type BasicBlock struct {
 	Instrs       []Instruction  // instructions in order
 	Preds, Succs []*BasicBlock  // predecessors and successors
 	succs2       [2]*BasicBlock // initial space for Succs.
-	dom          *domNode       // node in dominator tree; optional.
+	dom          *domNode       // node in dominator tree
 	gaps         int            // number of nil Instrs (transient).
 	rundefers    int            // number of rundefers (transient)
 }
@@ -1088,6 +1097,30 @@ type MapUpdate struct {
 	pos   token.Pos
 }
 
+// A DebugRef instruction provides the position information for a
+// specific source expression Expr that refers to a local SSA value X.
+// It has no effect on execution.
+//
+// DebugRefs are generated only for functions built with the
+// DebugInfo builder mode; see (*Function).ValueForExpr.
+//
+// If IsAddr, X is the address of the variable denoted by Expr (e.g.
+// the Alloc of a local variable x in &x); otherwise X is the value
+// of Expr.  Expr may be a constant, in which case X is a Literal.
+//
+// Pos() returns Expr.Pos(), the start position of the source
+// expression.
+//
+// Example printed form:
+// 	; *ast.Ident @ 12:2 is t3
+// 	; address of *ast.Ident @ 14:3 is x
+type DebugRef struct {
+	anInstruction
+	X      Value    // the value or address of Expr
+	Expr   ast.Expr // the referring expression
+	IsAddr bool     // Expr is addressable and X is its address
+}
+
 // Embeddable mix-ins and helpers for common parts of other structs. -----------
 
 // Register is a mix-in embedded by all SSA values that are also
@@ -1349,6 +1382,7 @@ func (*Call) ImplementsInstruction()            {}
 func (*ChangeInterface) ImplementsInstruction() {}
 func (*ChangeType) ImplementsInstruction()      {}
 func (*Convert) ImplementsInstruction()         {}
+func (*DebugRef) ImplementsInstruction()        {}
 func (*Defer) ImplementsInstruction()           {}
 func (*Extract) ImplementsInstruction()         {}
 func (*Field) ImplementsInstruction()           {}
@@ -1380,6 +1414,7 @@ func (*UnOp) ImplementsInstruction()            {}
 
 func (v *Alloc) Pos() token.Pos     { return v.pos }
 func (v *Call) Pos() token.Pos      { return v.Call.pos }
+func (s *DebugRef) Pos() token.Pos  { return s.Expr.Pos() }
 func (s *Defer) Pos() token.Pos     { return s.Call.pos }
 func (s *Go) Pos() token.Pos        { return s.Call.pos }
 func (s *MapUpdate) Pos() token.Pos { return s.pos }
@@ -1433,6 +1468,10 @@ func (v *Convert) Operands(rands []*Value) []*Value {
 	return append(rands, &v.X)
 }
 
+func (s *DebugRef) Operands(rands []*Value) []*Value {
+	return append(rands, &s.X)
+}
+
 func (v *Extract) Operands(rands []*Value) []*Value {
 	return append(rands, &v.Tuple)
 }
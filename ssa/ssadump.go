@@ -12,6 +12,7 @@ import (
 	"runtime/pprof"
 
 	"code.google.com/p/go.tools/ssa"
+	"code.google.com/p/go.tools/ssa/callgraph"
 	"code.google.com/p/go.tools/ssa/interp"
 )
 
@@ -22,12 +23,19 @@ P	log [P]ackage inventory.
 F	log [F]unction SSA code.
 S	log [S]ource locations as SSA builder progresses.
 G	use binary object files from gc to provide imports (no code).
-L	build distinct packages seria[L]ly instead of in parallel.
+L	build packages and functions seria[L]ly instead of in parallel.
 N	build [N]aive SSA form: don't replace local loads/stores with registers.
+K	fold [K]onstants and prune infeasible branches (SCCP).
+V	merge equivalent computations by global [V]alue numbering.
+D	delete [D]ead computations.
+I	emit debug [I]nfo mapping source expressions to SSA values.
 `)
 
 var runFlag = flag.Bool("run", false, "Invokes the SSA interpreter on the program.")
 
+var callgraphFlag = flag.String("callgraph", "", `Prints the call graph of the program in DOT form.
+The value selects the algorithm: cha or rta.`)
+
 var interpFlag = flag.String("interp", "", `Options controlling the SSA test interpreter.
 The value is a sequence of zero or more more of these letters:
 R	disable [R]ecover() from panic; show interpreter crash instead.
@@ -42,6 +50,7 @@ Use -help flag to display options.
 Examples:
 % ssadump -run -interp=T hello.go     # interpret a program, with tracing
 % ssadump -build=FPG hello.go         # quickly dump SSA form of a single package
+% ssadump -callgraph=rta hello.go     # print the call graph of a program
 `
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
@@ -63,6 +72,14 @@ func main() {
 			mode |= ssa.SanityCheckFunctions
 		case 'N':
 			mode |= ssa.NaiveForm
+		case 'K':
+			mode |= ssa.ConstantPropagation
+		case 'V':
+			mode |= ssa.ValueNumbering
+		case 'D':
+			mode |= ssa.DeadCodeElimination
+		case 'I':
+			mode |= ssa.DebugInfo
 		case 'G':
 			mode |= ssa.UseGCImporter
 		case 'L':
@@ -111,6 +128,21 @@ func main() {
 	b.BuildAllPackages()
 	b = nil // discard Builder
 
+	if *callgraphFlag != "" {
+		var algo callgraph.Algorithm
+		switch *callgraphFlag {
+		case "cha":
+			algo = callgraph.CHA
+		case "rta":
+			algo = callgraph.RTA
+		default:
+			log.Fatalf("Unknown -callgraph algorithm: %q.", *callgraphFlag)
+		}
+		if err := callgraph.Build(mainpkg.Prog, algo).WriteDOT(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if *runFlag {
 		interp.Interpret(mainpkg, interpMode, mainpkg.Name(), args)
 	}
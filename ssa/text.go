@@ -0,0 +1,713 @@
+package ssa
+
+// This file defines the textual SSA syntax, and the writer that
+// produces it.  The parser is in textparse.go.
+//
+// Unlike the output of DumpTo, which is intended for human readers,
+// the textual syntax is regular and complete: ParseFunctions
+// reconstructs from it Functions equivalent to those written by
+// WriteText, and clients may write it by hand, e.g. to construct test
+// inputs for analyses and optimizations.
+//
+// The syntax is based on Go tokens; newlines terminate declarations
+// and instructions as in Go.  A file is a sequence of function
+// declarations:
+//
+//	Func      = "func" [ "(" Param ")" ] FuncName Params [ Results ] [ Pos ] ";"
+//	            [ "parent" Member ";" ]
+//	            { "free" Local Type ";" }
+//	            [ "{" { Block } "}" ";" ] .
+//	FuncName  = identifier | string_lit .
+//	Params    = "(" [ Param { "," Param } ] ")" .
+//	Param     = Local [ "..." ] Type .
+//	Results   = Type | "(" [ Type { "," Type } ] ")" .
+//	Pos       = "at" string_lit .                 // e.g. at "main.go:3:7"
+//	Block     = "block" int_lit string_lit [ "preds" { int_lit } ] ";"
+//	            { Instr [ Pos ] ";" } .
+//
+// A function without a body is external.  The free variables of a
+// nested function declare its Captures, and its parent is the
+// lexically enclosing function.  Blocks are numbered consecutively
+// from zero; the comment string has no semantic significance.  The
+// predecessors of a block must be listed in order, as they determine
+// the meaning of φ-nodes; the successors are implied by the final
+// instruction of each block.
+//
+// An instruction that defines a value is written "Local = Op : Type",
+// where Type is the type of the value; otherwise it is just "Op".  The
+// operations are:
+//
+//	local string_lit                 Alloc (the string is its name)
+//	new string_lit                   Alloc with Heap set
+//	phi "[" int_lit ":" Value, ... "]" string_lit
+//	                                 Phi (int_lit is a pred block; the string is its comment)
+//	call Call                        Call
+//	go Call                          Go
+//	defer Call                       Defer
+//	binop op Value, Value            BinOp, e.g. binop + %x, %y
+//	unop[,ok] op Value               UnOp, e.g. unop <- %ch
+//	changetype Value                 ChangeType
+//	convert Value                    Convert
+//	changeinterface Value            ChangeInterface
+//	makeinterface Value              MakeInterface
+//	makeclosure Value [ "[" Value, ... "]" ]
+//	                                 MakeClosure of a function with bindings
+//	makemap [ Value ]                MakeMap, with optional reserve
+//	makechan Value                   MakeChan
+//	makeslice Value, Value           MakeSlice
+//	slice Value, Value, Value        Slice (X, Low, High)
+//	fieldaddr Value, int_lit         FieldAddr
+//	field Value, int_lit             Field
+//	indexaddr Value, Value           IndexAddr
+//	index Value, Value               Index
+//	lookup[,ok] Value, Value         Lookup
+//	select,blocking "[" State, ... "]"
+//	select,nonblocking "[" State, ... "]"
+//	                                 Select; State is "recv Value" or "send Value Value"
+//	range Value                      Range
+//	next[,string] Value              Next
+//	typeassert[,ok] Value, Type      TypeAssert
+//	extract Value, int_lit           Extract
+//	jump int_lit                     Jump
+//	if Value, int_lit, int_lit       If
+//	ret [ Value, ... ]               Ret
+//	rundefers                        RunDefers
+//	panic Value                      Panic
+//	send Value, Value                Send
+//	store Value, Value               Store (Addr, Val)
+//	mapupdate Value, Value, Value    MapUpdate
+//
+// where Call is either "Value(Args)" for a function call or "invoke
+// Value.method(Args)" for an interface method invocation, and a final
+// "..." after the arguments sets HasEllipsis.
+//
+// The operands are written:
+//
+//	Local      = "%" identifier .      // parameter, free variable or instruction of the function
+//	Member     = string_lit "." ( identifier | string_lit ) .
+//	MethodName = [ string_lit "." ] identifier .
+//	Value      = Local | Member | "builtin" identifier | "const" Type Const | "_"
+//	           | "bound" Member | "thunk" Type "." MethodName .
+//
+// A Member is a package-level function or global, or a declared method
+// such as "(*T).m", of the package whose import path is the string; ""
+// denotes the package against which the text is parsed, in which
+// functions declared in the text take precedence.  "bound m" denotes
+// the wrapper function of the bound method value x.m, whose receiver
+// x is the only binding of the MakeClosure, and "thunk I.m" the wrapper
+// function of the method expression I.m of interface type I.  The
+// import path of a MethodName denotes the package of an unexported
+// method (or of a method of an interface type literal) if it is not
+// the package against which the text is parsed.  "_" denotes a nil
+// operand, e.g. an omitted Slice bound.  A Const is true, false, nil,
+// a string literal, an optionally negated integer or fraction (e.g.
+// -1/3), or complex(re, im).
+//
+// Types are written in Go syntax, except that named types of other
+// packages are qualified by the quoted import path (e.g.
+// "go/ast".Node), untyped basic types are written "untyped kind" (e.g.
+// untyped integer), tuples are written "(T, ...)", the methods of
+// interface type literals are written "MethodName Signature" (e.g.
+// interface{m(int) string}), the type of range iterators is written
+// range, and the invalid type is written invalid.  Named types must be
+// declared at package level.
+//
+// DebugRef instructions, which refer to syntax trees, are not
+// represented.
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"code.google.com/p/go.tools/go/exact"
+	"code.google.com/p/go.tools/go/types"
+)
+
+// WriteText writes f to w in the textual SSA syntax.
+func (f *Function) WriteText(w io.Writer) error {
+	tw := &textWriter{fn: f, names: make(map[Value]string), used: make(map[string]bool)}
+	if f.Pkg != nil {
+		tw.pkg = f.Pkg.Types
+	}
+	tw.writeFunc()
+	_, err := w.Write(tw.buf.Bytes())
+	return err
+}
+
+// A textWriter accumulates the text of a single function.
+type textWriter struct {
+	buf   bytes.Buffer
+	fn    *Function
+	pkg   *types.Package // the package relative to which names are qualified, or nil
+	names map[Value]string
+	used  map[string]bool
+}
+
+// name assigns v a unique name, its own if possible.
+func (w *textWriter) name(v Value, prefix string, i int) {
+	name := v.Name()
+	if !isIdent(name) || name == "_" || w.used[name] {
+		name = fmt.Sprintf("%s%d", prefix, i)
+		for w.used[name] {
+			name += "_"
+		}
+	}
+	w.used[name] = true
+	w.names[v] = name
+}
+
+func (w *textWriter) writeFunc() {
+	f := w.fn
+	for i, p := range f.Params {
+		w.name(p, "arg", i)
+	}
+	for i, fv := range f.FreeVars {
+		w.name(fv, "free", i)
+	}
+	i := 0
+	for _, b := range f.Blocks {
+		for _, instr := range b.Instrs {
+			if v, ok := instr.(Value); ok {
+				w.name(v, "v", i)
+				i++
+			}
+		}
+	}
+
+	w.buf.WriteString("func ")
+	params := f.Params
+	if f.Signature.Recv() != nil && len(params) > 0 {
+		w.buf.WriteString("(")
+		w.param(params[0], false)
+		w.buf.WriteString(") ")
+		params = params[1:]
+	}
+	w.funcName(f.Name())
+	w.buf.WriteString("(")
+	for i, p := range params {
+		if i > 0 {
+			w.buf.WriteString(", ")
+		}
+		w.param(p, f.Signature.IsVariadic() && i == len(params)-1)
+	}
+	w.buf.WriteString(")")
+	if results := f.Signature.Results(); results.Len() == 1 {
+		w.buf.WriteString(" ")
+		w.typ(results.At(0).Type())
+	} else if results.Len() > 1 {
+		w.buf.WriteString(" ")
+		w.typ(results)
+	}
+	w.pos(f.Pos())
+	w.buf.WriteString("\n")
+
+	if f.Enclosing != nil {
+		w.buf.WriteString("parent ")
+		w.funcRef(f.Enclosing)
+		w.buf.WriteString("\n")
+	}
+	for _, fv := range f.FreeVars {
+		fmt.Fprintf(&w.buf, "free %%%s ", w.names[fv])
+		w.typ(fv.Type())
+		w.buf.WriteString("\n")
+	}
+	if f.Blocks == nil {
+		w.buf.WriteString("\n") // external
+		return
+	}
+
+	w.buf.WriteString("{\n")
+	for _, b := range f.Blocks {
+		fmt.Fprintf(&w.buf, "block %d %s", b.Index, strconv.Quote(b.Comment))
+		if len(b.Preds) > 0 {
+			w.buf.WriteString(" preds")
+			for _, pred := range b.Preds {
+				fmt.Fprintf(&w.buf, " %d", pred.Index)
+			}
+		}
+		w.buf.WriteString("\n")
+		for _, instr := range b.Instrs {
+			if _, ok := instr.(*DebugRef); ok {
+				continue // not represented
+			}
+			w.buf.WriteString("\t")
+			w.instr(instr)
+			w.buf.WriteString("\n")
+		}
+	}
+	w.buf.WriteString("}\n\n")
+}
+
+func (w *textWriter) param(p *Parameter, variadic bool) {
+	fmt.Fprintf(&w.buf, "%%%s ", w.names[p])
+	if variadic {
+		w.buf.WriteString("...")
+		w.typ(p.Type().(*types.Slice).Elem())
+	} else {
+		w.typ(p.Type())
+	}
+}
+
+// funcName writes the name of a function or member, quoted if it is
+// not an identifier.
+func (w *textWriter) funcName(name string) {
+	if isIdent(name) {
+		w.buf.WriteString(name)
+	} else {
+		w.buf.WriteString(strconv.Quote(name))
+	}
+}
+
+func (w *textWriter) pos(pos token.Pos) {
+	if pos.IsValid() {
+		p := w.fn.Prog.Files.Position(pos)
+		fmt.Fprintf(&w.buf, " at %s", strconv.Quote(fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)))
+	}
+}
+
+// member writes a reference to the member name of the package with
+// types pkg.
+func (w *textWriter) member(pkg *types.Package, name string) {
+	path := ""
+	if pkg != nil && pkg != w.pkg {
+		path = pkg.Path()
+	}
+	w.buf.WriteString(strconv.Quote(path))
+	w.buf.WriteString(".")
+	w.funcName(name)
+}
+
+// methodName writes the name of a method of package pkg, qualified
+// by the import path if pkg is not the current package.
+func (w *textWriter) methodName(pkg *types.Package, name string) {
+	if pkg != nil && pkg != w.pkg {
+		w.buf.WriteString(strconv.Quote(pkg.Path()))
+		w.buf.WriteString(".")
+	}
+	w.buf.WriteString(name)
+}
+
+// funcRef writes a reference to the function fn.
+func (w *textWriter) funcRef(fn *Function) {
+	w.member(funcMemberName(fn))
+}
+
+// funcMemberName returns the package and name by which a Member
+// refers to fn.  Declared methods are named by their receiver type,
+// e.g. "(*T).m".
+func funcMemberName(fn *Function) (*types.Package, string) {
+	var pkg *types.Package
+	if fn.Pkg != nil {
+		pkg = fn.Pkg.Types
+	}
+	name := fn.Name()
+	if recv := fn.Signature.Recv(); recv != nil && fn.Enclosing == nil {
+		star := ""
+		t := recv.Type()
+		if p, ok := t.(*types.Pointer); ok {
+			star = "*"
+			t = p.Elem()
+		}
+		if nt, ok := t.(*types.Named); ok {
+			pkg = nt.Obj().Pkg()
+			name = fmt.Sprintf("(%s%s).%s", star, nt.Obj().Name(), name)
+		}
+	}
+	return pkg, name
+}
+
+// value writes the operand v.
+func (w *textWriter) value(v Value) {
+	switch v := v.(type) {
+	case nil:
+		w.buf.WriteString("_")
+	case *Literal:
+		w.buf.WriteString("const ")
+		w.typ(v.Type())
+		w.buf.WriteString(" ")
+		w.literal(v.Value)
+	case *Global:
+		w.member(v.Pkg.Types, v.Name())
+	case *Function:
+		switch {
+		case v.thunkMethod != nil:
+			w.buf.WriteString("bound ")
+			w.funcRef(v.thunkMethod)
+		case v.thunkType != nil:
+			w.buf.WriteString("thunk ")
+			w.typ(v.thunkType)
+			w.buf.WriteString(".")
+			w.methodName(v.thunkId.Pkg, v.thunkId.Name)
+		default:
+			w.funcRef(v)
+		}
+	case *Builtin:
+		w.buf.WriteString("builtin ")
+		w.buf.WriteString(v.Name())
+	default:
+		name, ok := w.names[v]
+		if !ok {
+			name = "?" + v.Name() // not a value of this function
+		}
+		w.buf.WriteString("%")
+		w.buf.WriteString(name)
+	}
+}
+
+func (w *textWriter) values(vs []Value) {
+	for i, v := range vs {
+		if i > 0 {
+			w.buf.WriteString(", ")
+		}
+		w.value(v)
+	}
+}
+
+func (w *textWriter) literal(x exact.Value) {
+	switch x.Kind() {
+	case exact.Nil:
+		w.buf.WriteString("nil")
+	case exact.Bool, exact.String, exact.Int:
+		w.buf.WriteString(x.String())
+	case exact.Float:
+		// Fractions are exact; other values are approximated
+		// as float64.
+		s := x.String()
+		if !strings.Contains(s, "/") {
+			f, _ := exact.Float64Val(x)
+			s = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		w.buf.WriteString(s)
+	case exact.Complex:
+		w.buf.WriteString("complex(")
+		w.literal(exact.Real(x))
+		w.buf.WriteString(", ")
+		w.literal(exact.Imag(x))
+		w.buf.WriteString(")")
+	default:
+		fmt.Fprintf(&w.buf, "<%s>", x) // unknown
+	}
+}
+
+// instr writes a single instruction and its position.
+func (w *textWriter) instr(instr Instruction) {
+	if v, ok := instr.(Value); ok {
+		fmt.Fprintf(&w.buf, "%%%s = ", w.names[v])
+	}
+	switch instr := instr.(type) {
+	case *Alloc:
+		op := "local"
+		if instr.Heap {
+			op = "new"
+		}
+		fmt.Fprintf(&w.buf, "%s %s", op, strconv.Quote(instr.Name_))
+	case *Phi:
+		w.buf.WriteString("phi [")
+		for i, e := range instr.Edges {
+			if i > 0 {
+				w.buf.WriteString(", ")
+			}
+			pred := -1 // be robust against malformed CFG
+			if i < len(instr.Block_.Preds) {
+				pred = instr.Block_.Preds[i].Index
+			}
+			fmt.Fprintf(&w.buf, "%d: ", pred)
+			w.value(e)
+		}
+		fmt.Fprintf(&w.buf, "] %s", strconv.Quote(instr.Comment))
+	case *Call:
+		w.call("call", &instr.Call)
+	case *Go:
+		w.call("go", &instr.Call)
+	case *Defer:
+		w.call("defer", &instr.Call)
+	case *BinOp:
+		fmt.Fprintf(&w.buf, "binop %s ", instr.Op)
+		w.values([]Value{instr.X, instr.Y})
+	case *UnOp:
+		fmt.Fprintf(&w.buf, "unop%s %s ", commaOk(instr.CommaOk), instr.Op)
+		w.value(instr.X)
+	case *ChangeType:
+		w.op("changetype", instr.X)
+	case *Convert:
+		w.op("convert", instr.X)
+	case *ChangeInterface:
+		w.op("changeinterface", instr.X)
+	case *MakeInterface:
+		w.op("makeinterface", instr.X)
+	case *MakeClosure:
+		w.op("makeclosure", instr.Fn)
+		if instr.Bindings != nil {
+			w.buf.WriteString(" [")
+			w.values(instr.Bindings)
+			w.buf.WriteString("]")
+		}
+	case *MakeMap:
+		w.buf.WriteString("makemap")
+		if instr.Reserve != nil {
+			w.buf.WriteString(" ")
+			w.value(instr.Reserve)
+		}
+	case *MakeChan:
+		w.op("makechan", instr.Size)
+	case *MakeSlice:
+		w.op("makeslice", instr.Len, instr.Cap)
+	case *Slice:
+		w.op("slice", instr.X, instr.Low, instr.High)
+	case *FieldAddr:
+		w.op("fieldaddr", instr.X)
+		fmt.Fprintf(&w.buf, ", %d", instr.Field)
+	case *Field:
+		w.op("field", instr.X)
+		fmt.Fprintf(&w.buf, ", %d", instr.Field)
+	case *IndexAddr:
+		w.op("indexaddr", instr.X, instr.Index)
+	case *Index:
+		w.op("index", instr.X, instr.Index)
+	case *Lookup:
+		w.op("lookup"+commaOk(instr.CommaOk), instr.X, instr.Index)
+	case *Select:
+		if instr.Blocking {
+			w.buf.WriteString("select,blocking [")
+		} else {
+			w.buf.WriteString("select,nonblocking [")
+		}
+		for i, st := range instr.States {
+			if i > 0 {
+				w.buf.WriteString(", ")
+			}
+			if st.Dir == ast.RECV {
+				w.buf.WriteString("recv ")
+				w.value(st.Chan)
+			} else {
+				w.buf.WriteString("send ")
+				w.value(st.Chan)
+				w.buf.WriteString(" ")
+				w.value(st.Send)
+			}
+		}
+		w.buf.WriteString("]")
+	case *Range:
+		w.op("range", instr.X)
+	case *Next:
+		op := "next"
+		if instr.IsString {
+			op += ",string"
+		}
+		w.op(op, instr.Iter)
+	case *TypeAssert:
+		w.op("typeassert"+commaOk(instr.CommaOk), instr.X)
+		w.buf.WriteString(", ")
+		w.typ(instr.AssertedType)
+	case *Extract:
+		w.op("extract", instr.Tuple)
+		fmt.Fprintf(&w.buf, ", %d", instr.Index)
+	case *Jump:
+		w.buf.WriteString("jump")
+		w.succs(instr.Block_)
+	case *If:
+		w.op("if", instr.Cond)
+		w.succs(instr.Block_)
+	case *Ret:
+		w.buf.WriteString("ret")
+		if len(instr.Results) > 0 {
+			w.buf.WriteString(" ")
+			w.values(instr.Results)
+		}
+	case *RunDefers:
+		w.buf.WriteString("rundefers")
+	case *Panic:
+		w.op("panic", instr.X)
+	case *Send:
+		w.op("send", instr.Chan, instr.X)
+	case *Store:
+		w.op("store", instr.Addr, instr.Val)
+	case *MapUpdate:
+		w.op("mapupdate", instr.Map, instr.Key, instr.Value)
+	default:
+		panic(fmt.Sprintf("unexpected instruction: %T", instr))
+	}
+
+	if v, ok := instr.(Value); ok {
+		w.buf.WriteString(" : ")
+		w.typ(v.Type())
+	}
+	w.pos(instr.Pos())
+}
+
+// op writes an operation and its operands.
+func (w *textWriter) op(op string, args ...Value) {
+	w.buf.WriteString(op)
+	w.buf.WriteString(" ")
+	w.values(args)
+}
+
+// succs writes the successors of the block terminated by a Jump or If.
+func (w *textWriter) succs(b *BasicBlock) {
+	for i, succ := range b.Succs {
+		if i > 0 || len(b.Succs) > 1 {
+			w.buf.WriteString(",")
+		}
+		fmt.Fprintf(&w.buf, " %d", succ.Index)
+	}
+}
+
+func (w *textWriter) call(op string, c *CallCommon) {
+	w.buf.WriteString(op)
+	w.buf.WriteString(" ")
+	if c.IsInvoke() {
+		w.buf.WriteString("invoke ")
+		w.value(c.Recv)
+		w.buf.WriteString(".")
+		w.buf.WriteString(c.Recv.Type().Underlying().(*types.Interface).Method(c.Method).Name())
+	} else {
+		w.value(c.Func)
+	}
+	w.buf.WriteString("(")
+	w.values(c.Args)
+	if c.HasEllipsis {
+		w.buf.WriteString("...")
+	}
+	w.buf.WriteString(")")
+}
+
+// typ writes the type t.
+func (w *textWriter) typ(t types.Type) {
+	switch t := t.(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.Invalid:
+			w.buf.WriteString("invalid")
+		case types.UnsafePointer:
+			w.buf.WriteString("unsafe.Pointer")
+		default:
+			w.buf.WriteString(t.Name())
+		}
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != nil && obj.Pkg() != w.pkg {
+			w.buf.WriteString(strconv.Quote(obj.Pkg().Path()))
+			w.buf.WriteString(".")
+		}
+		w.buf.WriteString(obj.Name())
+	case *types.Pointer:
+		w.buf.WriteString("*")
+		w.typ(t.Elem())
+	case *types.Slice:
+		w.buf.WriteString("[]")
+		w.typ(t.Elem())
+	case *types.Array:
+		fmt.Fprintf(&w.buf, "[%d]", t.Len())
+		w.typ(t.Elem())
+	case *types.Map:
+		w.buf.WriteString("map[")
+		w.typ(t.Key())
+		w.buf.WriteString("]")
+		w.typ(t.Elem())
+	case *types.Chan:
+		switch t.Dir() {
+		case ast.SEND:
+			w.buf.WriteString("chan<- ")
+		case ast.RECV:
+			w.buf.WriteString("<-chan ")
+		default:
+			w.buf.WriteString("chan ")
+			if c, ok := t.Elem().(*types.Chan); ok && c.Dir() == ast.RECV {
+				// chan (<-chan T), not chan<- (chan T)
+				w.buf.WriteString("(")
+				w.typ(c)
+				w.buf.WriteString(")")
+				return
+			}
+		}
+		w.typ(t.Elem())
+	case *types.Signature:
+		w.buf.WriteString("func")
+		w.signature(t)
+	case *types.Tuple:
+		w.tuple(t, false)
+	case *types.Struct:
+		w.buf.WriteString("struct{")
+		for i := 0; i < t.NumFields(); i++ {
+			if i > 0 {
+				w.buf.WriteString("; ")
+			}
+			f := t.Field(i)
+			if !f.IsAnonymous {
+				w.buf.WriteString(f.Name())
+				w.buf.WriteString(" ")
+			}
+			w.typ(f.Type())
+			if tag := t.Tag(i); tag != "" {
+				w.buf.WriteString(" ")
+				w.buf.WriteString(strconv.Quote(tag))
+			}
+		}
+		w.buf.WriteString("}")
+	case *types.Interface:
+		w.buf.WriteString("interface{")
+		for i := 0; i < t.NumMethods(); i++ {
+			if i > 0 {
+				w.buf.WriteString("; ")
+			}
+			m := t.Method(i)
+			w.methodName(m.Pkg(), m.Name())
+			w.signature(m.Type().(*types.Signature))
+		}
+		w.buf.WriteString("}")
+	case *opaqueType:
+		if t != tRangeIter {
+			fmt.Fprintf(&w.buf, "<%s>", t)
+			return
+		}
+		w.buf.WriteString("range")
+	default:
+		fmt.Fprintf(&w.buf, "<%s>", t)
+	}
+}
+
+// signature writes the parameters and results of the function type sig.
+func (w *textWriter) signature(sig *types.Signature) {
+	w.tuple(sig.Params(), sig.IsVariadic())
+	if results := sig.Results(); results.Len() == 1 {
+		w.buf.WriteString(" ")
+		w.typ(results.At(0).Type())
+	} else if results.Len() > 1 {
+		w.buf.WriteString(" ")
+		w.tuple(results, false)
+	}
+}
+
+// tuple writes the types of t in parentheses.  If variadic, the last
+// is written as "...T".
+func (w *textWriter) tuple(t *types.Tuple, variadic bool) {
+	w.buf.WriteString("(")
+	for i, n := 0, t.Len(); i < n; i++ {
+		if i > 0 {
+			w.buf.WriteString(", ")
+		}
+		typ := t.At(i).Type()
+		if variadic && i == n-1 {
+			w.buf.WriteString("...")
+			typ = typ.(*types.Slice).Elem()
+		}
+		w.typ(typ)
+	}
+	w.buf.WriteString(")")
+}
+
+// isIdent reports whether s is a Go identifier.
+func isIdent(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
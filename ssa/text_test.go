@@ -0,0 +1,184 @@
+package ssa_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"os"
+	"strings"
+	"testing"
+
+	"code.google.com/p/go.tools/ssa"
+)
+
+// textSrc imports no packages: the loader may be unable to load
+// the sources of the standard library.
+const textSrc = `package main
+
+type T struct{ x, y int }
+
+func (t *T) sum() int { return t.x + t.y }
+
+var g = &T{1, 2}
+
+func f(n int, args ...interface{}) (int, error) {
+	sum := 0
+	for i := 0; i < n; i++ {
+		sum += i
+	}
+	add := func(k int) { sum += k }
+	add(g.sum())
+	println(len(args), args[0])
+	return sum, nil
+}
+
+type S struct{}
+
+func (S) String() string { return "S" }
+
+type I interface {
+	m(x int) string
+}
+
+func r(m map[string]int, s string, i I) (int, func() string, func(I, int) string) {
+	n := 0
+	for k, v := range m {
+		n += len(k) + v
+	}
+	for _, c := range s {
+		n += int(c)
+	}
+	var j interface {
+		m(int) string
+		String() string
+	}
+	if j != nil {
+		n += len(j.m(1)) + len(i.m(2))
+	}
+	return n, S{}.String, I.m
+}
+
+func main() {
+	f(3, "a", 1.5, 2i)
+	r(nil, "s", nil)
+}
+`
+
+func buildText(t *testing.T) *ssa.Package {
+	b := ssa.NewBuilder(&ssa.Context{Mode: ssa.SanityCheckFunctions, Loader: ssa.MakeGoBuildLoader(nil)})
+	file, err := parser.ParseFile(b.Prog.Files, "main.go", textSrc, parser.DeclarationErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := b.CreatePackage("main", []*ast.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BuildPackage(pkg)
+	return pkg
+}
+
+func writeText(t *testing.T, fns []*ssa.Function) string {
+	var buf bytes.Buffer
+	for _, fn := range fns {
+		if err := fn.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.String()
+}
+
+// TestTextRoundTrip checks that the text of parsed functions is the
+// text from which they were parsed.
+func TestTextRoundTrip(t *testing.T) {
+	pkg := buildText(t)
+	f := pkg.Func("f")
+	fns := []*ssa.Function{f, f.AnonFuncs[0], pkg.Func("r"), pkg.Func("main")}
+	text := writeText(t, fns)
+	for _, want := range []string{": range", "interface{m(int) string; String() string}", "bound ", "thunk I.m"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text does not contain %q:\n%s", want, text)
+		}
+	}
+
+	parsed, err := ssa.ParseFunctions(pkg, "main.ssa", []byte(text))
+	if err != nil {
+		t.Fatalf("ParseFunctions failed: %s\n%s", err, text)
+	}
+	if len(parsed) != len(fns) {
+		t.Fatalf("ParseFunctions returned %d functions, want %d", len(parsed), len(fns))
+	}
+	for _, fn := range parsed {
+		if !ssa.SanityCheck(fn, os.Stderr) {
+			t.Errorf("parsed function %s is not well-formed", fn)
+		}
+	}
+	if parsed[1].Enclosing != parsed[0] {
+		t.Errorf("parent of %s is %s, want %s", parsed[1], parsed[1].Enclosing, parsed[0])
+	}
+	if got := writeText(t, parsed); got != text {
+		t.Errorf("text changed by round trip:\n%s\nwant:\n%s", got, text)
+	}
+}
+
+const absText = `
+// abs returns the absolute value of x.
+func abs(%x int) int
+{
+block 0 "entry"
+	%neg = binop < %x, const int 0 : bool
+	if %neg, 1, 2
+block 1 "if.then" preds 0
+	%y = unop - %x : int
+	jump 2
+block 2 "if.done" preds 0 1
+	%r = phi [0: %x, 1: %y] "abs" : int
+	ret %r
+}
+`
+
+func TestParseFunctions(t *testing.T) {
+	pkg := buildText(t)
+	fns, err := ssa.ParseFunctions(pkg, "abs.ssa", []byte(absText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs := fns[0]
+	if !ssa.SanityCheck(abs, os.Stderr) {
+		t.Fatalf("abs is not well-formed")
+	}
+	if n := len(abs.Blocks); n != 3 {
+		t.Fatalf("abs has %d blocks, want 3", n)
+	}
+	phi, ok := abs.Blocks[2].Instrs[0].(*ssa.Phi)
+	if !ok {
+		t.Fatalf("abs block 2 begins with %s, want phi", abs.Blocks[2].Instrs[0])
+	}
+	if phi.Edges[0] != abs.Params[0] {
+		t.Errorf("phi edge 0 is %s, want parameter x", phi.Edges[0])
+	}
+	if _, ok := phi.Edges[1].(*ssa.UnOp); !ok {
+		t.Errorf("phi edge 1 is %s, want unop", phi.Edges[1])
+	}
+}
+
+func TestParseFunctionsErrors(t *testing.T) {
+	pkg := buildText(t)
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"func f()\n{\nblock 0 \"entry\"\n\tret %x\n}", "undefined: %x"},
+		{"func f()\n{\nblock 1 \"entry\"\n\tret\n}", "block 1 out of order"},
+		{"func f()\n{\nblock 0 \"entry\"\n\tjump 1\n}", "undefined block 1"},
+		{"func f()\n{\nblock 0 \"entry\"\n\tjump 1\nblock 1 \"\"\n\tret\n}", "inconsistent"},
+		{"func f()\n{\nblock 0 \"entry\"\n\tfrob\n}", "unknown instruction frob"},
+		{"func f()\n{\nblock 0 \"entry\"\n\t%x = local \"x\" : int\n\tret\n}", "must be a pointer"},
+		{"func f()\n{\nblock 0 \"entry\"\n\tcall \"\".g()\n\tret\n}", "must be named"},
+		{"func f()\n{\nblock 0 \"entry\"\n\t%x = call \"\".nosuch() : ()\n\tret\n}", "undefined"},
+	} {
+		_, err := ssa.ParseFunctions(pkg, "bad.ssa", []byte(test.src))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseFunctions(%q) = %v, want error containing %q", test.src, err, test.want)
+		}
+	}
+}
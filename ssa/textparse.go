@@ -0,0 +1,1308 @@
+package ssa
+
+// This file defines the parser for the textual SSA syntax described
+// in text.go.
+
+import (
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"code.google.com/p/go.tools/go/exact"
+	"code.google.com/p/go.tools/go/types"
+)
+
+// ParseFunctions parses the functions in src, which is in the textual
+// SSA syntax, and returns them in order.  Names of types, functions
+// and globals are resolved relative to package pkg, and positions
+// relative to the files of pkg.Prog; filename is used only in error
+// messages.  Positions in files unknown to pkg.Prog are discarded.
+//
+// The functions are not added to pkg.Members.  Their Referrers and
+// dominator trees are computed and their registers numbered, so the
+// names of values in the text are not retained.  The result is not
+// checked for well-formedness beyond what is needed to construct it;
+// use SanityCheck for that.
+//
+// The error, if any, is a *scanner.Error describing the first problem.
+func ParseFunctions(pkg *Package, filename string, src []byte) (fns []*Function, err error) {
+	p := &textParser{
+		fset:  token.NewFileSet(),
+		pkg:   pkg,
+		funcs: make(map[string]*Function),
+	}
+	file := p.fset.AddFile(filename, -1, len(src))
+	p.scanner.Init(file, src, p.scanError, 0)
+
+	defer func() {
+		if e := recover(); e != nil {
+			serr, ok := e.(*scanner.Error)
+			if !ok {
+				panic(e)
+			}
+			err = serr
+		}
+	}()
+
+	p.next()
+	for {
+		for p.tok == token.SEMICOLON {
+			p.next()
+		}
+		if p.tok == token.EOF {
+			break
+		}
+		p.parseFunc()
+	}
+	p.finish()
+	return p.fns, nil
+}
+
+// A textParser holds the state of the parser.
+type textParser struct {
+	scanner scanner.Scanner
+	fset    *token.FileSet // for the text
+	pkg     *Package
+
+	// current token
+	pos token.Pos
+	tok token.Token
+	lit string
+
+	fns     []*Function
+	funcs   map[string]*Function // functions of the text, by member name
+	parents map[*Function]*textRef
+	fixups  []func() // run once all operands are resolved
+
+	// state for the current function
+	fn     *Function
+	values map[string]Value       // local values, by name
+	blocks map[int64]*BasicBlock  // blocks referenced so far, by index
+	phis   map[*Phi][]*BasicBlock // preds named by each phi edge
+}
+
+// A textRef is a placeholder operand, referring to a local value that
+// may be defined later, or to a member, which is resolved when the
+// whole text has been parsed.
+type textRef struct {
+	pos   token.Pos
+	local bool
+	bound bool   // refers to the bound method thunk of the member
+	path  string // package path of member; "" => pkg
+	name  string
+}
+
+func (r *textRef) Name() string              { return r.name }
+func (r *textRef) String() string            { return r.name }
+func (r *textRef) Type() types.Type          { return nil }
+func (r *textRef) Referrers() *[]Instruction { return nil }
+func (r *textRef) ImplementsValue()          {}
+
+// Errors -------------------------------------------------------------
+
+func (p *textParser) scanError(pos token.Position, msg string) {
+	panic(&scanner.Error{Pos: pos, Msg: msg})
+}
+
+func (p *textParser) errorAt(pos token.Pos, format string, args ...interface{}) {
+	panic(&scanner.Error{Pos: p.fset.Position(pos), Msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) {
+	p.errorAt(p.pos, format, args...)
+}
+
+// Tokens -------------------------------------------------------------
+
+func (p *textParser) next() {
+	p.pos, p.tok, p.lit = p.scanner.Scan()
+}
+
+// found describes the current token, for error messages.
+func (p *textParser) found() string {
+	switch {
+	case p.tok == token.SEMICOLON && p.lit == "\n":
+		return "newline"
+	case p.lit != "":
+		return strconv.Quote(p.lit)
+	}
+	return p.tok.String()
+}
+
+func (p *textParser) expect(tok token.Token) {
+	if p.tok != tok {
+		p.errorf("expected %s, found %s", tok, p.found())
+	}
+	p.next()
+}
+
+func (p *textParser) got(tok token.Token) bool {
+	if p.tok == tok {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// isWord reports whether the current token is the identifier or
+// keyword w.
+func (p *textParser) isWord(w string) bool {
+	return (p.tok == token.IDENT || p.tok.IsKeyword()) && p.lit == w
+}
+
+func (p *textParser) gotWord(w string) bool {
+	if p.isWord(w) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *textParser) expectWord(w string) {
+	if !p.gotWord(w) {
+		p.errorf("expected %s, found %s", w, p.found())
+	}
+}
+
+// word returns the current identifier or keyword.
+func (p *textParser) word() string {
+	if p.tok != token.IDENT && !p.tok.IsKeyword() {
+		p.errorf("expected identifier, found %s", p.found())
+	}
+	w := p.lit
+	p.next()
+	return w
+}
+
+func (p *textParser) ident() string {
+	if p.tok != token.IDENT {
+		p.errorf("expected identifier, found %s", p.found())
+	}
+	id := p.lit
+	p.next()
+	return id
+}
+
+func (p *textParser) int() int64 {
+	if p.tok != token.INT {
+		p.errorf("expected integer, found %s", p.found())
+	}
+	i, err := strconv.ParseInt(p.lit, 0, 64)
+	if err != nil {
+		p.errorf("invalid integer %s", p.lit)
+	}
+	p.next()
+	return i
+}
+
+func (p *textParser) string() string {
+	if p.tok != token.STRING {
+		p.errorf("expected string, found %s", p.found())
+	}
+	s, err := strconv.Unquote(p.lit)
+	if err != nil {
+		p.errorf("invalid string %s", p.lit)
+	}
+	p.next()
+	return s
+}
+
+// endLine consumes the end of a declaration or instruction.
+func (p *textParser) endLine() {
+	if p.tok != token.EOF {
+		p.expect(token.SEMICOLON)
+	}
+}
+
+// endOfInstr reports whether the current token ends the operands of
+// an instruction.
+func (p *textParser) endOfInstr() bool {
+	return p.tok == token.SEMICOLON || p.tok == token.EOF || p.tok == token.COLON || p.isWord("at")
+}
+
+// Functions ----------------------------------------------------------
+
+func (p *textParser) parseFunc() {
+	p.expect(token.FUNC)
+	fn := &Function{Pkg: p.pkg, Prog: p.pkg.Prog}
+	p.fn = fn
+	p.values = make(map[string]Value)
+	p.blocks = make(map[int64]*BasicBlock)
+	p.phis = make(map[*Phi][]*BasicBlock)
+
+	var recv *types.Var
+	if p.got(token.LPAREN) {
+		param, _ := p.parseParam()
+		recv = types.NewVar(nil, param.Name_, param.Type_)
+		p.expect(token.RPAREN)
+	}
+
+	switch p.tok {
+	case token.IDENT:
+		fn.Name_ = p.ident()
+	case token.STRING:
+		fn.Name_ = p.string()
+	default:
+		p.errorf("expected function name, found %s", p.found())
+	}
+
+	var params []*types.Var
+	variadic := false
+	p.expect(token.LPAREN)
+	for p.tok != token.RPAREN {
+		if len(params) > 0 {
+			p.expect(token.COMMA)
+		}
+		if variadic {
+			p.errorf("can only use ... with final parameter")
+		}
+		var param *Parameter
+		param, variadic = p.parseParam()
+		params = append(params, types.NewVar(nil, param.Name_, param.Type_))
+	}
+	p.next()
+	var results *types.Tuple
+	if p.startsType() {
+		results = p.resultType()
+	}
+	fn.Signature = types.NewSignature(recv, types.NewTuple(params...), results, variadic)
+	if p.gotWord("at") {
+		fn.pos = p.parsePos()
+	}
+	p.endLine()
+
+	_, name := funcMemberName(fn)
+	if p.funcs[name] != nil {
+		p.errorf("duplicate function %s", name)
+	}
+	p.funcs[name] = fn
+	p.fns = append(p.fns, fn)
+
+	if p.gotWord("parent") {
+		if p.tok != token.STRING {
+			p.errorf("expected function, found %s", p.found())
+		}
+		if p.parents == nil {
+			p.parents = make(map[*Function]*textRef)
+		}
+		p.parents[fn] = p.parseMember()
+		p.endLine()
+	}
+
+	for p.gotWord("free") {
+		p.expect(token.REM)
+		fv := &Capture{Name_: p.ident()}
+		fv.Type_ = p.parseType()
+		fn.FreeVars = append(fn.FreeVars, fv)
+		p.define(fv.Name_, fv)
+		p.endLine()
+	}
+
+	if p.got(token.LBRACE) {
+		for p.tok == token.SEMICOLON {
+			p.next()
+		}
+		for p.tok != token.RBRACE {
+			p.parseBlock()
+		}
+		p.next()
+		p.endLine()
+		p.finishBody()
+	}
+}
+
+// parseParam parses a parameter, appends it to the current function,
+// and reports whether it is variadic.
+func (p *textParser) parseParam() (*Parameter, bool) {
+	p.expect(token.REM)
+	param := &Parameter{Name_: p.ident()}
+	variadic := p.got(token.ELLIPSIS)
+	param.Type_ = p.parseType()
+	if variadic {
+		param.Type_ = types.NewSlice(param.Type_)
+	}
+	p.fn.Params = append(p.fn.Params, param)
+	p.define(param.Name_, param)
+	return param, variadic
+}
+
+// parsePos parses the string of a Pos and returns the position in the
+// files of the program that it denotes.
+func (p *textParser) parsePos() token.Pos {
+	pos := p.pos
+	s := p.string()
+
+	// s is "file:line:col".
+	var line, col int
+	var err error
+	i := strings.LastIndex(s, ":")
+	j := -1
+	if i > 0 {
+		j = strings.LastIndex(s[:i], ":")
+	}
+	if j > 0 {
+		line, err = strconv.Atoi(s[j+1 : i])
+		if err == nil {
+			col, err = strconv.Atoi(s[i+1:])
+		}
+	}
+	if j <= 0 || err != nil || line < 1 || col < 1 {
+		p.errorAt(pos, "invalid position %q", s)
+	}
+	filename := s[:j]
+
+	var result token.Pos
+	p.pkg.Prog.Files.Iterate(func(f *token.File) bool {
+		if f.Name() != filename {
+			return true
+		}
+		if line <= f.LineCount() {
+			// Find the offset of the start of the line.
+			lo, hi := 0, f.Size()
+			for lo < hi {
+				mid := (lo + hi) / 2
+				if f.Position(f.Pos(mid)).Line < line {
+					lo = mid + 1
+				} else {
+					hi = mid
+				}
+			}
+			if offset := lo + col - 1; offset <= f.Size() {
+				result = f.Pos(offset)
+			}
+		}
+		return false
+	})
+	return result
+}
+
+// define records v as the value of the local name.
+func (p *textParser) define(name string, v Value) {
+	if p.values[name] != nil {
+		p.errorf("%%%s redefined", name)
+	}
+	p.values[name] = v
+}
+
+// block returns the block of the current function with index i,
+// creating it if needed.
+func (p *textParser) block(i int64) *BasicBlock {
+	b := p.blocks[i]
+	if b == nil {
+		b = &BasicBlock{Index: int(i), Func: p.fn}
+		b.Succs = b.succs2[:0]
+		p.blocks[i] = b
+	}
+	return b
+}
+
+func (p *textParser) parseBlock() {
+	p.expectWord("block")
+	pos := p.pos
+	i := p.int()
+	if i != int64(len(p.fn.Blocks)) {
+		p.errorAt(pos, "block %d out of order", i)
+	}
+	b := p.block(i)
+	p.fn.Blocks = append(p.fn.Blocks, b)
+	b.Comment = p.string()
+	if p.gotWord("preds") {
+		for p.tok == token.INT {
+			b.Preds = append(b.Preds, p.block(p.int()))
+		}
+	}
+	p.endLine()
+
+	for !p.isWord("block") && p.tok != token.RBRACE {
+		if p.tok == token.EOF {
+			p.errorf("unexpected EOF")
+		}
+		p.parseInstr(b)
+	}
+}
+
+// finishBody resolves the local operands of the current function and
+// checks its control-flow graph.
+func (p *textParser) finishBody() {
+	fn := p.fn
+	for i := range p.blocks {
+		if i >= int64(len(fn.Blocks)) {
+			p.errorf("%s: undefined block %d", fn.Name_, i)
+		}
+	}
+
+	var rands []*Value
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			rands = instr.Operands(rands[:0])
+			for _, rand := range rands {
+				if ref, ok := (*rand).(*textRef); ok && ref.local {
+					v := p.values[ref.name]
+					if v == nil {
+						p.errorAt(ref.pos, "undefined: %%%s", ref.name)
+					}
+					*rand = v
+				}
+			}
+		}
+	}
+
+	// The edges of the graph must agree with the predecessors.
+	edges := make(map[[2]int]int)
+	for _, b := range fn.Blocks {
+		for _, succ := range b.Succs {
+			edges[[2]int{b.Index, succ.Index}]++
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, pred := range b.Preds {
+			edges[[2]int{pred.Index, b.Index}]--
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, succ := range b.Succs {
+			if edges[[2]int{b.Index, succ.Index}] != 0 {
+				p.errorf("%s: preds of block %d inconsistent with succs of block %d", fn.Name_, succ.Index, b.Index)
+			}
+		}
+		for _, pred := range b.Preds {
+			if edges[[2]int{pred.Index, b.Index}] != 0 {
+				p.errorf("%s: preds of block %d inconsistent with succs of block %d", fn.Name_, b.Index, pred.Index)
+			}
+		}
+	}
+
+	// The edges of φ-nodes must agree with the predecessors.
+	for phi, preds := range p.phis {
+		b := phi.Block()
+		if len(preds) != len(b.Preds) {
+			p.errorf("%s: phi in block %d has %d edges, want %d", fn.Name_, b.Index, len(preds), len(b.Preds))
+		}
+		for i, pred := range preds {
+			if pred != b.Preds[i] {
+				p.errorf("%s: edge %d of phi in block %d is from block %d, want %d", fn.Name_, i, b.Index, pred.Index, b.Preds[i].Index)
+			}
+		}
+	}
+}
+
+// finish resolves references to members and completes the functions.
+func (p *textParser) finish() {
+	var rands []*Value
+	for _, fn := range p.fns {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				rands = instr.Operands(rands[:0])
+				for _, rand := range rands {
+					if ref, ok := (*rand).(*textRef); ok {
+						*rand = p.member(ref)
+					}
+				}
+			}
+		}
+	}
+	for _, fn := range p.fns {
+		if ref := p.parents[fn]; ref != nil {
+			parent, ok := p.member(ref).(*Function)
+			if !ok {
+				p.errorAt(ref.pos, "parent %s is not a function", ref.name)
+			}
+			fn.Enclosing = parent
+			parent.AnonFuncs = append(parent.AnonFuncs, fn)
+		}
+	}
+	for _, fixup := range p.fixups {
+		fixup()
+	}
+	for _, fn := range p.fns {
+		if fn.Blocks != nil {
+			buildReferrers(fn)
+			buildDomTree(fn)
+			numberRegisters(fn)
+		}
+	}
+}
+
+// member returns the function or global denoted by ref.
+func (p *textParser) member(ref *textRef) Value {
+	if ref.bound {
+		ref.bound = false
+		meth, ok := p.member(ref).(*Function)
+		if !ok || meth.Signature.Recv() == nil {
+			p.errorAt(ref.pos, "%q.%s is not a method", ref.path, ref.name)
+		}
+		return makeBoundMethodThunk(p.pkg.Prog, meth)
+	}
+
+	pkg := p.pkg
+	if ref.path == "" {
+		if fn := p.funcs[ref.name]; fn != nil {
+			return fn
+		}
+	} else if pkg = p.pkg.Prog.Packages[ref.path]; pkg == nil {
+		p.errorAt(ref.pos, "unknown package %q", ref.path)
+	}
+
+	switch mem := pkg.Members[ref.name].(type) {
+	case *Function:
+		return mem
+	case *Global:
+		return mem
+	}
+
+	// A method, "(T).m" or "(*T).m"?
+	if i := strings.Index(ref.name, ")."); strings.HasPrefix(ref.name, "(") && i > 0 {
+		recv := ref.name[1:i]
+		star := strings.HasPrefix(recv, "*")
+		if t, ok := pkg.Members[strings.TrimPrefix(recv, "*")].(*Type); ok {
+			var typ types.Type = t.NamedType
+			if star {
+				typ = pointer(typ)
+			}
+			if fn := p.pkg.Prog.MethodSet(typ)[MakeId(ref.name[i+2:], pkg.Types)]; fn != nil {
+				return fn
+			}
+		}
+	}
+	p.errorAt(ref.pos, "undefined: %q.%s", ref.path, ref.name)
+	return nil
+}
+
+// Instructions -------------------------------------------------------
+
+func (p *textParser) parseInstr(b *BasicBlock) {
+	pos := p.pos
+	name := ""
+	if p.got(token.REM) {
+		name = p.ident()
+		p.expect(token.ASSIGN)
+	}
+	op := p.word()
+	mods := make(map[string]bool)
+	for p.got(token.COMMA) {
+		mods[p.word()] = true
+	}
+	flag := func(mod string) bool {
+		ok := mods[mod]
+		delete(mods, mod)
+		return ok
+	}
+
+	var instr Instruction
+	switch op {
+	case "local", "new":
+		instr = &Alloc{Name_: p.string(), Heap: op == "new"}
+
+	case "phi":
+		phi := &Phi{}
+		p.expect(token.LBRACK)
+		for p.tok != token.RBRACK {
+			if len(phi.Edges) > 0 {
+				p.expect(token.COMMA)
+			}
+			p.phis[phi] = append(p.phis[phi], p.block(p.int()))
+			p.expect(token.COLON)
+			phi.Edges = append(phi.Edges, p.parseValue())
+		}
+		p.next()
+		phi.Comment = p.string()
+		instr = phi
+
+	case "call":
+		v := &Call{}
+		p.parseCall(&v.Call)
+		instr = v
+
+	case "go":
+		v := &Go{}
+		p.parseCall(&v.Call)
+		instr = v
+
+	case "defer":
+		v := &Defer{}
+		p.parseCall(&v.Call)
+		instr = v
+
+	case "binop":
+		v := &BinOp{Op: p.tok}
+		if !p.tok.IsOperator() {
+			p.errorf("expected operator, found %s", p.found())
+		}
+		p.next()
+		v.X, v.Y = p.parseValue2()
+		instr = v
+
+	case "unop":
+		v := &UnOp{Op: p.tok, CommaOk: flag("ok")}
+		if !p.tok.IsOperator() {
+			p.errorf("expected operator, found %s", p.found())
+		}
+		p.next()
+		v.X = p.parseValue()
+		instr = v
+
+	case "changetype":
+		instr = &ChangeType{X: p.parseValue()}
+
+	case "convert":
+		instr = &Convert{X: p.parseValue()}
+
+	case "changeinterface":
+		instr = &ChangeInterface{X: p.parseValue()}
+
+	case "makeinterface":
+		v := &MakeInterface{X: p.parseValue()}
+		p.fixups = append(p.fixups, func() {
+			v.Methods = p.pkg.Prog.MethodSet(v.X.Type())
+		})
+		instr = v
+
+	case "makeclosure":
+		v := &MakeClosure{Fn: p.parseValue()}
+		if p.got(token.LBRACK) {
+			v.Bindings = p.parseValues(token.RBRACK)
+			p.next()
+		}
+		instr = v
+
+	case "makemap":
+		v := &MakeMap{}
+		if !p.endOfInstr() {
+			v.Reserve = p.parseValue()
+		}
+		instr = v
+
+	case "makechan":
+		instr = &MakeChan{Size: p.parseValue()}
+
+	case "makeslice":
+		v := &MakeSlice{}
+		v.Len, v.Cap = p.parseValue2()
+		instr = v
+
+	case "slice":
+		v := &Slice{}
+		v.X, v.Low = p.parseValue2()
+		p.expect(token.COMMA)
+		v.High = p.parseValue()
+		instr = v
+
+	case "fieldaddr":
+		v := &FieldAddr{X: p.parseValue()}
+		p.expect(token.COMMA)
+		v.Field = int(p.int())
+		instr = v
+
+	case "field":
+		v := &Field{X: p.parseValue()}
+		p.expect(token.COMMA)
+		v.Field = int(p.int())
+		instr = v
+
+	case "indexaddr":
+		v := &IndexAddr{}
+		v.X, v.Index = p.parseValue2()
+		instr = v
+
+	case "index":
+		v := &Index{}
+		v.X, v.Index = p.parseValue2()
+		instr = v
+
+	case "lookup":
+		v := &Lookup{CommaOk: flag("ok")}
+		v.X, v.Index = p.parseValue2()
+		instr = v
+
+	case "select":
+		v := &Select{Blocking: flag("blocking")}
+		if !v.Blocking && !flag("nonblocking") {
+			p.errorf("select must be blocking or nonblocking")
+		}
+		p.expect(token.LBRACK)
+		for p.tok != token.RBRACK {
+			if len(v.States) > 0 {
+				p.expect(token.COMMA)
+			}
+			var st SelectState
+			switch {
+			case p.gotWord("recv"):
+				st.Dir = ast.RECV
+				st.Chan = p.parseValue()
+			case p.gotWord("send"):
+				st.Dir = ast.SEND
+				st.Chan = p.parseValue()
+				st.Send = p.parseValue()
+			default:
+				p.errorf("expected recv or send, found %s", p.found())
+			}
+			v.States = append(v.States, st)
+		}
+		p.next()
+		instr = v
+
+	case "range":
+		instr = &Range{X: p.parseValue()}
+
+	case "next":
+		instr = &Next{IsString: flag("string"), Iter: p.parseValue()}
+
+	case "typeassert":
+		v := &TypeAssert{CommaOk: flag("ok"), X: p.parseValue()}
+		p.expect(token.COMMA)
+		v.AssertedType = p.parseType()
+		instr = v
+
+	case "extract":
+		v := &Extract{Tuple: p.parseValue()}
+		p.expect(token.COMMA)
+		v.Index = int(p.int())
+		instr = v
+
+	case "jump":
+		b.Succs = append(b.Succs, p.block(p.int()))
+		instr = &Jump{}
+
+	case "if":
+		v := &If{Cond: p.parseValue()}
+		p.expect(token.COMMA)
+		t := p.block(p.int())
+		p.expect(token.COMMA)
+		f := p.block(p.int())
+		b.Succs = append(b.Succs, t, f)
+		instr = v
+
+	case "ret":
+		v := &Ret{}
+		if !p.endOfInstr() {
+			v.Results = p.parseValues(token.SEMICOLON)
+		}
+		instr = v
+
+	case "rundefers":
+		instr = &RunDefers{}
+
+	case "panic":
+		instr = &Panic{X: p.parseValue()}
+
+	case "send":
+		v := &Send{}
+		v.Chan, v.X = p.parseValue2()
+		instr = v
+
+	case "store":
+		v := &Store{}
+		v.Addr, v.Val = p.parseValue2()
+		instr = v
+
+	case "mapupdate":
+		v := &MapUpdate{}
+		v.Map, v.Key = p.parseValue2()
+		p.expect(token.COMMA)
+		v.Value = p.parseValue()
+		instr = v
+
+	default:
+		p.errorAt(pos, "unknown instruction %s", op)
+	}
+	for mod := range mods {
+		p.errorAt(pos, "unexpected modifier ,%s of %s", mod, op)
+	}
+
+	// Result.
+	if v, ok := instr.(Value); ok {
+		if name == "" {
+			p.errorAt(pos, "result of %s must be named", op)
+		}
+		p.expect(token.COLON)
+		t := p.parseType()
+		if alloc, ok := v.(*Alloc); ok {
+			if _, ok := t.Underlying().(*types.Pointer); !ok {
+				p.errorAt(pos, "type of %s must be a pointer", op)
+			}
+			alloc.Type_ = t
+			if !alloc.Heap {
+				p.fn.Locals = append(p.fn.Locals, alloc)
+			}
+		} else {
+			v.(interface {
+				setType(types.Type)
+			}).setType(t)
+		}
+		p.define(name, v)
+	} else if name != "" {
+		p.errorAt(pos, "%s has no result", op)
+	}
+
+	if p.gotWord("at") {
+		setTextPos(instr, p.parsePos())
+	}
+	p.endLine()
+	b.emit(instr)
+}
+
+// setTextPos sets the position of instr.
+func setTextPos(instr Instruction, pos token.Pos) {
+	switch instr := instr.(type) {
+	case *Alloc:
+		instr.pos = pos
+	case *Call:
+		instr.Call.pos = pos
+	case *Go:
+		instr.Call.pos = pos
+	case *Defer:
+		instr.Call.pos = pos
+	case *Ret:
+		instr.pos = pos
+	case *Panic:
+		instr.pos = pos
+	case *Send:
+		instr.pos = pos
+	case *Store:
+		instr.pos = pos
+	case *MapUpdate:
+		instr.pos = pos
+	case interface {
+		setPos(token.Pos)
+	}:
+		instr.setPos(pos)
+	}
+}
+
+// parseCall parses the operation of a Call, Go or Defer into c.
+func (p *textParser) parseCall(c *CallCommon) {
+	if p.gotWord("invoke") {
+		c.Recv = p.parseValue()
+		p.expect(token.PERIOD)
+		pos := p.pos
+		name := p.ident()
+		p.fixups = append(p.fixups, func() {
+			if itf, ok := c.Recv.Type().Underlying().(*types.Interface); ok {
+				for i, n := 0, itf.NumMethods(); i < n; i++ {
+					if itf.Method(i).Name() == name {
+						c.Method = i
+						return
+					}
+				}
+			}
+			p.errorAt(pos, "no method %s in %s", name, c.Recv.Type())
+		})
+	} else {
+		c.Func = p.parseValue()
+	}
+	p.expect(token.LPAREN)
+	for p.tok != token.RPAREN {
+		if len(c.Args) > 0 {
+			p.expect(token.COMMA)
+		}
+		c.Args = append(c.Args, p.parseValue())
+		if p.got(token.ELLIPSIS) {
+			c.HasEllipsis = true
+			break
+		}
+	}
+	p.expect(token.RPAREN)
+}
+
+// Operands -----------------------------------------------------------
+
+func (p *textParser) parseValue() Value {
+	pos := p.pos
+	switch {
+	case p.tok == token.REM:
+		p.next()
+		name := p.ident()
+		if v := p.values[name]; v != nil {
+			return v
+		}
+		return &textRef{pos: pos, local: true, name: name} // forward reference
+
+	case p.tok == token.STRING:
+		return p.parseMember()
+
+	case p.tok == token.CONST:
+		p.next()
+		t := p.parseType()
+		return newLiteral(p.parseConst(), t)
+
+	case p.isWord("builtin"):
+		p.next()
+		name := p.ident()
+		obj := types.Universe.Lookup(name)
+		if obj == nil {
+			obj = types.Unsafe.Scope().Lookup(name)
+		}
+		b := p.pkg.Prog.Builtins[obj]
+		if b == nil {
+			p.errorAt(pos, "unknown built-in %s", name)
+		}
+		return b
+
+	case p.isWord("_"):
+		p.next()
+		return nil
+
+	case p.isWord("bound"):
+		p.next()
+		ref := p.parseMember()
+		ref.bound = true
+		return ref
+
+	case p.isWord("thunk"):
+		p.next()
+		typ := p.parseType()
+		p.expect(token.PERIOD)
+		id := MakeId(p.parseMethodName())
+		itf, ok := typ.Underlying().(*types.Interface)
+		if !ok {
+			p.errorAt(pos, "thunk of non-interface type %s", typ)
+		}
+		for i, n := 0, itf.NumMethods(); i < n; i++ {
+			if m := itf.Method(i); MakeId(m.Name(), m.Pkg()) == id {
+				return makeImethodThunk(p.pkg.Prog, typ, id)
+			}
+		}
+		p.errorAt(pos, "interface %s has no method %s", typ, id.Name)
+	}
+	p.errorf("expected operand, found %s", p.found())
+	return nil
+}
+
+// parseValue2 parses a pair of operands.
+func (p *textParser) parseValue2() (x, y Value) {
+	x = p.parseValue()
+	p.expect(token.COMMA)
+	y = p.parseValue()
+	return
+}
+
+// parseValues parses a list of operands up to, but excluding, the
+// token end.
+func (p *textParser) parseValues(end token.Token) []Value {
+	var vs []Value
+	for p.tok != end && !p.endOfInstr() {
+		if len(vs) > 0 {
+			p.expect(token.COMMA)
+		}
+		vs = append(vs, p.parseValue())
+	}
+	return vs
+}
+
+// parseMember parses a Member as a placeholder for resolution once
+// the whole text has been parsed.
+func (p *textParser) parseMember() *textRef {
+	ref := &textRef{pos: p.pos, path: p.string()}
+	p.expect(token.PERIOD)
+	if p.tok == token.STRING {
+		ref.name = p.string()
+	} else {
+		ref.name = p.ident()
+	}
+	return ref
+}
+
+func (p *textParser) parseConst() exact.Value {
+	switch {
+	case p.isWord("true"), p.isWord("false"):
+		return exact.MakeBool(p.word() == "true")
+	case p.isWord("nil"):
+		p.next()
+		return exact.MakeNil()
+	case p.tok == token.STRING:
+		return exact.MakeString(p.string())
+	case p.isWord("complex"):
+		p.next()
+		p.expect(token.LPAREN)
+		re := p.parseNumber()
+		p.expect(token.COMMA)
+		im := p.parseNumber()
+		p.expect(token.RPAREN)
+		return exact.BinaryOp(re, token.ADD, exact.MakeImag(im))
+	}
+	return p.parseNumber()
+}
+
+// parseNumber parses an optionally negated integer, floating-point
+// number or fraction.
+func (p *textParser) parseNumber() exact.Value {
+	neg := p.got(token.SUB)
+	if p.tok != token.INT && p.tok != token.FLOAT {
+		p.errorf("expected number, found %s", p.found())
+	}
+	x := exact.MakeFromLiteral(p.lit, p.tok)
+	p.next()
+	if p.got(token.QUO) {
+		if p.tok != token.INT {
+			p.errorf("expected integer, found %s", p.found())
+		}
+		x = exact.BinaryOp(x, token.QUO, exact.MakeFromLiteral(p.lit, token.INT))
+		p.next()
+	}
+	if neg {
+		x = exact.UnaryOp(token.SUB, x, 0)
+	}
+	return x
+}
+
+// Types --------------------------------------------------------------
+
+// startsType reports whether the current token may start a type.
+func (p *textParser) startsType() bool {
+	switch p.tok {
+	case token.IDENT:
+		return p.lit != "at"
+	case token.STRING, token.MUL, token.LBRACK, token.MAP, token.CHAN,
+		token.ARROW, token.FUNC, token.STRUCT, token.INTERFACE, token.LPAREN,
+		token.RANGE:
+		return true
+	}
+	return false
+}
+
+func (p *textParser) parseType() types.Type {
+	pos := p.pos
+	switch p.tok {
+	case token.IDENT:
+		return p.namedType(p.ident())
+
+	case token.STRING:
+		path := p.string()
+		pkg := p.typesPackage(pos, path)
+		p.expect(token.PERIOD)
+		name := p.ident()
+		if obj, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok {
+			return obj.Type()
+		}
+		p.errorAt(pos, "undefined type %q.%s", path, name)
+
+	case token.MUL:
+		p.next()
+		return types.NewPointer(p.parseType())
+
+	case token.LBRACK:
+		p.next()
+		if p.got(token.RBRACK) {
+			return types.NewSlice(p.parseType())
+		}
+		n := p.int()
+		p.expect(token.RBRACK)
+		return types.NewArray(p.parseType(), n)
+
+	case token.MAP:
+		p.next()
+		p.expect(token.LBRACK)
+		key := p.parseType()
+		p.expect(token.RBRACK)
+		return types.NewMap(key, p.parseType())
+
+	case token.CHAN:
+		p.next()
+		dir := ast.SEND | ast.RECV
+		if p.got(token.ARROW) {
+			dir = ast.SEND
+		}
+		return types.NewChan(dir, p.parseType())
+
+	case token.ARROW:
+		p.next()
+		p.expect(token.CHAN)
+		return types.NewChan(ast.RECV, p.parseType())
+
+	case token.FUNC:
+		p.next()
+		params, variadic := p.typeList(true)
+		var results *types.Tuple
+		if p.startsType() {
+			results = p.resultType()
+		}
+		return types.NewSignature(nil, params, results, variadic)
+
+	case token.STRUCT:
+		p.next()
+		return p.structType()
+
+	case token.INTERFACE:
+		p.next()
+		return p.interfaceType()
+
+	case token.RANGE:
+		p.next()
+		return tRangeIter
+
+	case token.LPAREN:
+		t, _ := p.typeList(false)
+		if t.Len() == 1 {
+			return t.At(0).Type() // parenthesized type
+		}
+		return t
+	}
+	p.errorf("expected type, found %s", p.found())
+	return nil
+}
+
+// typesPackage returns the package with the given import path; ""
+// denotes the package against which the text is parsed.
+func (p *textParser) typesPackage(pos token.Pos, path string) *types.Package {
+	if path == "" {
+		return p.pkg.Types
+	}
+	if pkg := p.pkg.Types.Imports()[path]; pkg != nil {
+		return pkg
+	}
+	if pkg := p.pkg.Prog.Packages[path]; pkg != nil {
+		return pkg.Types
+	}
+	p.errorAt(pos, "unknown package %q", path)
+	return nil
+}
+
+// parseMethodName parses a MethodName and returns its name and package.
+func (p *textParser) parseMethodName() (string, *types.Package) {
+	pkg := p.pkg.Types
+	if p.tok == token.STRING {
+		pkg = p.typesPackage(p.pos, p.string())
+		p.expect(token.PERIOD)
+	}
+	return p.ident(), pkg
+}
+
+// namedType returns the type denoted by the identifier name, which
+// has been consumed.
+func (p *textParser) namedType(name string) types.Type {
+	pos := p.pos
+	switch name {
+	case "invalid":
+		return types.Typ[types.Invalid]
+	case "untyped":
+		kind := "untyped " + p.ident()
+		for _, t := range types.Typ {
+			if t.Name() == kind {
+				return t
+			}
+		}
+		p.errorAt(pos, "unknown type %s", kind)
+	case "unsafe":
+		if p.got(token.PERIOD) {
+			if p.ident() != "Pointer" {
+				p.errorAt(pos, "expected unsafe.Pointer")
+			}
+			return types.Typ[types.UnsafePointer]
+		}
+	}
+	if obj, ok := p.pkg.Types.Scope().Lookup(name).(*types.TypeName); ok {
+		return obj.Type()
+	}
+	if obj, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+		return obj.Type()
+	}
+	p.errorAt(pos, "undefined type %s", name)
+	return nil
+}
+
+// resultType parses the results of a function type.
+func (p *textParser) resultType() *types.Tuple {
+	t := p.parseType()
+	if tuple, ok := t.(*types.Tuple); ok {
+		return tuple
+	}
+	return types.NewTuple(types.NewVar(nil, "", t))
+}
+
+// typeList parses a parenthesized list of types.  If variadic is
+// true, the last may be written "...T", and the result reports whether
+// it was.
+func (p *textParser) typeList(variadic bool) (*types.Tuple, bool) {
+	var vars []*types.Var
+	isVariadic := false
+	p.expect(token.LPAREN)
+	for p.tok != token.RPAREN {
+		if len(vars) > 0 {
+			p.expect(token.COMMA)
+		}
+		if isVariadic {
+			p.errorf("can only use ... with final parameter")
+		}
+		if variadic && p.got(token.ELLIPSIS) {
+			isVariadic = true
+			vars = append(vars, types.NewVar(nil, "", types.NewSlice(p.parseType())))
+		} else {
+			vars = append(vars, types.NewVar(nil, "", p.parseType()))
+		}
+	}
+	p.next()
+	return types.NewTuple(vars...), isVariadic
+}
+
+// interfaceType parses the methods of an interface type.
+func (p *textParser) interfaceType() types.Type {
+	var methods []*types.Func
+	p.expect(token.LBRACE)
+	for p.tok != token.RBRACE {
+		if len(methods) > 0 {
+			p.expect(token.SEMICOLON)
+		}
+		name, pkg := p.parseMethodName()
+		params, variadic := p.typeList(true)
+		var results *types.Tuple
+		if p.startsType() {
+			results = p.resultType()
+		}
+		methods = append(methods, types.NewFunc(pkg, name, types.NewSignature(nil, params, results, variadic)))
+	}
+	p.next()
+	return types.NewInterface(methods)
+}
+
+// structType parses the fields of a struct type.
+func (p *textParser) structType() types.Type {
+	var fields []*types.Field
+	var tags []string
+	p.expect(token.LBRACE)
+	for p.tok != token.RBRACE {
+		if len(fields) > 0 {
+			p.expect(token.SEMICOLON)
+		}
+		var name string
+		var typ types.Type
+		anon := true
+		if p.tok == token.IDENT {
+			name = p.ident()
+			switch p.tok {
+			case token.SEMICOLON, token.RBRACE, token.STRING, token.PERIOD:
+				typ = p.namedType(name) // embedded
+			default:
+				anon = false
+				typ = p.parseType()
+			}
+		} else {
+			typ = p.parseType() // embedded *T or "path".T
+		}
+		if anon {
+			t := typ
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			switch t := t.(type) {
+			case *types.Named:
+				name = t.Obj().Name()
+			case *types.Basic:
+				name = t.Name()
+			default:
+				p.errorf("invalid embedded field type %s", typ)
+			}
+		}
+		if p.tok == token.STRING {
+			for len(tags) < len(fields) {
+				tags = append(tags, "")
+			}
+			tags = append(tags, p.string())
+		}
+		fields = append(fields, &types.Field{Var: *types.NewVar(p.pkg.Types, name, typ), IsAnonymous: anon})
+	}
+	p.next()
+	return types.NewStruct(fields, tags)
+}
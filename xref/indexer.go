@@ -0,0 +1,550 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the construction of indices.
+
+package xref
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"code.google.com/p/go.tools/go/types"
+	"github.com/sourcegraph/go.tools/go/exact"
+)
+
+// An Indexer type-checks packages and builds their cross-reference index.
+type Indexer struct {
+	fset *token.FileSet
+	ctxt types.Context // context used for type-checking
+
+	pkgs  map[string]*types.Package // indexed packages, by path
+	order []*types.Package          // indexed packages, in order of addition
+	ids   map[types.Object]string   // object -> symbol id
+	idx   *Index
+}
+
+// NewIndexer returns a new Indexer for packages whose files belong to fset.
+// If ctxt is not nil, it is used for type-checking; its Ident and Expr
+// callbacks are called as usual. Packages added to the Indexer before are
+// imported from source, other packages are imported via ctxt.Import (or
+// the default importer).
+func NewIndexer(fset *token.FileSet, ctxt *types.Context) *Indexer {
+	x := &Indexer{
+		fset: fset,
+		pkgs: make(map[string]*types.Package),
+		ids:  make(map[types.Object]string),
+		idx: &Index{
+			Symbols: make(map[string]*Symbol),
+			Files:   make(map[string][]*Ref),
+		},
+	}
+	if ctxt != nil {
+		x.ctxt = *ctxt
+	}
+	return x
+}
+
+// An ident records an identifier and the object it denotes.
+type ident struct {
+	id  *ast.Ident
+	obj types.Object
+}
+
+// Add type-checks the package with the given path and files, and adds
+// its symbols and references to the index. The first error reported by
+// the type checker is returned; the package is indexed nevertheless.
+func (x *Indexer) Add(path string, files ...*ast.File) error {
+	var idents []ident
+	exprs := make(map[ast.Expr]types.Type)
+
+	ctxt := x.ctxt
+	ctxt.Ident = func(id *ast.Ident, obj types.Object) {
+		idents = append(idents, ident{id, obj})
+		if f := x.ctxt.Ident; f != nil {
+			f(id, obj)
+		}
+	}
+	ctxt.Expr = func(e ast.Expr, typ types.Type, val exact.Value) {
+		exprs[e] = typ
+		if f := x.ctxt.Expr; f != nil {
+			f(e, typ, val)
+		}
+	}
+	ctxt.Import = x.importer(x.ctxt.Import)
+
+	pkg, err := ctxt.Check(path, x.fset, files...)
+	if pkg == nil {
+		return err
+	}
+	x.pkgs[path] = pkg
+	x.order = append(x.order, pkg)
+
+	// determine the syntactic context of identifiers
+	parents := make(map[ast.Node]ast.Node)
+	for _, file := range files {
+		var stack []ast.Node
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+			if len(stack) > 0 {
+				parents[n] = stack[len(stack)-1]
+			}
+			stack = append(stack, n)
+			return true
+		})
+	}
+
+	objs := make(map[*ast.Ident]types.Object)
+	for _, e := range idents {
+		objs[e.id] = e.obj
+	}
+
+	b := builder{x, pkg, parents, exprs, objs}
+
+	// Record declarations first, so that objects without position
+	// information (e.g., interface methods) are identified by their
+	// declaration.
+	var uses []ident
+	for _, e := range idents {
+		if b.isDef(e.id, e.obj) {
+			b.ref(e.id, e.obj, true)
+		} else {
+			uses = append(uses, e)
+		}
+	}
+	for _, e := range uses {
+		b.ref(e.id, e.obj, false)
+	}
+	x.idx.refs = nil // invalidate cached references
+
+	return err
+}
+
+// importer returns an Importer that returns indexed packages
+// and uses imp (or the default importer) for all other packages.
+func (x *Indexer) importer(imp types.Importer) types.Importer {
+	if imp == nil {
+		imp = types.GcImport
+	}
+	return func(imports map[string]*types.Package, path string) (*types.Package, error) {
+		if pkg := x.pkgs[path]; pkg != nil {
+			imports[path] = pkg
+			return pkg, nil
+		}
+		return imp(imports, path)
+	}
+}
+
+// Index returns the index of all packages added so far. It computes the
+// implements relations between the named types declared in the indexed
+// packages and all named types (including interfaces) of the indexed and
+// imported packages.
+func (x *Indexer) Index() *Index {
+	idx := x.idx
+	idx.refs = nil
+
+	for _, refs := range idx.Files {
+		sort.Sort(byOffset(refs))
+	}
+
+	// collect named types
+	var all, local []*types.Named
+	seen := make(map[*types.Package]bool)
+	var collect func(pkg *types.Package, indexed bool)
+	collect = func(pkg *types.Package, indexed bool) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		for _, obj := range pkg.Scope().Entries {
+			if obj, ok := obj.(*types.TypeName); ok {
+				if t, ok := obj.Type().(*types.Named); ok {
+					all = append(all, t)
+					if indexed {
+						local = append(local, t)
+					}
+				}
+			}
+		}
+	}
+	for _, pkg := range x.order {
+		collect(pkg, true)
+	}
+	for _, pkg := range x.order {
+		for _, imp := range pkg.Imports() {
+			collect(imp, false)
+		}
+	}
+
+	// determine implements and overrides relations, if at least
+	// one of the types involved is declared in an indexed package
+	idx.Implements = nil
+	idx.Overrides = nil
+	overrides := make(map[Relation]bool)
+	isLocal := make(map[*types.Named]bool)
+	for _, t := range local {
+		isLocal[t] = true
+	}
+	for _, iface := range all {
+		it, ok := iface.Underlying().(*types.Interface)
+		if !ok || it.IsEmpty() {
+			continue
+		}
+		for _, t := range all {
+			if _, isIface := t.Underlying().(*types.Interface); isIface {
+				continue
+			}
+			if !isLocal[t] && !isLocal[iface] {
+				continue
+			}
+			if !types.Implements(t, it) && !types.Implements(types.NewPointer(t), it) {
+				continue
+			}
+			tid := x.objID(t.Obj())
+			idx.Implements = append(idx.Implements, Relation{tid, x.objID(iface.Obj())})
+			x.symbol(t.Obj(), tid)
+			x.symbol(iface.Obj(), x.objID(iface.Obj()))
+			it.ForEachMethod(func(im *types.Func) {
+				if m := findMethod(t, im); m != nil && m != im {
+					owner := findOwner(t, m)
+					if owner == nil {
+						return
+					}
+					mid := x.memberID(m, owner)
+					imid := x.memberID(im, iface)
+					r := Relation{mid, imid}
+					if overrides[r] {
+						return // promoted method related before
+					}
+					overrides[r] = true
+					idx.Overrides = append(idx.Overrides, r)
+					x.symbol(m, mid)
+					x.symbol(im, imid)
+				}
+			})
+		}
+	}
+
+	return idx
+}
+
+type byOffset []*Ref
+
+func (s byOffset) Len() int           { return len(s) }
+func (s byOffset) Less(i, j int) bool { return s[i].Pos.Offset < s[j].Pos.Offset }
+func (s byOffset) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// A builder adds the references of a type-checked package to an index.
+type builder struct {
+	*Indexer
+	pkg     *types.Package
+	parents map[ast.Node]ast.Node
+	exprs   map[ast.Expr]types.Type
+	objs    map[*ast.Ident]types.Object
+}
+
+// isDef reports whether id declares obj.
+func (b *builder) isDef(id *ast.Ident, obj types.Object) bool {
+	if pos := obj.Pos(); pos.IsValid() {
+		return pos == id.Pos()
+	}
+	// objects without position: interface methods are declared
+	// by the names of fields in interface types
+	field, ok := b.parents[id].(*ast.Field)
+	return ok && len(field.Names) > 0 && b.isInterfaceField(field)
+}
+
+func (b *builder) isInterfaceField(field *ast.Field) bool {
+	list, _ := b.parents[field].(*ast.FieldList)
+	_, ok := b.parents[list].(*ast.InterfaceType)
+	return ok
+}
+
+// ref records a reference of id to obj.
+func (b *builder) ref(id *ast.Ident, obj types.Object, def bool) {
+	sid := b.ids[obj]
+	if sid == "" {
+		sid = b.identify(id, obj, def)
+		b.ids[obj] = sid
+	}
+	b.symbol(obj, sid)
+
+	pos := b.fset.Position(id.Pos())
+	b.idx.Files[pos.Filename] = append(b.idx.Files[pos.Filename], &Ref{
+		Symbol: sid,
+		Pos:    pos,
+		Len:    len(id.Name),
+		Def:    def,
+	})
+}
+
+// identify returns the symbol id for obj, using the syntactic context
+// of the identifier id if necessary.
+func (b *builder) identify(id *ast.Ident, obj types.Object, def bool) string {
+	if _, ok := obj.(*types.Func); ok && def && !obj.Pos().IsValid() {
+		// interface method declaration
+		if owner := b.declaredType(id); owner != nil {
+			return b.typeID(owner) + "." + obj.Name()
+		}
+		return b.localID(obj, id.Pos())
+	}
+
+	if owner := b.owner(id, obj); owner != nil {
+		return b.memberID(obj, owner)
+	}
+	if obj.Pos().IsValid() && !b.isPackageLevel(obj) {
+		return b.localID(obj, obj.Pos())
+	}
+	if b.isMember(obj) {
+		// field or method whose owner is unknown
+		return b.localID(obj, id.Pos())
+	}
+	return b.objID(obj)
+}
+
+// declaredType returns the named type declared by the type declaration
+// whose interface type contains the method name id, or nil.
+func (b *builder) declaredType(id *ast.Ident) *types.Named {
+	field := b.parents[id]
+	list := b.parents[field]
+	iface := b.parents[list]
+	spec, ok := b.parents[iface].(*ast.TypeSpec)
+	if !ok || spec.Type != iface {
+		return nil
+	}
+	if obj := b.objs[spec.Name]; obj != nil {
+		t, _ := obj.Type().(*types.Named)
+		return t
+	}
+	return nil
+}
+
+// owner returns the named type declaring the field or method obj,
+// or nil if obj is not a field or method or if the type is unknown.
+func (b *builder) owner(id *ast.Ident, obj types.Object) *types.Named {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			if t, ok := sig.Recv().Type().Deref().(*types.Named); ok {
+				return t
+			}
+			return nil
+		}
+	case *types.Field:
+		if obj.FieldOf != nil {
+			if t, ok := obj.FieldOf.Type().(*types.Named); ok {
+				return t
+			}
+		}
+	case *types.Var:
+		if obj.FieldOf != nil {
+			if t, ok := obj.FieldOf.Type().(*types.Named); ok {
+				return t
+			}
+		}
+	default:
+		return nil
+	}
+
+	// determine the type providing the context for the member
+	var typ types.Type
+	switch parent := b.parents[id].(type) {
+	case *ast.SelectorExpr:
+		if parent.Sel == id {
+			typ = b.exprs[parent.X]
+		}
+	case *ast.KeyValueExpr:
+		if lit, ok := b.parents[parent].(*ast.CompositeLit); ok && parent.Key == id {
+			typ = b.exprs[lit]
+		}
+	}
+	if typ == nil {
+		return nil
+	}
+	return findOwner(typ, obj)
+}
+
+// isMember reports whether obj is a field or method.
+func (b *builder) isMember(obj types.Object) bool {
+	switch obj := obj.(type) {
+	case *types.Field:
+		return true
+	case *types.Func:
+		_, isSig := obj.Type().(*types.Signature)
+		return isSig && obj.Pkg() != nil && obj.Pkg().Scope().Lookup(obj.Name()) != obj
+	}
+	return false
+}
+
+// isPackageLevel reports whether obj is declared at package level.
+func (b *builder) isPackageLevel(obj types.Object) bool {
+	if _, ok := obj.(*types.Package); ok {
+		return true
+	}
+	pkg := obj.Pkg()
+	return pkg != nil && pkg.Scope().Lookup(obj.Name()) == obj
+}
+
+// localID returns the symbol id of an object identified by its position.
+func (b *builder) localID(obj types.Object, pos token.Pos) string {
+	path := b.pkg.Path()
+	if pkg := obj.Pkg(); pkg != nil {
+		path = pkg.Path()
+	}
+	return fmt.Sprintf("%s.%s@%s", path, obj.Name(), b.fset.Position(pos))
+}
+
+// objID returns the symbol id for a package or package-level object.
+func (x *Indexer) objID(obj types.Object) string {
+	if sid := x.ids[obj]; sid != "" {
+		return sid
+	}
+	var sid string
+	switch {
+	case isPackage(obj):
+		sid = obj.(*types.Package).Path()
+	case obj.Pkg() == nil:
+		sid = "builtin." + obj.Name()
+	default:
+		sid = obj.Pkg().Path() + "." + obj.Name()
+	}
+	x.ids[obj] = sid
+	return sid
+}
+
+// typeID returns the symbol id for the named type t.
+func (x *Indexer) typeID(t *types.Named) string {
+	return x.objID(t.Obj())
+}
+
+// memberID returns the symbol id for the field or method obj of type t.
+func (x *Indexer) memberID(obj types.Object, t *types.Named) string {
+	if sid := x.ids[obj]; sid != "" {
+		return sid
+	}
+	sid := x.typeID(t) + "." + obj.Name()
+	x.ids[obj] = sid
+	return sid
+}
+
+// symbol records the symbol for obj with the given id, if it
+// doesn't exist yet.
+func (x *Indexer) symbol(obj types.Object, sid string) {
+	if x.idx.Symbols[sid] != nil {
+		return
+	}
+	sym := &Symbol{
+		ID:       sid,
+		Name:     obj.Name(),
+		Kind:     kind(obj),
+		Exported: ast.IsExported(obj.Name()),
+	}
+	switch {
+	case isPackage(obj):
+		sym.Pkg = obj.(*types.Package).Path()
+	case obj.Pkg() != nil:
+		sym.Pkg = obj.Pkg().Path()
+	default:
+		sym.Pkg = "builtin"
+	}
+	if !isPackage(obj) && obj.Type() != nil {
+		sym.Type = obj.Type().String()
+	}
+	if pos := obj.Pos(); pos.IsValid() && !isPackage(obj) {
+		sym.Def = x.fset.Position(pos)
+	}
+	x.idx.Symbols[sid] = sym
+}
+
+func isPackage(obj types.Object) bool {
+	_, ok := obj.(*types.Package)
+	return ok
+}
+
+// kind returns the symbol kind of obj.
+func kind(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Package:
+		return "package"
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	case *types.Field:
+		return "field"
+	case *types.Var:
+		if obj.FieldOf != nil {
+			return "field"
+		}
+		return "var"
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		if obj.Pkg() != nil && obj.Pkg().Scope().Lookup(obj.Name()) != obj {
+			return "method" // interface method
+		}
+		return "func"
+	}
+	return "unknown"
+}
+
+// findOwner returns the named type among typ and the types embedded
+// in it (directly or indirectly) that declares the field or method obj.
+// The result is nil if there is no such type.
+func findOwner(typ types.Type, obj types.Object) *types.Named {
+	seen := make(map[*types.Named]bool)
+	list := []types.Type{typ}
+	for len(list) > 0 {
+		var next []types.Type
+		for _, typ := range list {
+			t, _ := typ.Deref().(*types.Named)
+			if t != nil {
+				if seen[t] {
+					continue
+				}
+				seen[t] = true
+				for i := 0; i < t.NumMethods(); i++ {
+					if types.Object(t.Method(i)) == obj {
+						return t
+					}
+				}
+			}
+			switch u := typ.Deref().Underlying().(type) {
+			case *types.Struct:
+				for i := 0; i < u.NumFields(); i++ {
+					f := u.Field(i)
+					if types.Object(f) == obj && t != nil {
+						return t
+					}
+					if f.IsAnonymous {
+						next = append(next, f.Type())
+					}
+				}
+			case *types.Interface:
+				for i := 0; i < u.NumMethods(); i++ {
+					if types.Object(u.Method(i)) == obj && t != nil {
+						return t
+					}
+				}
+			}
+		}
+		list = next
+	}
+	return nil
+}
+
+// findMethod returns the method of t, or of a type embedded in t, that
+// implements the interface method im, or nil. Embedded interfaces are
+// taken into account.
+func findMethod(t *types.Named, im *types.Func) *types.Func {
+	m, _, _ := types.LookupFieldOrMethod(t, im.Pkg(), im.Name())
+	fn, _ := m.(*types.Func)
+	return fn
+}
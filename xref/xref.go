@@ -0,0 +1,168 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package xref builds cross-reference indices for type-checked Go
+// packages.
+//
+// An Index records the symbols (named language entities) declared or
+// referenced in a set of packages, every identifier denoting a symbol,
+// and the implements relations between named types and interfaces.
+// Indices are built with an Indexer, may be written to and read from
+// a stream (in JSON format), and answer queries by position or symbol.
+//
+// Symbols are identified by strings that are stable across packages
+// and independent of whether a package was type-checked from source or
+// imported from export data:
+//
+//	"path"              package
+//	"path.Name"         package-level object
+//	"path.T.Name"       method, struct field, or interface method of the named type T
+//	"path.Name@pos"     any other (e.g., local) object, identified by its declaration position
+//	"builtin.Name"      predeclared object
+package xref
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"sort"
+)
+
+// A Symbol describes a named language entity.
+type Symbol struct {
+	ID       string         // unique symbol id
+	Pkg      string         // package path; "builtin" for predeclared objects
+	Name     string         // object name
+	Kind     string         // "package", "const", "type", "var", "func", "method", or "field"
+	Type     string         // type of the object, if any
+	Def      token.Position // declaration position, if known (Filename != "")
+	Exported bool           // whether the object is exported
+}
+
+// A Ref describes an identifier denoting a symbol.
+type Ref struct {
+	Symbol string         // id of denoted symbol
+	Pos    token.Position // position of identifier
+	Len    int            // length of identifier
+	Def    bool           // whether the identifier declares the symbol
+}
+
+// A Relation describes a relation between two symbols.
+type Relation struct {
+	From, To string // symbol ids
+}
+
+// An Index is a cross-reference index.
+type Index struct {
+	Symbols map[string]*Symbol // symbol id -> symbol
+	Files   map[string][]*Ref  // filename -> references in the file, sorted by offset
+
+	// Implements relates named types (From) with the named interfaces
+	// (To) they or their pointer types implement. Overrides relates the
+	// methods of such types with the interface methods they implement.
+	Implements []Relation
+	Overrides  []Relation
+
+	refs map[string][]*Ref // symbol id -> references; computed on demand
+}
+
+// Write writes the index to w, in JSON format.
+func (idx *Index) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(idx)
+}
+
+// Read reads an index written with Index.Write.
+func Read(r io.Reader) (*Index, error) {
+	idx := new(Index)
+	if err := json.NewDecoder(r).Decode(idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Lookup returns the symbol with the given id, or nil.
+func (idx *Index) Lookup(id string) *Symbol {
+	return idx.Symbols[id]
+}
+
+// RefAt returns the reference at the given byte offset in the file
+// with the given name, or nil.
+func (idx *Index) RefAt(filename string, offset int) *Ref {
+	refs := idx.Files[filename]
+	i := sort.Search(len(refs), func(i int) bool {
+		return refs[i].Pos.Offset+refs[i].Len > offset
+	})
+	if i < len(refs) && refs[i].Pos.Offset <= offset {
+		return refs[i]
+	}
+	return nil
+}
+
+// SymbolAt returns the symbol denoted by the identifier at the given
+// byte offset in the file with the given name, or nil.
+func (idx *Index) SymbolAt(filename string, offset int) *Symbol {
+	if ref := idx.RefAt(filename, offset); ref != nil {
+		return idx.Symbols[ref.Symbol]
+	}
+	return nil
+}
+
+// References returns all references to the symbol with the given id,
+// sorted by position.
+func (idx *Index) References(id string) []*Ref {
+	if idx.refs == nil {
+		idx.refs = make(map[string][]*Ref)
+		var filenames []string
+		for filename := range idx.Files {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+		for _, filename := range filenames {
+			for _, ref := range idx.Files[filename] {
+				idx.refs[ref.Symbol] = append(idx.refs[ref.Symbol], ref)
+			}
+		}
+	}
+	return idx.refs[id]
+}
+
+// Implementations returns the ids of the named types implementing
+// the interface with the given id.
+func (idx *Index) Implementations(iface string) []string {
+	return related(idx.Implements, iface, false)
+}
+
+// Interfaces returns the ids of the named interfaces implemented
+// by the type with the given id.
+func (idx *Index) Interfaces(typ string) []string {
+	return related(idx.Implements, typ, true)
+}
+
+// OverriddenMethods returns the ids of the interface methods implemented
+// by the method with the given id.
+func (idx *Index) OverriddenMethods(method string) []string {
+	return related(idx.Overrides, method, true)
+}
+
+// Overriders returns the ids of the methods implementing the interface
+// method with the given id.
+func (idx *Index) Overriders(method string) []string {
+	return related(idx.Overrides, method, false)
+}
+
+// related returns the sorted ids related to id: the To symbols of
+// relations from id if forward is set, and the From symbols of relations
+// to id otherwise.
+func related(list []Relation, id string, forward bool) (res []string) {
+	for _, r := range list {
+		switch {
+		case forward && r.From == id:
+			res = append(res, r.To)
+		case !forward && r.To == id:
+			res = append(res, r.From)
+		}
+	}
+	sort.Strings(res)
+	return
+}
@@ -0,0 +1,226 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xref
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const srcP = `package p
+
+type T struct {
+	X int
+	E
+}
+
+type E struct {
+	Y int
+}
+
+func (t *T) M() int { return t.X + t.Y }
+func (E) N()        {}
+
+type I interface {
+	M() int
+}
+
+type J interface {
+	N()
+}
+
+func F() int {
+	x := &T{X: 1}
+	var i I = x
+	return i.M() + len("abc")
+}
+
+type L interface {
+	N()
+}
+`
+
+const srcQ = `package q
+
+import "p"
+
+type U struct{ p.T }
+
+func G() int {
+	var u U
+	u.N()
+	return u.M() + u.X
+}
+
+type V struct{ p.L } // implements p.J through the embedded interface
+`
+
+func index(t *testing.T) (*Index, *token.FileSet) {
+	fset := token.NewFileSet()
+	parse := func(filename, src string) *ast.File {
+		file, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	x := NewIndexer(fset, nil)
+	if err := x.Add("p", parse("p.go", srcP)); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Add("q", parse("q.go", srcQ)); err != nil {
+		t.Fatal(err)
+	}
+	return x.Index(), fset
+}
+
+// offset returns the offset of the n'th occurrence (counting from 0)
+// of the identifier name in src.
+func offset(src, name string, n int) int {
+	offs := 0
+	for {
+		i := strings.Index(src[offs:], name)
+		if i < 0 {
+			panic("identifier not found: " + name)
+		}
+		offs += i
+		if n == 0 {
+			return offs
+		}
+		n--
+		offs += len(name)
+	}
+}
+
+func TestSymbolAt(t *testing.T) {
+	idx, _ := index(t)
+	for _, test := range []struct {
+		filename, src, name string
+		n                   int
+		id, kind            string
+	}{
+		{"p.go", srcP, "T", 0, "p.T", "type"},
+		{"p.go", srcP, "X int", 0, "p.T.X", "field"},
+		{"p.go", srcP, "Y", 0, "p.E.Y", "field"},
+		{"p.go", srcP, "t.Y", 0, "p.t@p.go:12:7", "var"},
+		{"p.go", srcP, "Y }", 0, "p.E.Y", "field"},
+		{"p.go", srcP, "M() int", 0, "p.T.M", "method"},
+		{"p.go", srcP, "M() int", 1, "p.I.M", "method"},
+		{"p.go", srcP, "X: 1", 0, "p.T.X", "field"},
+		{"p.go", srcP, "M() +", 0, "p.I.M", "method"},
+		{"p.go", srcP, "F", 0, "p.F", "func"},
+		{"p.go", srcP, "len", 0, "builtin.len", "func"},
+		{"q.go", srcQ, "p.T", 0, "p", "package"},
+		{"q.go", srcQ, "T", 0, "p.T", "type"},
+		{"q.go", srcQ, "N", 0, "p.E.N", "method"},
+		{"q.go", srcQ, "M", 0, "p.T.M", "method"},
+		{"q.go", srcQ, "X", 0, "p.T.X", "field"},
+	} {
+		sym := idx.SymbolAt(test.filename, offset(test.src, test.name, test.n))
+		if sym == nil {
+			t.Errorf("%s: no symbol for %q (#%d)", test.filename, test.name, test.n)
+			continue
+		}
+		if sym.ID != test.id || sym.Kind != test.kind {
+			t.Errorf("%s: %q (#%d): got %s %s; want %s %s", test.filename, test.name, test.n, sym.Kind, sym.ID, test.kind, test.id)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	idx, _ := index(t)
+
+	var got []string
+	for _, ref := range idx.References("p.T.X") {
+		s := ref.Pos.String()
+		if ref.Def {
+			s += " def"
+		}
+		got = append(got, s)
+	}
+	want := []string{"p.go:4:2 def", "p.go:12:32", "p.go:24:10", "q.go:10:19"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got references %v; want %v", got, want)
+	}
+
+	sym := idx.Lookup("p.T")
+	if sym == nil || sym.Def.String() != "p.go:3:6" || !sym.Exported {
+		t.Errorf("got symbol %+v for p.T", sym)
+	}
+}
+
+func TestReferencesAfterAdd(t *testing.T) {
+	fset := token.NewFileSet()
+	x := NewIndexer(fset, nil)
+	file, err := parser.ParseFile(fset, "p.go", srcP, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Add("p", file); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(x.Index().References("p.T.X")); n != 3 {
+		t.Errorf("got %d references to p.T.X in p; want 3", n)
+	}
+
+	file, err = parser.ParseFile(fset, "q.go", srcQ, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Add("q", file); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(x.Index().References("p.T.X")); n != 4 {
+		t.Errorf("got %d references to p.T.X in p and q; want 4", n)
+	}
+}
+
+func TestImplements(t *testing.T) {
+	idx, _ := index(t)
+
+	for _, test := range []struct {
+		got, want []string
+	}{
+		// *U implements I through the embedded T
+		{idx.Implementations("p.I"), []string{"p.T", "q.U"}},
+		{idx.Implementations("p.J"), []string{"p.E", "p.T", "q.U", "q.V"}},
+		{idx.Interfaces("q.U"), []string{"p.I", "p.J", "p.L"}},
+		{idx.Overriders("p.J.N"), []string{"p.E.N", "p.L.N"}},
+		{idx.OverriddenMethods("p.L.N"), []string{"p.J.N"}},
+		{idx.OverriddenMethods("p.T.M"), []string{"p.I.M"}},
+	} {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("got %v; want %v", test.got, test.want)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	idx, _ := index(t)
+
+	var buf bytes.Buffer
+	if err := idx.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	idx2, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(idx.Symbols, idx2.Symbols) {
+		t.Errorf("symbols differ after reading index")
+	}
+	if !reflect.DeepEqual(idx.Files, idx2.Files) {
+		t.Errorf("references differ after reading index")
+	}
+	if !reflect.DeepEqual(idx.Implements, idx2.Implements) {
+		t.Errorf("implements relations differ after reading index")
+	}
+}